@@ -0,0 +1,551 @@
+// Package browser finds a Chrome or Chromium executable for ejspdf, and
+// installs a pinned Chromium snapshot into a local cache when none is
+// available. Downloaded archives are verified against a SHA-256 checksum
+// and installed atomically into a directory per revision.
+package browser
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	// DefaultRevision is a known-good revision of Chromium.
+	// Source: https://github.com/puppeteer/puppeteer/blob/main/packages/puppeteer-core/src/revisions.ts
+	DefaultRevision = "1056772"
+	// DefaultBaseURL is the server of the Chromium snapshots.
+	DefaultBaseURL = "https://storage.googleapis.com/chromium-browser-snapshots"
+	// MirrorEnv is the environment variable overriding DefaultBaseURL,
+	// e.g. with an internal artifact server.
+	MirrorEnv = "EJSPDF_BROWSER_MIRROR"
+	// PathEnv is the environment variable naming the browser to use.
+	PathEnv = "CHROME_PATH"
+	// CacheDirEnv is the environment variable of the ejspdf cache
+	// directory, which holds the browsers in its "browser" directory.
+	CacheDirEnv = "EJSPDF_CACHE_DIR"
+)
+
+// Config configures how a browser is installed.
+type Config struct {
+	// Revision is the Chromium snapshot revision to install. Default is
+	// DefaultRevision.
+	Revision string
+	// BaseURL is the server of the snapshots, laid out as
+	// "<BaseURL>/<platform>/<revision>/<archive>". Default is the value
+	// of $EJSPDF_BROWSER_MIRROR, or DefaultBaseURL.
+	BaseURL string
+	// Archive is the path of a local snapshot archive, e.g.
+	// chrome-linux.zip, to install instead of downloading one. It is
+	// verified like a download.
+	Archive string
+
+	// SHA256 is the expected hex checksum of the archive.
+	SHA256 string
+	// Manifest is the URL or file path of a checksum manifest, used when
+	// SHA256 is empty. Each line holds a checksum and an archive path in
+	// sha256sum format, e.g.
+	//
+	//	3f2a...e9  Linux_x64/1056772/chrome-linux.zip
+	Manifest string
+	// RequireChecksum refuses to install an archive whose checksum is not
+	// known. Otherwise the checksum of an unverified archive is logged so
+	// that it can be pinned.
+	RequireChecksum bool
+
+	// Progress is called as the archive is downloaded. Nothing is
+	// reported if it is nil.
+	Progress ProgressFunc
+
+	// Logger receives the discovery and install messages. Default is
+	// slog.Default(); discovery is logged at debug level.
+	Logger *slog.Logger
+
+	// Candidates are the names or paths of the installed browsers looked
+	// for, in order, before the cache. Default is the usual Chrome, Edge
+	// and Chromium executables of the platform.
+	Candidates []string
+
+	// CacheDir is the directory holding the installed browsers. Default
+	// is $EJSPDF_CACHE_DIR/browser, $XDG_CACHE_HOME/ejspdf/browser or
+	// ~/.cache/ejspdf/browser, in that order.
+	CacheDir string
+}
+
+func (cfg Config) withDefaults() (Config, error) {
+	if cfg.Revision == "" {
+		cfg.Revision = DefaultRevision
+	}
+	cfg.Logger = cfg.logger()
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = os.Getenv(MirrorEnv)
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	if strings.ContainsAny(cfg.Revision, `/\`) || cfg.Revision == "." || cfg.Revision == ".." {
+		return cfg, fmt.Errorf("invalid revision %q", cfg.Revision)
+	}
+	if cfg.CacheDir == "" {
+		dir, err := getCacheDir()
+		if err != nil {
+			return cfg, err
+		}
+		cfg.CacheDir = dir
+	}
+	return cfg, nil
+}
+
+func (cfg Config) logger() *slog.Logger {
+	if cfg.Logger == nil {
+		return slog.Default()
+	}
+	return cfg.Logger
+}
+
+// FindOrDownload attempts to find a locally installed Chrome/Chromium executable.
+// If not found, it will download a suitable version into a local cache.
+//
+// The browser named by $CHROME_PATH is used first, then the first of
+// the candidates found.
+func FindOrDownload(ctx context.Context, cfg Config) (string, error) {
+	// 1. First, try to find an installed version
+	if p := os.Getenv(PathEnv); p != "" {
+		found, err := exec.LookPath(p)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", PathEnv, err)
+		}
+		cfg.logger().Debug("found browser from environment", "path", found)
+		return found, nil
+	}
+	for _, path := range cfg.candidates() {
+		if p, err := exec.LookPath(path); err == nil {
+			cfg.logger().Debug("found installed browser", "path", p)
+			return p, nil
+		}
+	}
+
+	// 2. If not found, use or install the cached one
+	return Install(ctx, cfg)
+}
+
+// Install installs the configured revision into the cache, unless it is
+// already there, and returns the path of its executable. Unlike
+// FindOrDownload it ignores an installed Chrome, so that the browser can
+// be provisioned ahead of time, e.g. while building a container image.
+//
+// Concurrent installs of a revision download it once: callers in the
+// same process share the running install, and other processes wait for
+// its lock file in the cache directory.
+func Install(ctx context.Context, cfg Config) (string, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return "", err
+	}
+	dir, err := installDir(cfg)
+	if err != nil {
+		return "", err
+	}
+	if p, ok := cached(cfg, dir); ok {
+		return p, nil
+	}
+
+	return installs.do(ctx, dir, func(ctx context.Context) (string, error) {
+		if err := os.MkdirAll(cfg.CacheDir, 0755); err != nil {
+			return "", err
+		}
+		unlock, err := lockFile(ctx, dir+".lock")
+		if err != nil {
+			return "", err
+		}
+		defer unlock()
+		// Another process may have installed it while we waited.
+		if p, ok := cached(cfg, dir); ok {
+			return p, nil
+		}
+
+		cfg.Logger.Info("browser not found, installing chromium", "revision", cfg.Revision, "dir", dir)
+		return install(ctx, cfg)
+	})
+}
+
+// cached returns the executable of the configured revision if it is
+// installed in dir.
+func cached(cfg Config, dir string) (string, bool) {
+	if p, ok := findExecutable(dir); ok {
+		cfg.Logger.Debug("found cached browser", "path", p)
+		return p, true
+	}
+	if cfg.Revision == DefaultRevision {
+		// Earlier versions installed the default revision directly in
+		// the cache directory.
+		if p := getExecutablePath(cfg.CacheDir); p != "" {
+			if info, err := os.Stat(p); err == nil && !info.IsDir() {
+				cfg.Logger.Debug("found cached browser", "path", p)
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+// installDir returns the directory of the configured revision.
+func installDir(cfg Config) (string, error) {
+	platform, _, err := getPlatform()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cfg.CacheDir, platform+"-"+cfg.Revision), nil
+}
+
+// install downloads or copies the archive of the configured revision,
+// verifies it and extracts it into its version directory. The archive is
+// extracted into a temporary directory renamed at the end, so that an
+// interrupted install leaves no partial browser behind.
+func install(ctx context.Context, cfg Config) (string, error) {
+	platform, archive, err := getPlatform()
+	if err != nil {
+		return "", err
+	}
+	dir, err := installDir(cfg)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(cfg.CacheDir, 0755); err != nil {
+		return "", err
+	}
+	want, err := expectedChecksum(ctx, cfg, platform+"/"+cfg.Revision+"/"+archive)
+	if err != nil {
+		return "", err
+	}
+	if want == "" && cfg.RequireChecksum {
+		return "", fmt.Errorf("no checksum known for chromium %s %s", platform, cfg.Revision)
+	}
+
+	src := cfg.Archive
+	if src == "" {
+		// The partial download is kept when it fails, to be resumed by
+		// the next install.
+		src = filepath.Join(cfg.CacheDir, "."+filepath.Base(dir)+".zip.part")
+		url := strings.TrimSuffix(cfg.BaseURL, "/") + "/" + platform + "/" + cfg.Revision + "/" + archive
+		if err := download(ctx, cfg.Logger, url, src, cfg.Progress); err != nil {
+			return "", err
+		}
+		defer os.Remove(src)
+	} else {
+		cfg.Logger.Info("installing browser from archive", "path", src)
+	}
+	sum, err := hashFile(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to read archive: %w", err)
+	}
+	switch {
+	case want == "":
+		cfg.Logger.Warn("chromium archive not verified", "archive", src, "sha256", sum)
+	case !strings.EqualFold(sum, want):
+		return "", fmt.Errorf("checksum mismatch for %s: got sha256 %s, want %s", src, sum, want)
+	}
+
+	// Unzip to a temporary directory next to the final one
+	tmpDir, err := os.MkdirTemp(cfg.CacheDir, ".install-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := unzip(src, tmpDir); err != nil {
+		return "", fmt.Errorf("failed to unzip: %w", err)
+	}
+	executablePath, ok := findExecutable(tmpDir)
+	if !ok {
+		return "", fmt.Errorf("chromium executable not found in %s", src)
+	}
+
+	// Double check permissions on Linux/Darwin
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(executablePath, 0755); err != nil {
+			cfg.Logger.Warn("failed to set executable permissions", "path", executablePath, "error", err)
+		}
+	}
+
+	rel, err := filepath.Rel(tmpDir, executablePath)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		// Another install of the same revision may have finished first.
+		if p, ok := findExecutable(dir); ok {
+			return p, nil
+		}
+		return "", fmt.Errorf("failed to install chromium: %w", err)
+	}
+	cfg.Logger.Info("browser installed", "path", filepath.Join(dir, rel))
+	return filepath.Join(dir, rel), nil
+}
+
+// expectedChecksum returns the checksum of the archive at path relative
+// to the base URL, from the configuration or its manifest. It returns ""
+// if the checksum is unknown.
+func expectedChecksum(ctx context.Context, cfg Config, archive string) (string, error) {
+	if cfg.SHA256 != "" {
+		return cfg.SHA256, nil
+	}
+	if cfg.Manifest == "" {
+		return "", nil
+	}
+	var r io.Reader
+	if strings.HasPrefix(cfg.Manifest, "http://") || strings.HasPrefix(cfg.Manifest, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.Manifest, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch manifest: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("failed to fetch manifest: %s", resp.Status)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(cfg.Manifest)
+		if err != nil {
+			return "", fmt.Errorf("failed to read manifest: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		// sha256sum marks binary files with a leading '*'.
+		if strings.TrimPrefix(fields[1], "*") == archive {
+			return fields[0], nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return "", fmt.Errorf("manifest %s has no checksum for %s", cfg.Manifest, archive)
+}
+
+// findExecutable returns the browser executable installed in dir.
+func findExecutable(dir string) (string, bool) {
+	executablePath := getExecutablePath(dir)
+	if info, err := os.Stat(executablePath); err == nil && !info.IsDir() {
+		return executablePath, true
+	}
+	// Fallback: search for the executable if not found at expected location
+	var foundPath string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || foundPath != "" {
+			return nil
+		}
+		name := strings.ToLower(info.Name())
+		if !info.IsDir() && (name == "chrome" || name == "chrome.exe" || name == "chromium") {
+			foundPath = path
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return foundPath, foundPath != ""
+}
+
+// unzip extracts a zip archive to a destination directory. Entries that
+// would be written outside of dest, directly or through a symbolic
+// link, are rejected.
+func unzip(src, dest string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// Everything is written through root, which refuses paths that
+	// resolve outside dest, e.g. through links extracted earlier.
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	// The top-level directory in the archive is something like "chrome-win",
+	// we want to strip that and place the contents directly in dest.
+	var firstDir string
+
+	for _, f := range r.File {
+		name := strings.ReplaceAll(f.Name, `\`, "/")
+		// Determine the base directory
+		if firstDir == "" {
+			if idx := strings.Index(name, "/"); idx != -1 {
+				firstDir = name[:idx]
+			}
+		}
+
+		rel := strings.TrimPrefix(name, firstDir+"/")
+		if !filepath.IsLocal(filepath.FromSlash(rel)) {
+			if rel == "" || rel == "." {
+				continue
+			}
+			return fmt.Errorf("illegal file path in archive: %s", f.Name)
+		}
+		rel = path.Clean(rel)
+
+		if f.FileInfo().IsDir() {
+			if err := mkdirAll(root, rel); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := mkdirAll(root, path.Dir(rel)); err != nil {
+			return err
+		}
+
+		if f.Mode()&os.ModeSymlink != 0 {
+			if err := extractSymlink(root, f, rel); err != nil {
+				return err
+			}
+			continue
+		}
+
+		outFile, err := root.OpenFile(filepath.FromSlash(rel), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode().Perm())
+		if err != nil {
+			return err
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			outFile.Close()
+			return err
+		}
+
+		_, err = io.Copy(outFile, rc)
+
+		outFile.Close()
+		rc.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mkdirAll creates the directory rel of root and its parents.
+func mkdirAll(root *os.Root, rel string) error {
+	if rel == "." {
+		return nil
+	}
+	dir := ""
+	for _, part := range strings.Split(rel, "/") {
+		dir = path.Join(dir, part)
+		if err := root.Mkdir(filepath.FromSlash(dir), 0755); err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractSymlink creates the symbolic link of an archive entry at rel.
+// Its target must stay inside the archive, and no directory above rel
+// may be a link: targets are checked by name, which only holds if the
+// directories are what their names say.
+func extractSymlink(root *os.Root, f *zip.File, rel string) error {
+	dir := ""
+	for _, part := range strings.Split(path.Dir(rel), "/") {
+		if part == "." {
+			break
+		}
+		dir = path.Join(dir, part)
+		fi, err := root.Lstat(filepath.FromSlash(dir))
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("illegal link in archive: %s is inside link %s", f.Name, dir)
+		}
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	target, err := io.ReadAll(io.LimitReader(rc, 4096))
+	rc.Close()
+	if err != nil {
+		return err
+	}
+	t := string(target)
+	if path.IsAbs(t) || !filepath.IsLocal(filepath.FromSlash(path.Join(path.Dir(rel), t))) {
+		return fmt.Errorf("illegal link target in archive: %s -> %s", f.Name, t)
+	}
+	// The parents of rel are real directories of root, so the link is
+	// created inside it.
+	return os.Symlink(filepath.FromSlash(t), filepath.Join(root.Name(), filepath.FromSlash(rel)))
+}
+
+func getCacheDir() (string, error) {
+	if dir := os.Getenv(CacheDirEnv); dir != "" {
+		return filepath.Join(dir, "browser"), nil
+	}
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "ejspdf", "browser"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("no cache directory: set %s: %w", CacheDirEnv, err)
+	}
+	return filepath.Join(home, ".cache", "ejspdf", "browser"), nil
+}
+
+// getPlatform returns the snapshot platform directory and the archive
+// name of the current system.
+func getPlatform() (platform, archive string, err error) {
+	switch runtime.GOOS {
+	case "windows":
+		// For Windows, the structure is slightly different
+		return "Win_x64", "chrome-win.zip", nil
+	case "darwin":
+		if runtime.GOARCH == "arm64" { // Apple Silicon
+			return "Mac_Arm", "chrome-mac.zip", nil
+		}
+		return "Mac", "chrome-mac.zip", nil // Intel
+	case "linux":
+		if runtime.GOARCH != "amd64" {
+			return "", "", fmt.Errorf("automatic download is only supported for linux/amd64 (current: %s/%s). Please install chromium manually and set ChromePath or ensure it is in your PATH", runtime.GOOS, runtime.GOARCH)
+		}
+		return "Linux_x64", "chrome-linux.zip", nil
+	}
+	return "", "", fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+}
+
+func getExecutablePath(basePath string) string {
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(basePath, "chrome.exe")
+	case "darwin":
+		return filepath.Join(basePath, "Chromium.app", "Contents", "MacOS", "Chromium")
+	case "linux":
+		return filepath.Join(basePath, "chrome")
+	}
+	return ""
+}
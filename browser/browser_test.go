@@ -0,0 +1,467 @@
+package browser
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFindOrDownload(t *testing.T) {
+	// 1. Run the function
+	path, err := FindOrDownload(context.Background(), Config{})
+
+	// 2. Assert results
+	if err != nil {
+		t.Fatalf("FindOrDownload failed: %v", err)
+	}
+
+	if path == "" {
+		t.Fatal("FindOrDownload returned empty path")
+	}
+
+	// 3. Verify the file actually exists
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Returned path does not exist: %s, error: %v", path, err)
+	}
+
+	if info.IsDir() {
+		t.Fatalf("Returned path is a directory, expected file: %s", path)
+	}
+
+	t.Logf("Success! Found/Downloaded browser at: %s", path)
+}
+
+// zipEntry is a file of a test archive; a link is a symbolic link to
+// body.
+type zipEntry struct {
+	name, body string
+	link       bool
+}
+
+func makeZip(t *testing.T, entries ...zipEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		h.SetMode(0644)
+		if e.link {
+			h.SetMode(os.ModeSymlink | 0777)
+		}
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e.body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// fakeArchive returns an archive laid out like a Chromium snapshot.
+func fakeArchive(t *testing.T) []byte {
+	t.Helper()
+	exe := filepath.Base(getExecutablePath(""))
+	return makeZip(t,
+		zipEntry{name: "chrome-fake/" + exe, body: "#!/bin/sh\n"},
+		zipEntry{name: "chrome-fake/resources.pak", body: "pak"},
+	)
+}
+
+// serveArchive serves archive at the snapshot path of the current
+// platform and counts the downloads.
+func serveArchive(t *testing.T, archive []byte) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	platform, name, err := getPlatform()
+	if err != nil {
+		t.Skip(err)
+	}
+	var downloads atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/"+platform+"/"+DefaultRevision+"/"+name {
+			http.NotFound(w, r)
+			return
+		}
+		downloads.Add(1)
+		w.Write(archive)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &downloads
+}
+
+func sum(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// assertClean fails if a temporary download or install was left in dir.
+func assertClean(t *testing.T, dir string) {
+	t.Helper()
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			t.Errorf("temporary file %s left in cache", e.Name())
+		}
+	}
+}
+
+func TestInstall(t *testing.T) {
+	archive := fakeArchive(t)
+	srv, downloads := serveArchive(t, archive)
+	cfg := Config{BaseURL: srv.URL, SHA256: sum(archive), CacheDir: t.TempDir()}
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := install(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, _ := installDir(cfg)
+	if filepath.Dir(p) != dir && !strings.HasPrefix(p, dir+string(filepath.Separator)) {
+		t.Errorf("browser installed at %s, want inside %s", p, dir)
+	}
+	if b, err := os.ReadFile(p); err != nil || string(b) != "#!/bin/sh\n" {
+		t.Errorf("executable not extracted: %v", err)
+	}
+	assertClean(t, cfg.CacheDir)
+
+	// A second lookup is served from the cache.
+	if cached, ok := findExecutable(dir); !ok || cached != p {
+		t.Errorf("cached browser %q, want %q", cached, p)
+	}
+	if n := downloads.Load(); n != 1 {
+		t.Errorf("downloaded %d times, want 1", n)
+	}
+}
+
+func TestInstallChecksum(t *testing.T) {
+	archive := fakeArchive(t)
+	srv, _ := serveArchive(t, archive)
+	platform, name, _ := getPlatform()
+
+	t.Run("mismatch", func(t *testing.T) {
+		cfg, _ := Config{BaseURL: srv.URL, SHA256: strings.Repeat("0", 64), CacheDir: t.TempDir()}.withDefaults()
+		if _, err := install(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Fatalf("expected a checksum mismatch, got %v", err)
+		}
+		dir, _ := installDir(cfg)
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Error("install directory created for an invalid archive")
+		}
+		assertClean(t, cfg.CacheDir)
+	})
+
+	t.Run("manifest", func(t *testing.T) {
+		manifest := filepath.Join(t.TempDir(), "SHA256SUMS")
+		content := "# chromium snapshots\n" +
+			strings.Repeat("1", 64) + "  Other/1/other.zip\n" +
+			sum(archive) + " *" + platform + "/" + DefaultRevision + "/" + name + "\n"
+		if err := os.WriteFile(manifest, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, _ := Config{BaseURL: srv.URL, Manifest: manifest, CacheDir: t.TempDir()}.withDefaults()
+		if _, err := install(context.Background(), cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg.Revision = "1"
+		if _, err := install(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "no checksum") {
+			t.Errorf("expected a missing manifest entry error, got %v", err)
+		}
+	})
+
+	t.Run("required", func(t *testing.T) {
+		cfg, _ := Config{BaseURL: srv.URL, RequireChecksum: true, CacheDir: t.TempDir()}.withDefaults()
+		if _, err := install(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "no checksum") {
+			t.Errorf("expected an unknown checksum error, got %v", err)
+		}
+	})
+
+	if _, err := (Config{Revision: "../x"}).withDefaults(); err == nil {
+		t.Error("expected an error for an invalid revision")
+	}
+}
+
+func TestUnzipRejectsEscapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []zipEntry
+	}{
+		{"parent", []zipEntry{{name: "chrome/../../evil", body: "x"}}},
+		{"absolute", []zipEntry{{name: "/tmp/evil", body: "x"}}},
+		{"link", []zipEntry{{name: "chrome/lib", body: "../../etc", link: true}}},
+		{"absolute link", []zipEntry{{name: "chrome/lib", body: "/etc/passwd", link: true}}},
+		// Each target looks local by name, but x/y is the root, so
+		// x/y/z would be its parent.
+		{"chained links", []zipEntry{
+			{name: "chrome/x/", body: ""},
+			{name: "chrome/x/y", body: "..", link: true},
+			{name: "chrome/x/y/z", body: "..", link: true},
+			{name: "chrome/x/y/z/evil", body: "x"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := filepath.Join(t.TempDir(), "a.zip")
+			archive := makeZip(t, append([]zipEntry{{name: "chrome/chrome", body: "x"}}, tt.entries...)...)
+			if err := os.WriteFile(src, archive, 0644); err != nil {
+				t.Fatal(err)
+			}
+			parent := t.TempDir()
+			dest := filepath.Join(parent, "out")
+			if err := unzip(src, dest); err == nil {
+				t.Error("expected an error for an escaping entry")
+			}
+			if _, err := os.Lstat(filepath.Join(parent, "evil")); err == nil {
+				t.Error("file written outside the destination")
+			}
+		})
+	}
+
+	// Links inside the archive are kept.
+	src := filepath.Join(t.TempDir(), "a.zip")
+	archive := makeZip(t,
+		zipEntry{name: "chrome/lib/libfoo.so.1", body: "so"},
+		zipEntry{name: "chrome/lib/libfoo.so", body: "libfoo.so.1", link: true},
+	)
+	if err := os.WriteFile(src, archive, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	if err := unzip(src, dest); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dest, "lib", "libfoo.so")); err != nil || string(b) != "so" {
+		t.Errorf("link not extracted: %v", err)
+	}
+}
+
+func TestInstallOffline(t *testing.T) {
+	archive := fakeArchive(t)
+
+	t.Run("archive", func(t *testing.T) {
+		src := filepath.Join(t.TempDir(), "chrome.zip")
+		if err := os.WriteFile(src, archive, 0644); err != nil {
+			t.Fatal(err)
+		}
+		// The base URL is unreachable: the local archive is used.
+		cfg := Config{BaseURL: "http://127.0.0.1:1", Archive: src, SHA256: sum(archive), CacheDir: t.TempDir()}
+		p, err := Install(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again, err := Install(context.Background(), cfg); err != nil || again != p {
+			t.Errorf("second install returned %q, %v; want the cached %q", again, err, p)
+		}
+
+		cfg.CacheDir, cfg.SHA256 = t.TempDir(), strings.Repeat("0", 64)
+		if _, err := Install(context.Background(), cfg); err == nil {
+			t.Error("expected a checksum error for a local archive")
+		}
+	})
+
+	t.Run("mirror", func(t *testing.T) {
+		srv, downloads := serveArchive(t, archive)
+		t.Setenv(MirrorEnv, srv.URL)
+		if _, err := Install(context.Background(), Config{CacheDir: t.TempDir()}); err != nil {
+			t.Fatal(err)
+		}
+		if downloads.Load() != 1 {
+			t.Error("archive not downloaded from the mirror")
+		}
+	})
+}
+
+func TestInstallConcurrent(t *testing.T) {
+	archive := fakeArchive(t)
+	srv, downloads := serveArchive(t, archive)
+	cfg := Config{BaseURL: srv.URL, SHA256: sum(archive), CacheDir: t.TempDir()}
+
+	var wg sync.WaitGroup
+	paths := make([]string, 10)
+	errs := make([]error, 10)
+	for i := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			paths[i], errs[i] = Install(context.Background(), cfg)
+		}()
+	}
+	wg.Wait()
+	for i := range paths {
+		if errs[i] != nil || paths[i] != paths[0] {
+			t.Errorf("install %d: %q, %v; want %q", i, paths[i], errs[i], paths[0])
+		}
+	}
+	if n := downloads.Load(); n != 1 {
+		t.Errorf("downloaded %d times, want 1", n)
+	}
+	assertClean(t, cfg.CacheDir)
+}
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.lock")
+	unlock, err := lockFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A held lock blocks until the context ends.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*lockPoll)
+	defer cancel()
+	if _, err := lockFile(ctx, path); err == nil {
+		t.Fatal("expected the lock to be held")
+	}
+
+	// A released lock can be taken.
+	unlock()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("lock file not removed")
+	}
+	unlock, err = lockFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	// A lock that is no longer refreshed is taken over.
+	old := time.Now().Add(-2 * lockStale)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	release, err := lockFile(ctx, path)
+	if err != nil {
+		t.Fatalf("stale lock not taken over: %v", err)
+	}
+	release()
+}
+
+func TestBreakLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.lock")
+	if err := os.WriteFile(path, []byte("1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * lockStale)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	stale, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process took the lock over after it was found stale.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	breakLock(path, stale)
+	if b, err := os.ReadFile(path); err != nil || string(b) != "2" {
+		t.Fatalf("newer lock not kept: %q, %v", b, err)
+	}
+
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err = os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	breakLock(path, stale)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("stale lock not removed")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 0 {
+		t.Errorf("files left behind: %v", entries)
+	}
+}
+
+func TestFlightCancel(t *testing.T) {
+	var f flight
+	started := make(chan struct{})
+	release := make(chan struct{})
+	canceled := make(chan struct{})
+	fn := func(ctx context.Context) (string, error) {
+		close(started)
+		select {
+		case <-release:
+			return "chrome", nil
+		case <-ctx.Done():
+			close(canceled)
+			return "", ctx.Err()
+		}
+	}
+
+	// A waiter whose context ends stops waiting; the call goes on for the
+	// other one.
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := f.do(ctx, "k", fn)
+		errc <- err
+	}()
+	<-started
+	type result struct {
+		path string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		p, err := f.do(context.Background(), "k", fn)
+		done <- result{p, err}
+	}()
+	for {
+		f.mu.Lock()
+		n := f.calls["k"].waiters
+		f.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the waiter's context error, got %v", err)
+	}
+	close(release)
+	if r := <-done; r.path != "chrome" || r.err != nil {
+		t.Fatalf("got %q, %v", r.path, r.err)
+	}
+
+	// The call is canceled once nobody waits for it.
+	started, canceled = make(chan struct{}), make(chan struct{})
+	release = make(chan struct{})
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		_, err := f.do(ctx, "k", fn)
+		errc <- err
+	}()
+	<-started
+	cancel()
+	<-errc
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("abandoned call not canceled")
+	}
+}
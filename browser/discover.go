@@ -0,0 +1,162 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// versionTimeout bounds the time a browser has to print its version.
+const versionTimeout = 5 * time.Second
+
+// Source tells where a browser was found.
+type Source string
+
+// Browser sources, in the order FindOrDownload considers them.
+const (
+	SourceEnv       Source = "env"
+	SourceInstalled Source = "installed"
+	SourceCache     Source = "cache"
+)
+
+// Browser is a browser found by Discover.
+type Browser struct {
+	// Path is the executable.
+	Path string
+	// Version is the version the browser reports, e.g.
+	// "Chromium 109.0.5414.0", or "" if it could not be queried.
+	Version string
+	// Major is the major version, e.g. 109, or 0 if unknown.
+	Major int
+	// Source tells where the browser was found.
+	Source Source
+	// Revision is the snapshot revision of a cached browser.
+	Revision string
+}
+
+// Discover returns the browsers available to FindOrDownload, in its
+// order of preference: $CHROME_PATH, the installed candidates, then the
+// revisions installed in the cache. Unlike FindOrDownload, it never
+// downloads a browser.
+func Discover(ctx context.Context, cfg Config) ([]Browser, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	var found []Browser
+	seen := map[string]bool{}
+	add := func(b Browser) {
+		if seen[b.Path] {
+			return
+		}
+		seen[b.Path] = true
+		b.Version, _ = Version(ctx, b.Path)
+		b.Major, _ = MajorVersion(b.Version)
+		found = append(found, b)
+	}
+
+	if p := os.Getenv(PathEnv); p != "" {
+		if p, err := exec.LookPath(p); err == nil {
+			add(Browser{Path: p, Source: SourceEnv})
+		}
+	}
+	for _, name := range cfg.candidates() {
+		if p, err := exec.LookPath(name); err == nil {
+			add(Browser{Path: p, Source: SourceInstalled})
+		}
+	}
+
+	platform, _, err := getPlatform()
+	if err != nil {
+		return found, nil
+	}
+	entries, _ := os.ReadDir(cfg.CacheDir)
+	for _, e := range entries {
+		rev, ok := strings.CutPrefix(e.Name(), platform+"-")
+		if !e.IsDir() || !ok {
+			continue
+		}
+		if p, ok := findExecutable(filepath.Join(cfg.CacheDir, e.Name())); ok {
+			add(Browser{Path: p, Source: SourceCache, Revision: rev})
+		}
+	}
+	// The default revision used to be installed in the cache directory.
+	if p := getExecutablePath(cfg.CacheDir); p != "" {
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			add(Browser{Path: p, Source: SourceCache, Revision: DefaultRevision})
+		}
+	}
+	return found, nil
+}
+
+// Version runs the browser at path with --version and returns what it
+// prints, e.g. "Google Chrome 120.0.6099.109".
+func Version(ctx context.Context, path string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, versionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to query browser version: %w", err)
+	}
+	v := strings.TrimSpace(string(out))
+	if v == "" {
+		return "", fmt.Errorf("browser %s printed no version", path)
+	}
+	return v, nil
+}
+
+// candidates returns the browsers looked for before the cache.
+func (cfg Config) candidates() []string {
+	if cfg.Candidates != nil {
+		return cfg.Candidates
+	}
+	return findChromePaths()
+}
+
+func findChromePaths() []string {
+	switch runtime.GOOS {
+	case "windows":
+		return []string{
+			"chrome",
+			"msedge",
+			"chromium",
+		}
+	case "darwin":
+		return []string{
+			"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+			"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+			"/Applications/Chromium.app/Contents/MacOS/Chromium",
+		}
+	case "linux":
+		return []string{
+			"google-chrome",
+			"microsoft-edge",
+			"chromium-browser",
+			"chromium",
+		}
+	default:
+		return []string{}
+	}
+}
+
+// MajorVersion returns the major version of a browser version string,
+// such as "Chromium 109.0.5414.0" from --version or
+// "HeadlessChrome/120.0.6099.109" from the DevTools protocol.
+func MajorVersion(version string) (int, bool) {
+	for _, f := range strings.FieldsFunc(version, func(r rune) bool { return r == ' ' || r == '/' }) {
+		parts := strings.Split(f, ".")
+		if len(parts) < 2 {
+			continue
+		}
+		if major, err := strconv.Atoi(parts[0]); err == nil && major > 0 {
+			return major, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,108 @@
+package browser
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeBrowser writes an executable script printing version to dir.
+func fakeBrowser(t *testing.T, dir, name, version string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake browsers are shell scripts")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte("#!/bin/sh\necho '"+version+"'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDiscover(t *testing.T) {
+	platform, _, err := getPlatform()
+	if err != nil {
+		t.Skip(err)
+	}
+	bin := t.TempDir()
+	env := fakeBrowser(t, bin, "chrome-env", "Google Chrome 120.0.6099.109")
+	installed := fakeBrowser(t, bin, "chromium", "Chromium 118.0.5993.88")
+
+	cache := t.TempDir()
+	t.Setenv(CacheDirEnv, cache)
+	t.Setenv(PathEnv, env)
+	exe := filepath.Base(getExecutablePath(""))
+	rev := fakeBrowser(t, filepath.Join(cache, "browser", platform+"-1000"), exe, "Chromium 109.0.5414.0")
+
+	cfg := Config{Candidates: []string{"missing-browser", installed, env}}
+	found, err := Discover(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Browser{
+		{Path: env, Version: "Google Chrome 120.0.6099.109", Major: 120, Source: SourceEnv},
+		{Path: installed, Version: "Chromium 118.0.5993.88", Major: 118, Source: SourceInstalled},
+		{Path: rev, Version: "Chromium 109.0.5414.0", Major: 109, Source: SourceCache, Revision: "1000"},
+	}
+	if len(found) != len(want) {
+		t.Fatalf("found %+v, want %+v", found, want)
+	}
+	for i := range want {
+		if found[i] != want[i] {
+			t.Errorf("browser %d = %+v, want %+v", i, found[i], want[i])
+		}
+	}
+
+	// FindOrDownload prefers $CHROME_PATH, then the candidates.
+	if p, err := FindOrDownload(context.Background(), cfg); err != nil || p != env {
+		t.Errorf("FindOrDownload = %q, %v; want %q", p, err, env)
+	}
+	t.Setenv(PathEnv, "")
+	if p, err := FindOrDownload(context.Background(), cfg); err != nil || p != installed {
+		t.Errorf("FindOrDownload = %q, %v; want %q", p, err, installed)
+	}
+	t.Setenv(PathEnv, filepath.Join(bin, "missing"))
+	if _, err := FindOrDownload(context.Background(), cfg); err == nil {
+		t.Error("expected an error for a missing $CHROME_PATH")
+	}
+}
+
+func TestCacheDir(t *testing.T) {
+	t.Setenv(CacheDirEnv, "")
+	t.Setenv("XDG_CACHE_HOME", filepath.FromSlash("/xdg"))
+	if dir, _ := getCacheDir(); dir != filepath.FromSlash("/xdg/ejspdf/browser") {
+		t.Errorf("cache dir = %s with $XDG_CACHE_HOME", dir)
+	}
+	t.Setenv(CacheDirEnv, filepath.FromSlash("/cache"))
+	if dir, _ := getCacheDir(); dir != filepath.FromSlash("/cache/browser") {
+		t.Errorf("cache dir = %s with $%s", dir, CacheDirEnv)
+	}
+	cfg, err := Config{CacheDir: "custom"}.withDefaults()
+	if err != nil || cfg.CacheDir != "custom" {
+		t.Errorf("configured cache dir = %s, %v", cfg.CacheDir, err)
+	}
+}
+
+func TestMajorVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		major   int
+	}{
+		{"Google Chrome 120.0.6099.109", 120},
+		{"HeadlessChrome/109.0.5414.0", 109},
+		{"Chromium 118.0.5993.88 built on Debian 12", 118},
+		{"Microsoft Edge 121.0.2277.83 ", 121},
+		{"Chrome", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if major, ok := MajorVersion(tt.version); major != tt.major || ok != (tt.major > 0) {
+			t.Errorf("MajorVersion(%q) = %d, %v; want %d", tt.version, major, ok, tt.major)
+		}
+	}
+}
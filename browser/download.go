@@ -0,0 +1,183 @@
+package browser
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// downloadAttempts is the number of times a download is tried, and
+// downloadBackoff the delay before the first retry. The delay doubles on
+// each retry, and each retry resumes the partial file.
+const (
+	downloadAttempts = 4
+	downloadBackoff  = 500 * time.Millisecond
+)
+
+// ProgressFunc reports the progress of a download: the bytes downloaded
+// so far, including those of an earlier attempt that is being resumed,
+// and the size of the archive, or -1 if unknown.
+type ProgressFunc func(downloaded, total int64)
+
+// download downloads the file at url to path. A partial file left at
+// path by an earlier attempt is resumed with an HTTP Range request, and
+// failed attempts are retried with backoff.
+func download(ctx context.Context, logger *slog.Logger, url, path string, progress ProgressFunc) error {
+	logger.Info("downloading browser", "url", url)
+	delay := downloadBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := downloadOnce(ctx, url, path, progress)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt == downloadAttempts {
+			return err
+		}
+		logger.Warn("browser download failed, retrying", "attempt", attempt, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// downloadOnce continues the download of url to path. It reports whether
+// a failure may be retried.
+func downloadOnce(ctx context.Context, url, path string, progress ProgressFunc) (bool, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return false, fmt.Errorf("failed to create download file: %w", err)
+	}
+	defer f.Close()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+
+	total := int64(-1)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The server ignored the range: start over.
+		if offset > 0 {
+			if err := f.Truncate(0); err != nil {
+				return false, err
+			}
+			if offset, err = f.Seek(0, io.SeekStart); err != nil {
+				return false, err
+			}
+		}
+		if resp.ContentLength >= 0 {
+			total = resp.ContentLength
+		}
+	case http.StatusPartialContent:
+		start, size, ok := contentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			f.Truncate(0)
+			return true, fmt.Errorf("unexpected content range %q", resp.Header.Get("Content-Range"))
+		}
+		total = size
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is complete, or does not match the archive.
+		if _, size, ok := contentRange(resp.Header.Get("Content-Range")); ok && size == offset {
+			return false, nil
+		}
+		f.Truncate(0)
+		return true, fmt.Errorf("bad status: %s", resp.Status)
+	default:
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	w := io.Writer(f)
+	if progress != nil {
+		progress(offset, total)
+		w = &progressWriter{w: f, n: offset, total: total, fn: progress}
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return true, fmt.Errorf("failed to download: %w", err)
+	}
+	return false, nil
+}
+
+// contentRange parses a Content-Range header such as "bytes 100-199/200"
+// or "bytes */200". The size is -1 if unknown.
+func contentRange(h string) (start, size int64, ok bool) {
+	r, found := strings.CutPrefix(h, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, sz, found := strings.Cut(r, "/")
+	if !found {
+		return 0, 0, false
+	}
+	size = -1
+	if sz != "*" {
+		var err error
+		if size, err = strconv.ParseInt(sz, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if rng == "*" {
+		return 0, size, true
+	}
+	first, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	return start, size, err == nil
+}
+
+// progressWriter reports the bytes written through it.
+type progressWriter struct {
+	w        io.Writer
+	n, total int64
+	fn       ProgressFunc
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.n += int64(n)
+	p.fn(p.n, p.total)
+	return n, err
+}
+
+// hashFile returns the hex SHA-256 of the file at path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,106 @@
+package browser
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownloadResume(t *testing.T) {
+	body := bytes.Repeat([]byte("chromium"), 4096)
+	var requests atomic.Int32
+	var ranged atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// Drop the connection halfway through the first response.
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write(body[:len(body)/2])
+			panic(http.ErrAbortHandler)
+		}
+		if r.Header.Get("Range") != "" {
+			ranged.Store(true)
+		}
+		http.ServeContent(w, r, "chrome.zip", time.Time{}, bytes.NewReader(body))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "chrome.zip.part")
+	var last, total int64
+	err := download(context.Background(), slog.Default(), srv.URL, path, func(n, size int64) {
+		if n < last {
+			t.Errorf("progress went back from %d to %d", last, n)
+		}
+		last, total = n, size
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, body) {
+		t.Errorf("downloaded %d bytes, want %d", len(got), len(body))
+	}
+	if !ranged.Load() {
+		t.Error("retry did not resume the partial file")
+	}
+	if last != int64(len(body)) || total != int64(len(body)) {
+		t.Errorf("progress ended at %d/%d, want %d", last, total, len(body))
+	}
+
+	// A complete partial file is not downloaded again.
+	if err := download(context.Background(), slog.Default(), srv.URL, path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, body) {
+		t.Error("complete file changed")
+	}
+}
+
+func TestDownloadErrors(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "chrome.zip.part")
+	if err := download(context.Background(), slog.Default(), srv.URL, path, nil); err == nil {
+		t.Fatal("expected an error for a missing archive")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("missing archive requested %d times, want 1", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := download(ctx, slog.Default(), srv.URL, path, nil); err == nil {
+		t.Error("expected an error for a canceled context")
+	}
+}
+
+func TestContentRange(t *testing.T) {
+	tests := []struct {
+		h           string
+		start, size int64
+		ok          bool
+	}{
+		{"bytes 100-199/200", 100, 200, true},
+		{"bytes 0-9/*", 0, -1, true},
+		{"bytes */200", 0, 200, true},
+		{"bytes 1-2", 0, 0, false},
+		{"items 0-1/2", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, size, ok := contentRange(tt.h)
+		if start != tt.start || size != tt.size || ok != tt.ok {
+			t.Errorf("contentRange(%q) = %d, %d, %v; want %d, %d, %v", tt.h, start, size, ok, tt.start, tt.size, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The lock file of an install is refreshed every lockRefresh by its
+// holder, and taken over once it is older than lockStale, e.g. when the
+// installing process was killed. Waiters check it every lockPoll.
+const (
+	lockRefresh = 10 * time.Second
+	lockStale   = time.Minute
+	lockPoll    = 200 * time.Millisecond
+)
+
+// installs deduplicates concurrent installs within the process.
+var installs flight
+
+// flight runs a function once per key at a time; concurrent callers with
+// the same key wait for the running call and share its result.
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done    chan struct{}
+	path    string
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// do runs fn for key unless a call is already running, and waits for its
+// result or for ctx to end. fn does not run with the context of any one
+// caller: its context keeps the values of the first caller's, and is
+// canceled once every caller has stopped waiting.
+func (f *flight) do(ctx context.Context, key string, fn func(context.Context) (string, error)) (string, error) {
+	f.mu.Lock()
+	c, ok := f.calls[key]
+	if !ok {
+		if f.calls == nil {
+			f.calls = make(map[string]*flightCall)
+		}
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &flightCall{done: make(chan struct{}), cancel: cancel}
+		f.calls[key] = c
+		go func() {
+			c.path, c.err = fn(fctx)
+			cancel()
+			f.forget(key, c)
+			close(c.done)
+		}()
+	}
+	c.waiters++
+	f.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.path, c.err
+	case <-ctx.Done():
+	}
+	f.mu.Lock()
+	c.waiters--
+	abandoned := c.waiters == 0
+	f.mu.Unlock()
+	if abandoned {
+		// Later callers start over rather than join a canceled call.
+		c.cancel()
+		f.forget(key, c)
+	}
+	return "", fmt.Errorf("waiting for browser install: %w", ctx.Err())
+}
+
+// forget removes call c of key, if it is still the current one.
+func (f *flight) forget(key string, c *flightCall) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.calls[key] == c {
+		delete(f.calls, key)
+	}
+}
+
+// lockFile takes the lock file at path, waiting while another process
+// holds it. The returned function releases the lock.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.WriteString(strconv.Itoa(os.Getpid()))
+			info, err := f.Stat()
+			f.Close()
+			if err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to create lock file: %w", err)
+			}
+			return holdLock(path, info), nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lockStale {
+			// The holder stopped refreshing the lock: take it over.
+			breakLock(path, info)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for another browser install: %w", ctx.Err())
+		case <-time.After(lockPoll):
+		}
+	}
+}
+
+// breakLock removes the stale lock file at path, described by stale.
+// Removing path directly could remove a lock that another process has
+// just taken over, or one its holder refreshed since, so the file is
+// first renamed, which only one process can do, and put back unless it
+// is still the stale lock.
+func breakLock(path string, stale os.FileInfo) {
+	moved := fmt.Sprintf("%s.%d.%d", path, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(path, moved); err != nil {
+		return
+	}
+	if info, err := os.Stat(moved); err == nil && (!os.SameFile(info, stale) || time.Since(info.ModTime()) <= lockStale) {
+		// Link does not replace a lock taken in the meantime.
+		os.Link(moved, path)
+	}
+	os.Remove(moved)
+}
+
+// holdLock refreshes the lock file at path, described by info, until
+// the returned function is called, which removes it. A lock file that
+// was taken over is left alone.
+func holdLock(path string, info os.FileInfo) func() {
+	ours := func() bool {
+		cur, err := os.Stat(path)
+		return err == nil && os.SameFile(cur, info)
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(lockRefresh)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-t.C:
+				if ours() {
+					os.Chtimes(path, now, now)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+		if ours() {
+			os.Remove(path)
+		}
+	}
+}
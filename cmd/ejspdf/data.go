@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadData reads the template data from the JSON or YAML file at path,
+// or from stdin if path is "-". format is "json", "yaml" or "" to choose
+// from the file extension, JSON by default.
+func loadData(path, format string, stdin io.Reader) (any, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := readInput(path, stdin)
+	if err != nil {
+		return nil, fmt.Errorf("read data: %w", err)
+	}
+
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			format = "yaml"
+		default:
+			format = "json"
+		}
+	}
+	var data any
+	switch format {
+	case "json":
+		if err := json.Unmarshal(b, &data); err != nil {
+			return nil, fmt.Errorf("parse data %s: %w", path, err)
+		}
+	case "yaml":
+		if data, err = decodeYAML(b); err != nil {
+			return nil, fmt.Errorf("parse data %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported data format %q", format)
+	}
+	return data, nil
+}
+
+// decodeYAML decodes a single YAML document like JSON data: mappings
+// become map[string]any, and timestamps are kept as the strings they are
+// written as.
+func decodeYAML(b []byte) (any, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	var doc yaml.Node
+	if err := dec.Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := dec.Decode(new(yaml.Node)); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("multiple documents are not supported")
+	}
+	untagTimestamps(&doc)
+	var data any
+	if err := doc.Decode(&data); err != nil {
+		return nil, err
+	}
+	return stringKeys(data), nil
+}
+
+// untagTimestamps makes the timestamps under n plain strings.
+func untagTimestamps(n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!timestamp" {
+		n.Tag = "!!str"
+	}
+	for _, c := range n.Content {
+		untagTimestamps(c)
+	}
+}
+
+// stringKeys converts the mappings in v with non-string keys, such as
+// numbers, to map[string]any.
+func stringKeys(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = stringKeys(e)
+		}
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return m
+	case []any:
+		for i, e := range v {
+			v[i] = stringKeys(e)
+		}
+	}
+	return v
+}
+
+// readInput reads the file at path, or stdin if path is "-".
+func readInput(path string, stdin io.Reader) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadDataYAML(t *testing.T) {
+	src := `# invoice data
+customer:
+  name: "John \"JJ\" Smith"
+  phone: '081-234 5678'
+  vip: true
+  notes: ~
+issued: 2024-01-02
+items:
+- name: Widget
+  qty: 2
+  price: 10.5
+tags: [urgent, "net 30", 7]
+vat: {7: standard}
+address: |
+  1 Main Road
+  Bangkok
+`
+	path := writeFile(t, t.TempDir(), "order.yml", src)
+	got, err := loadData(path, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"customer": map[string]any{
+			"name":  `John "JJ" Smith`,
+			"phone": "081-234 5678",
+			"vip":   true,
+			"notes": nil,
+		},
+		"issued":  "2024-01-02",
+		"items":   []any{map[string]any{"name": "Widget", "qty": 2, "price": 10.5}},
+		"tags":    []any{"urgent", "net 30", 7},
+		"vat":     map[string]any{"7": "standard"},
+		"address": "1 Main Road\nBangkok\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loadData =\n%#v\nwant\n%#v", got, want)
+	}
+
+	// The format flag overrides the extension.
+	got, err = loadData("-", "yaml", strings.NewReader("a: 1\n"))
+	if err != nil || !reflect.DeepEqual(got, map[string]any{"a": 1}) {
+		t.Errorf("loadData from stdin = %#v, %v", got, err)
+	}
+	if got, err := loadData("-", "yaml", strings.NewReader("")); got != nil || err != nil {
+		t.Errorf("empty data = %#v, %v", got, err)
+	}
+}
+
+func TestLoadDataErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct{ name, src, format, err string }{
+		{"data.yaml", "a: 1\n  b: 2\n", "", "line 2"},
+		{"data.yaml", "a: 1\n---\nb: 2\n", "", "multiple documents"},
+		{"data.json", "{", "", "parse data"},
+		{"data.json", "{}", "toml", "unsupported data format"},
+	}
+	for _, tt := range tests {
+		path := writeFile(t, dir, tt.name, tt.src)
+		if _, err := loadData(path, tt.format, nil); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("loadData(%q) error = %v, want %q", tt.src, err, tt.err)
+		}
+	}
+	if _, err := loadData(filepath.Join(dir, "missing.json"), "", nil); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/yodsakorn-so/ejspdf"
+)
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// parseArgs parses flags that may come before or after the positional
+// arguments, as in "render invoice.ejs -o invoice.pdf".
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// browserFlags are the Chrome and logging flags shared by the commands.
+type browserFlags struct {
+	opt                    *ejspdf.Options
+	chromeFlags, chromeEnv stringList
+	logLevel, logFormat    string
+}
+
+// addBrowserFlags defines the browser flags setting opt on fs, with
+// logLevel as the default log level.
+func addBrowserFlags(fs *flag.FlagSet, opt *ejspdf.Options, logLevel string) *browserFlags {
+	b := &browserFlags{opt: opt}
+	fs.StringVar(&opt.ChromePath, "chrome-path", "", "Chrome or Chromium executable `path` (default: found or downloaded)")
+	fs.StringVar(&opt.RemoteURL, "remote-url", "", "DevTools `url` of a running Chrome, e.g. ws://chrome:9222")
+	fs.Var(&b.chromeFlags, "chrome-flag", "extra Chrome command-line `flag`, e.g. --lang=th-TH (repeatable)")
+	fs.Var(&b.chromeEnv, "chrome-env", "extra Chrome environment `NAME=value` (repeatable)")
+	fs.BoolVar(&opt.Sandbox, "sandbox", false, "keep Chrome's sandbox enabled")
+	fs.BoolVar(&opt.StrictBrowserVersion, "strict-browser-version", false, "fail if Chrome is too old for a requested option")
+	fs.StringVar(&opt.Browser.Revision, "browser-revision", "", "Chromium `revision` to download if no browser is found")
+	fs.StringVar(&opt.Browser.BaseURL, "browser-mirror", "", "`url` of the Chromium snapshot server")
+	fs.StringVar(&opt.Browser.Archive, "browser-archive", "", "install Chromium from this local `zip` instead of downloading it")
+	fs.StringVar(&opt.Browser.SHA256, "browser-sha256", "", "expected SHA-256 `checksum` of the Chromium archive")
+	fs.StringVar(&opt.Browser.Manifest, "browser-manifest", "", "checksum manifest `file or url` of the Chromium archives")
+	fs.BoolVar(&opt.Browser.RequireChecksum, "require-checksum", false, "refuse to install a Chromium archive with no known checksum")
+	fs.StringVar(&opt.Browser.CacheDir, "cache-dir", "", "`directory` of the downloaded browsers")
+	fs.StringVar(&b.logLevel, "log-level", logLevel, "log `level`: debug, info, warn or error")
+	fs.StringVar(&b.logFormat, "log-format", "text", "log `format`: text or json")
+	return b
+}
+
+// apply sets the options that need the parsed flags: the Chrome flags
+// and environment, and a logger writing to stderr.
+func (b *browserFlags) apply(stderr io.Writer) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(b.logLevel)); err != nil {
+		return usageError("invalid log level %q", b.logLevel)
+	}
+	hopt := &slog.HandlerOptions{Level: level}
+	switch b.logFormat {
+	case "text":
+		b.opt.Logger = slog.New(slog.NewTextHandler(stderr, hopt))
+	case "json":
+		b.opt.Logger = slog.New(slog.NewJSONHandler(stderr, hopt))
+	default:
+		return usageError("invalid log format %q", b.logFormat)
+	}
+	b.opt.ChromeFlags, b.opt.ChromeEnv = b.chromeFlags, b.chromeEnv
+	return nil
+}
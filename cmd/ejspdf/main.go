@@ -0,0 +1,107 @@
+// Command ejspdf renders EJS templates to PDF from the command line.
+//
+// Usage:
+//
+//	ejspdf render [flags] TEMPLATE
+//	ejspdf serve --templates DIR [flags]
+//
+// For example:
+//
+//	ejspdf render invoice.ejs --data invoice.json -o invoice.pdf
+//	cat data.yaml | ejspdf render invoice.ejs --data - --data-format yaml -o - > invoice.pdf
+//	ejspdf serve --templates ./templates --addr :8080 --max-concurrent 4
+//
+// Run "ejspdf render -h" or "ejspdf serve -h" for the flags. The serve
+// command runs the HTTP service of package server until interrupted.
+//
+// Exit status is 0 on success, 1 if rendering failed, 2 for invalid
+// usage, 3 if an input file could not be read or parsed, 4 if the
+// timeout expired and 5 if the document does not conform to the
+// requested PDF/A level.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Exit codes.
+const (
+	exitOK          = 0
+	exitFailure     = 1
+	exitUsage       = 2
+	exitInput       = 3
+	exitTimeout     = 4
+	exitConformance = 5
+)
+
+// exitError is an error with the exit code it causes.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+func usageError(format string, args ...any) error {
+	return &exitError{exitUsage, fmt.Errorf(format, args...)}
+}
+
+func inputError(err error) error {
+	return &exitError{exitInput, err}
+}
+
+const usage = `Usage: ejspdf <command> [arguments]
+
+Commands:
+  render    render an EJS template to PDF
+  serve     serve a directory of templates over HTTP
+
+Run "ejspdf <command> -h" for the flags of a command.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run runs the command line args and returns the exit code.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	var err error
+	switch args[0] {
+	case "render":
+		err = runRender(ctx, args[1:], stdin, stdout, stderr)
+	case "serve":
+		err = runServe(ctx, args[1:], stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "ejspdf: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+	if err == nil {
+		return exitOK
+	}
+	if errors.Is(err, errHelp) {
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "ejspdf: %v\n", err)
+	var ee *exitError
+	if errors.As(err, &ee) {
+		return ee.code
+	}
+	return exitFailure
+}
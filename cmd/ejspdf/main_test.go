@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/fs"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yodsakorn-so/ejspdf"
+)
+
+// fakeRender replaces the renderer for the duration of a test.
+func fakeRender(t *testing.T, fn func(context.Context, ejspdf.Options) (*ejspdf.Document, error)) {
+	t.Helper()
+	old := renderDocument
+	renderDocument = fn
+	t.Cleanup(func() { renderDocument = old })
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestParseRender(t *testing.T) {
+	dir := t.TempDir()
+	tpl := writeFile(t, dir, "invoice.ejs", "<h1><%= customer %></h1>")
+	footer := writeFile(t, dir, "footer.html", `<span class="pageNumber"></span>`)
+	xml := writeFile(t, dir, "factur-x.xml", "<xml/>")
+
+	args := []string{
+		"--page-size", "Letter", tpl, "--landscape", "--margin", "15mm", "--margin-top", "1in",
+		"--data", "-", "--data-format", "yaml", "--footer", footer,
+		"--chrome-flag", "--lang=th-TH", "--chrome-flag", "--disable-gpu",
+		"--attach", xml + ",Data", "--user-password", "secret", "--watermark", "DRAFT",
+		"--max-image-dpi", "150", "--split-dir", filepath.Join(dir, "sections"),
+	}
+	job, err := parseRender(args, strings.NewReader("customer: ACME\n"), &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := job.opt
+	if opt.Template != "<h1><%= customer %></h1>" || opt.TemplatePath != tpl {
+		t.Errorf("template = %q from %q", opt.Template, opt.TemplatePath)
+	}
+	if d, ok := opt.Data.(map[string]any); !ok || d["customer"] != "ACME" {
+		t.Errorf("data = %#v", opt.Data)
+	}
+	if opt.PageSize != "Letter" || !opt.Landscape {
+		t.Errorf("page = %s, landscape %v", opt.PageSize, opt.Landscape)
+	}
+	if opt.MarginTop != "1in" || opt.MarginBottom != "15mm" || opt.MarginLeft != "15mm" || opt.MarginRight != "15mm" {
+		t.Errorf("margins = %s %s %s %s", opt.MarginTop, opt.MarginBottom, opt.MarginLeft, opt.MarginRight)
+	}
+	if !opt.DisplayHeaderFooter || opt.FooterTemplate != `<span class="pageNumber"></span>` || opt.HeaderTemplate != "" {
+		t.Errorf("header/footer = %v %q %q", opt.DisplayHeaderFooter, opt.HeaderTemplate, opt.FooterTemplate)
+	}
+	if len(opt.ChromeFlags) != 2 || opt.ChromeFlags[0] != "--lang=th-TH" {
+		t.Errorf("chrome flags = %q", opt.ChromeFlags)
+	}
+	if len(opt.Attachments) != 1 || opt.Attachments[0].Name != "factur-x.xml" ||
+		opt.Attachments[0].Relationship != "Data" || opt.Attachments[0].MIMEType != "text/xml" {
+		t.Errorf("attachments = %+v", opt.Attachments)
+	}
+	if opt.Encryption == nil || opt.Encryption.UserPassword != "secret" {
+		t.Errorf("encryption = %+v", opt.Encryption)
+	}
+	if opt.Watermark == nil || opt.Watermark.Text != "DRAFT" {
+		t.Errorf("watermark = %+v", opt.Watermark)
+	}
+	if opt.Optimize == nil || opt.Optimize.MaxImageDPI != 150 {
+		t.Errorf("optimize = %+v", opt.Optimize)
+	}
+	if !opt.SplitSections || opt.Logger == nil {
+		t.Errorf("split sections %v, logger %v", opt.SplitSections, opt.Logger)
+	}
+	if want := filepath.Join(dir, "invoice.pdf"); job.output != want {
+		t.Errorf("output = %s, want %s", job.output, want)
+	}
+}
+
+func TestParseRenderSign(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Signer"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := writeFile(t, dir, "key.pem", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})))
+	certFile := writeFile(t, dir, "cert.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	tpl := writeFile(t, dir, "t.ejs", "x")
+
+	job, err := parseRender([]string{tpl, "--sign-key", keyFile, "--sign-cert", certFile, "--sign-reason", "Approved"}, nil, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := job.opt.Sign
+	if s == nil || s.Signer == nil || len(s.Certificates) != 1 || s.Reason != "Approved" {
+		t.Fatalf("signature = %+v", s)
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	tpl := writeFile(t, dir, "t.ejs", "<p><%= n %></p>")
+	fakeRender(t, func(ctx context.Context, opt ejspdf.Options) (*ejspdf.Document, error) {
+		switch opt.PDFA {
+		case ejspdf.PDFA2B:
+			return nil, &ejspdf.ConformanceError{Level: opt.PDFA, Violations: []string{"font not embedded"}}
+		case "wait":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return &ejspdf.Document{PDF: []byte("%PDF-1.7")}, nil
+	})
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"no command", nil, exitUsage},
+		{"unknown command", []string{"print"}, exitUsage},
+		{"help", []string{"help"}, exitOK},
+		{"render help", []string{"render", "-h"}, exitOK},
+		{"unknown flag", []string{"render", tpl, "--colour"}, exitUsage},
+		{"no template", []string{"render"}, exitUsage},
+		{"two templates", []string{"render", tpl, tpl}, exitUsage},
+		{"both stdin", []string{"render", "-", "--data", "-"}, exitUsage},
+		{"missing template", []string{"render", filepath.Join(dir, "missing.ejs")}, exitInput},
+		{"missing data", []string{"render", tpl, "--data", filepath.Join(dir, "missing.json")}, exitInput},
+		{"conformance", []string{"render", tpl, "-o", "-", "--pdfa", ejspdf.PDFA2B}, exitConformance},
+		{"timeout", []string{"render", tpl, "-o", "-", "--pdfa", "wait", "--timeout", "10ms"}, exitTimeout},
+		{"ok", []string{"render", tpl, "-o", "-"}, exitOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(context.Background(), tt.args, strings.NewReader(""), &stdout, &stderr); code != tt.code {
+				t.Errorf("exit code %d, want %d; stderr:\n%s", code, tt.code, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunRender(t *testing.T) {
+	dir := t.TempDir()
+	tpl := writeFile(t, dir, "statements.ejs", "<%= n %>")
+	data := writeFile(t, dir, "data.json", `{"n": 3}`)
+	var got ejspdf.Options
+	fakeRender(t, func(ctx context.Context, opt ejspdf.Options) (*ejspdf.Document, error) {
+		got = opt
+		return &ejspdf.Document{
+			PDF:            []byte("%PDF-all"),
+			BrowserVersion: "HeadlessChrome/120.0.6099.109",
+			Sections: []ejspdf.Section{
+				{ID: "a", FirstPage: 1, LastPage: 1, PDF: []byte("%PDF-a")},
+				{ID: "b", FirstPage: 2, LastPage: 3, PDF: []byte("%PDF-b")},
+			},
+		}, nil
+	})
+
+	out := filepath.Join(dir, "out.pdf")
+	report := filepath.Join(dir, "report.json")
+	split := filepath.Join(dir, "split")
+	var stderr bytes.Buffer
+	args := []string{"render", tpl, "--data", data, "-o", out, "--report", report, "--split-dir", split}
+	if code := run(context.Background(), args, nil, &bytes.Buffer{}, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if d, ok := got.Data.(map[string]any); !ok || d["n"] != 3.0 {
+		t.Errorf("data = %#v", got.Data)
+	}
+	if b, _ := os.ReadFile(out); string(b) != "%PDF-all" {
+		t.Errorf("output = %q", b)
+	}
+	for id, want := range map[string]string{"a": "%PDF-a", "b": "%PDF-b"} {
+		if b, _ := os.ReadFile(filepath.Join(split, id+".pdf")); string(b) != want {
+			t.Errorf("section %s = %q", id, b)
+		}
+	}
+	var r renderReport
+	b, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.BrowserVersion != "HeadlessChrome/120.0.6099.109" || len(r.Sections) != 2 || r.Sections[1].LastPage != 3 {
+		t.Errorf("report = %s", b)
+	}
+
+	// The template can come from stdin, and the PDF go to stdout.
+	var stdout bytes.Buffer
+	if code := run(context.Background(), []string{"render", "-"}, strings.NewReader("<p>stdin</p>"), &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if got.Template != "<p>stdin</p>" || stdout.String() != "%PDF-all" {
+		t.Errorf("stdin template %q, stdout %q", got.Template, stdout.String())
+	}
+}
+
+func TestParseServe(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "invoice.ejs", "x")
+	job, err := parseServe([]string{"--templates", dir, "--addr", "127.0.0.1:9000", "--max-concurrent", "3",
+		"--timeout", "30s", "--remote-url", "ws://chrome:9222"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := job.srv
+	if job.addr != "127.0.0.1:9000" || srv.MaxConcurrent != 3 || srv.Timeout != 30*time.Second {
+		t.Errorf("addr %s, max concurrent %d, timeout %s", job.addr, srv.MaxConcurrent, srv.Timeout)
+	}
+	if srv.Options.RemoteURL != "ws://chrome:9222" || srv.Options.Logger == nil {
+		t.Errorf("options = %+v", srv.Options)
+	}
+	if b, err := fs.ReadFile(srv.Templates, "invoice.ejs"); err != nil || string(b) != "x" {
+		t.Errorf("template = %q, %v", b, err)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		code int
+	}{
+		{nil, exitUsage},
+		{[]string{"--templates", dir, "extra"}, exitUsage},
+		{[]string{"--templates", filepath.Join(dir, "missing")}, exitInput},
+		{[]string{"--templates", filepath.Join(dir, "invoice.ejs")}, exitInput},
+	} {
+		if code := run(context.Background(), append([]string{"serve"}, tt.args...), nil, &bytes.Buffer{}, &bytes.Buffer{}); code != tt.code {
+			t.Errorf("serve %q: exit code %d, want %d", tt.args, code, tt.code)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestRunServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var stderr syncBuffer
+	done := make(chan int)
+	go func() {
+		done <- run(ctx, []string{"serve", "--templates", t.TempDir(), "--addr", "127.0.0.1:0"}, nil, &bytes.Buffer{}, &stderr)
+	}()
+
+	addr := regexp.MustCompile(`addr=(\S+)`)
+	var m []string
+	for deadline := time.Now().Add(5 * time.Second); m == nil; {
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start: %s", stderr.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+		m = addr.FindStringSubmatch(stderr.String())
+	}
+	resp, err := http.Get("http://" + m[1] + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("healthz: status %d", resp.StatusCode)
+	}
+
+	cancel()
+	if code := <-done; code != exitOK {
+		t.Errorf("exit code %d: %s", code, stderr.String())
+	}
+}
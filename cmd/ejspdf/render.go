@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yodsakorn-so/ejspdf"
+	"github.com/yodsakorn-so/ejspdf/pdfutil"
+)
+
+// errHelp is returned when the flags of a command were asked for.
+var errHelp = flag.ErrHelp
+
+// renderDocument renders a job; tests replace it.
+var renderDocument = ejspdf.RenderDocument
+
+// renderJob is a parsed render command.
+type renderJob struct {
+	opt ejspdf.Options
+	// output is the PDF path, or "-" for stdout.
+	output string
+	// splitDir receives one PDF per section.
+	splitDir string
+	// report receives a JSON report of the render.
+	report  string
+	timeout time.Duration
+}
+
+// parseRender parses the arguments of the render command and reads its
+// input files.
+func parseRender(args []string, stdin io.Reader, stderr io.Writer) (*renderJob, error) {
+	job := &renderJob{}
+	opt := &job.opt
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	var (
+		dataPath, dataFormat   string
+		margin                 string
+		headerPath, footerPath string
+		encrypt                bool
+		enc                    ejspdf.Encryption
+		signKey, signCert      string
+		sign                   ejspdf.Signature
+		wm                     ejspdf.Watermark
+		watermarkImage         string
+		optimize               bool
+		opti                   ejspdf.Optimization
+		attachments            stringList
+	)
+
+	// Input and output
+	fs.StringVar(&dataPath, "data", "", "JSON or YAML `file` with the template data, or - for stdin")
+	fs.StringVar(&dataFormat, "data-format", "", "data `format`: json or yaml (default from the file extension, else json)")
+	fs.StringVar(&job.output, "o", "", "output PDF `file`, or - for stdout (default TEMPLATE with a .pdf extension)")
+	fs.StringVar(&job.output, "output", "", "same as -o")
+	fs.StringVar(&job.report, "report", "", "write a JSON report of the render (sections, accessibility issues, browser version) to `file`")
+	fs.DurationVar(&job.timeout, "timeout", 2*time.Minute, "abort the render after this `duration`")
+
+	// Browser
+	browser := addBrowserFlags(fs, opt, "warn")
+
+	// Page
+	fs.StringVar(&opt.PageSize, "page-size", "", "paper `size`: A4, A3, A5, Letter, Legal or Tabloid (default A4)")
+	fs.BoolVar(&opt.Landscape, "landscape", false, "landscape orientation")
+	fs.StringVar(&opt.PaperWidth, "paper-width", "", "custom paper `width`, e.g. 80mm")
+	fs.StringVar(&opt.PaperHeight, "paper-height", "", "custom paper `height`, e.g. 200mm")
+	fs.StringVar(&margin, "margin", "", "all four `margins`, e.g. 15mm (default 10mm)")
+	fs.StringVar(&opt.MarginTop, "margin-top", "", "top `margin`")
+	fs.StringVar(&opt.MarginBottom, "margin-bottom", "", "bottom `margin`")
+	fs.StringVar(&opt.MarginLeft, "margin-left", "", "left `margin`")
+	fs.StringVar(&opt.MarginRight, "margin-right", "", "right `margin`")
+	fs.BoolVar(&opt.DisplayHeaderFooter, "header-footer", false, "print the header and footer (implied by -header and -footer)")
+	fs.StringVar(&headerPath, "header", "", "HTML `file` of the page header")
+	fs.StringVar(&footerPath, "footer", "", "HTML `file` of the page footer")
+	fs.StringVar(&opt.WaitSelector, "wait-selector", "", "CSS `selector` to wait for before printing")
+	fs.DurationVar(&opt.WaitDelay, "wait-delay", 0, "extra `duration` to wait before printing")
+	fs.Float64Var(&opt.Scale, "scale", 0, "rendering `scale` (default 1)")
+	fs.StringVar(&opt.PageRanges, "page-ranges", "", "`pages` to print, e.g. 1-5,8")
+	fs.BoolVar(&opt.IgnoreBackground, "no-background", false, "do not print background graphics")
+
+	// Document
+	fs.BoolVar(&opt.GenerateOutline, "outline", false, "add bookmarks built from the headings")
+	fs.BoolVar(&opt.Tagged, "tagged", false, "produce a tagged (accessible) PDF")
+	fs.StringVar(&opt.PDFA, "pdfa", "", "PDF/A `level`: PDF/A-2b or PDF/A-3b")
+	fs.BoolVar(&opt.Fields, "fields", false, "turn form elements into fillable fields")
+	fs.BoolVar(&opt.Sections, "sections", false, "report the pages of each data-ejspdf-section element")
+	fs.StringVar(&job.splitDir, "split-dir", "", "also write each section to `directory`/<id>.pdf")
+	fs.Var(&attachments, "attach", "embed a `file[,relationship]`, e.g. factur-x.xml,Data (repeatable)")
+	fs.BoolVar(&optimize, "optimize", false, "reduce the file size")
+	fs.Float64Var(&opti.MaxImageDPI, "max-image-dpi", 0, "downsample images above this `dpi` (implies -optimize)")
+	fs.IntVar(&opti.JPEGQuality, "jpeg-quality", 0, "`quality` of downsampled JPEG images (default 85)")
+	fs.BoolVar(&opt.Linearize, "linearize", false, "write a linearized (fast web view) PDF")
+
+	// Encryption
+	fs.BoolVar(&encrypt, "encrypt", false, "encrypt the PDF (implied by the passwords)")
+	fs.StringVar(&enc.UserPassword, "user-password", "", "`password` required to open the PDF")
+	fs.StringVar(&enc.OwnerPassword, "owner-password", "", "`password` granting full access (default random)")
+	fs.IntVar(&enc.KeyLength, "key-length", 0, "AES key `bits`: 128 or 256 (default 256)")
+	fs.BoolVar(&enc.AllowPrint, "allow-print", false, "allow printing an encrypted PDF")
+	fs.BoolVar(&enc.AllowCopy, "allow-copy", false, "allow copying from an encrypted PDF")
+	fs.BoolVar(&enc.AllowModify, "allow-modify", false, "allow modifying an encrypted PDF")
+
+	// Signature
+	fs.StringVar(&signKey, "sign-key", "", "PEM `file` of the private key to sign with")
+	fs.StringVar(&signCert, "sign-cert", "", "PEM `file` of the signing certificate and its chain")
+	fs.StringVar(&sign.Name, "sign-name", "", "signer `name`")
+	fs.StringVar(&sign.Reason, "sign-reason", "", "signing `reason`")
+	fs.StringVar(&sign.Location, "sign-location", "", "signing `location`")
+	fs.StringVar(&sign.ContactInfo, "sign-contact", "", "signer `contact` information")
+
+	// Watermark
+	fs.StringVar(&wm.Text, "watermark", "", "watermark `text`, e.g. DRAFT")
+	fs.StringVar(&watermarkImage, "watermark-image", "", "watermark image `file` instead of text")
+	fs.StringVar(&wm.FontSize, "watermark-font-size", "", "CSS font `size` of the watermark text")
+	fs.StringVar(&wm.FontFamily, "watermark-font-family", "", "CSS font `family` of the watermark text")
+	fs.StringVar(&wm.Color, "watermark-color", "", "CSS `color` of the watermark text")
+	fs.StringVar(&wm.Width, "watermark-width", "", "CSS `width` of the watermark image")
+	fs.Float64Var(&wm.Opacity, "watermark-opacity", 0, "watermark `opacity` from 0 to 1 (default 0.3)")
+	fs.Float64Var(&wm.Rotation, "watermark-rotation", 0, "clockwise watermark rotation in `degrees`")
+	fs.StringVar(&wm.Position, "watermark-position", "", "watermark `position`: center, top, bottom-right, ...")
+	fs.StringVar(&wm.Pages, "watermark-pages", "", "`pages` to watermark, e.g. 1 or 2-")
+	fs.BoolVar(&wm.Behind, "watermark-behind", false, "draw the watermark under the page content")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ejspdf render [flags] TEMPLATE\n\n"+
+			"Renders the EJS template file TEMPLATE, or stdin if it is -, to PDF.\n\nFlags:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\nExit status: 0 success, 1 render failed, 2 invalid usage, "+
+			"3 unreadable input, 4 timeout, 5 PDF/A violations.\n")
+	}
+
+	positional, err := parseArgs(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil, errHelp
+	}
+	if err != nil {
+		return nil, &exitError{exitUsage, err}
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return nil, usageError("render needs exactly one template, got %d", len(positional))
+	}
+	template := positional[0]
+	if template == "-" && dataPath == "-" {
+		return nil, usageError("the template and the data cannot both be read from stdin")
+	}
+	if job.output == "" {
+		if template == "-" {
+			job.output = "-"
+		} else {
+			job.output = strings.TrimSuffix(template, filepath.Ext(template)) + ".pdf"
+		}
+	}
+
+	if err := browser.apply(stderr); err != nil {
+		return nil, err
+	}
+
+	// Margins set individually override -margin.
+	for _, m := range []*string{&opt.MarginTop, &opt.MarginBottom, &opt.MarginLeft, &opt.MarginRight} {
+		if *m == "" {
+			*m = margin
+		}
+	}
+
+	// Input files
+	tpl, err := readInput(template, stdin)
+	if err != nil {
+		return nil, inputError(fmt.Errorf("read template: %w", err))
+	}
+	opt.Template = string(tpl)
+	if template != "-" {
+		if opt.TemplatePath, err = filepath.Abs(template); err != nil {
+			return nil, inputError(err)
+		}
+	}
+	if opt.Data, err = loadData(dataPath, dataFormat, stdin); err != nil {
+		return nil, inputError(err)
+	}
+	if headerPath != "" {
+		b, err := os.ReadFile(headerPath)
+		if err != nil {
+			return nil, inputError(fmt.Errorf("read header: %w", err))
+		}
+		opt.HeaderTemplate, opt.DisplayHeaderFooter = string(b), true
+	}
+	if footerPath != "" {
+		b, err := os.ReadFile(footerPath)
+		if err != nil {
+			return nil, inputError(fmt.Errorf("read footer: %w", err))
+		}
+		opt.FooterTemplate, opt.DisplayHeaderFooter = string(b), true
+	}
+	for _, a := range attachments {
+		att, err := readAttachment(a)
+		if err != nil {
+			return nil, inputError(err)
+		}
+		opt.Attachments = append(opt.Attachments, att)
+	}
+
+	if encrypt || enc.UserPassword != "" || enc.OwnerPassword != "" {
+		opt.Encryption = &enc
+	}
+	if signKey != "" || signCert != "" {
+		if signKey == "" || signCert == "" {
+			return nil, usageError("signing needs both -sign-key and -sign-cert")
+		}
+		if sign.Signer, err = readSigner(signKey); err != nil {
+			return nil, inputError(err)
+		}
+		if sign.Certificates, err = readCertificates(signCert); err != nil {
+			return nil, inputError(err)
+		}
+		opt.Sign = &sign
+	}
+	if watermarkImage != "" {
+		if wm.Image, err = os.ReadFile(watermarkImage); err != nil {
+			return nil, inputError(fmt.Errorf("read watermark image: %w", err))
+		}
+	}
+	if wm.Text != "" || wm.Image != nil {
+		opt.Watermark = &wm
+	}
+	if optimize || opti.MaxImageDPI > 0 || opti.JPEGQuality > 0 {
+		opt.Optimize = &opti
+	}
+	if job.splitDir != "" {
+		opt.SplitSections = true
+	}
+	return job, nil
+}
+
+// readAttachment reads an -attach value, "file" or
+// "file,relationship".
+func readAttachment(v string) (ejspdf.Attachment, error) {
+	path, rel := v, ""
+	if i := strings.LastIndex(v, ","); i >= 0 {
+		path, rel = v[:i], v[i+1:]
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ejspdf.Attachment{}, fmt.Errorf("read attachment: %w", err)
+	}
+	typ := mime.TypeByExtension(filepath.Ext(path))
+	if typ == "" {
+		typ = "application/octet-stream"
+	}
+	typ, _, _ = strings.Cut(typ, ";")
+	return ejspdf.Attachment{
+		Name:         filepath.Base(path),
+		MIMEType:     typ,
+		Data:         data,
+		Relationship: rel,
+	}, nil
+}
+
+// readSigner reads a PEM private key in PKCS #8, PKCS #1 or SEC 1 form.
+func readSigner(path string) (crypto.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read signing key: %w", err)
+	}
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return nil, fmt.Errorf("no private key in %s", path)
+		}
+		var key any
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse signing key: %w", err)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported signing key type %T", key)
+		}
+		return signer, nil
+	}
+}
+
+// readCertificates reads the PEM certificates of a file, signing
+// certificate first.
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read certificate: %w", err)
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate in %s", path)
+	}
+	return certs, nil
+}
+
+// runRender runs the render command.
+func runRender(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	job, err := parseRender(args, stdin, stderr)
+	if err != nil {
+		return err
+	}
+	if job.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.timeout)
+		defer cancel()
+	}
+
+	doc, err := renderDocument(ctx, job.opt)
+	if err != nil {
+		var ce *ejspdf.ConformanceError
+		switch {
+		case errors.As(err, &ce):
+			return &exitError{exitConformance, err}
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			return &exitError{exitTimeout, fmt.Errorf("timed out after %s: %w", job.timeout, err)}
+		}
+		return err
+	}
+
+	if job.output == "-" {
+		if _, err := stdout.Write(doc.PDF); err != nil {
+			return fmt.Errorf("write pdf: %w", err)
+		}
+	} else if err := os.WriteFile(job.output, doc.PDF, 0644); err != nil {
+		return fmt.Errorf("write pdf: %w", err)
+	}
+	if job.splitDir != "" {
+		if err := writeSections(job.splitDir, doc.Sections); err != nil {
+			return err
+		}
+	}
+	if job.report != "" {
+		if err := writeReport(job.report, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSections writes the PDF of each section to dir/<id>.pdf.
+func writeSections(dir string, sections []ejspdf.Section) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, s := range sections {
+		name := s.ID + ".pdf"
+		if !filepath.IsLocal(name) || strings.ContainsAny(s.ID, `/\`) {
+			return fmt.Errorf("section id %q is not a valid file name", s.ID)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), s.PDF, 0644); err != nil {
+			return fmt.Errorf("write section: %w", err)
+		}
+	}
+	return nil
+}
+
+// renderReport is the JSON report written by -report.
+type renderReport struct {
+	Pages               int                         `json:"pages,omitempty"`
+	Size                int                         `json:"size"`
+	BrowserVersion      string                      `json:"browserVersion,omitempty"`
+	AccessibilityIssues []ejspdf.AccessibilityIssue `json:"accessibilityIssues,omitempty"`
+	Sections            []sectionReport             `json:"sections,omitempty"`
+	Optimization        *ejspdf.OptimizationReport  `json:"optimization,omitempty"`
+}
+
+type sectionReport struct {
+	ID        string `json:"id"`
+	FirstPage int    `json:"firstPage"`
+	LastPage  int    `json:"lastPage"`
+}
+
+func writeReport(path string, doc *ejspdf.Document) error {
+	pages, _ := pdfutil.PageCount(doc.PDF)
+	r := renderReport{
+		Pages:               pages,
+		Size:                len(doc.PDF),
+		BrowserVersion:      doc.BrowserVersion,
+		AccessibilityIssues: doc.AccessibilityIssues,
+		Optimization:        doc.Optimization,
+	}
+	for _, s := range doc.Sections {
+		r.Sections = append(r.Sections, sectionReport{s.ID, s.FirstPage, s.LastPage})
+	}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	return nil
+}
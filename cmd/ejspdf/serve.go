@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/yodsakorn-so/ejspdf/server"
+)
+
+// shutdownTimeout is how long running renders may take to finish once
+// the serve command is interrupted.
+const shutdownTimeout = 30 * time.Second
+
+// serveJob is a parsed serve command.
+type serveJob struct {
+	srv  *server.Server
+	addr string
+}
+
+// parseServe parses the arguments of the serve command.
+func parseServe(args []string, stderr io.Writer) (*serveJob, error) {
+	job := &serveJob{srv: &server.Server{}}
+	srv := job.srv
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	var templates string
+	fs.StringVar(&templates, "templates", "", "`directory` of the templates served (required)")
+	fs.StringVar(&job.addr, "addr", ":8080", "listen `address`")
+	fs.IntVar(&srv.MaxConcurrent, "max-concurrent", 0, "`number` of renders run at once (default the number of CPUs)")
+	fs.DurationVar(&srv.Timeout, "timeout", server.DefaultTimeout, "abort a request after this `duration`, including the wait for a render slot")
+	fs.Int64Var(&srv.MaxRequestBytes, "max-request-bytes", server.DefaultMaxRequestBytes, "largest request body in `bytes`")
+	browser := addBrowserFlags(fs, &srv.Options, "info")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ejspdf serve --templates DIR [flags]\n\n"+
+			"Serves the templates of DIR over HTTP: POST /render, GET /healthz and GET /readyz.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseArgs(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil, errHelp
+	}
+	if err != nil {
+		return nil, &exitError{exitUsage, err}
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return nil, usageError("serve takes no arguments, got %q", positional)
+	}
+	if templates == "" {
+		fs.Usage()
+		return nil, usageError("serve needs -templates")
+	}
+	if err := browser.apply(stderr); err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(templates); err != nil {
+		return nil, inputError(fmt.Errorf("read templates: %w", err))
+	} else if !fi.IsDir() {
+		return nil, inputError(fmt.Errorf("templates %s is not a directory", templates))
+	}
+	srv.Templates = os.DirFS(templates)
+	return job, nil
+}
+
+// runServe runs the serve command until ctx is canceled, then waits for
+// the running renders to finish and closes the browser.
+func runServe(ctx context.Context, args []string, stderr io.Writer) error {
+	job, err := parseServe(args, stderr)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", job.addr)
+	if err != nil {
+		return err
+	}
+	logger := job.srv.Options.Logger
+	logger.Info("serving", "addr", ln.Addr().String())
+
+	defer job.srv.Close()
+
+	hs := &http.Server{
+		Handler:           job.srv,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- hs.Serve(ln) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down")
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return hs.Shutdown(sctx)
+}
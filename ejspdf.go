@@ -2,13 +2,21 @@ package ejspdf
 
 import (
 	"context"
+	"crypto"
+	"crypto/x509"
 	"encoding/base64"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"time"
 
+	"github.com/yodsakorn-so/ejspdf/browser"
 	"github.com/yodsakorn-so/ejspdf/internal/pdf"
 	"github.com/yodsakorn-so/ejspdf/internal/renderer"
 	"github.com/yodsakorn-so/ejspdf/internal/renderer/assets"
@@ -21,12 +29,56 @@ type Options struct {
 	// TemplatePath is the file path of the template (optional).
 	// Required for resolving relative paths in <%- include(...) %>.
 	TemplatePath string
+	// TemplateFS, if set, is the file system includes are read from, with
+	// TemplatePath a slash-separated path within it, e.g. an embed.FS.
+	// Default is the OS file system.
+	TemplateFS fs.FS
 	// Data is the data map to pass to the template.
 	Data any
 
 	// ChromePath is the custom path to Chrome/Chromium executable.
 	// If empty, it will try to find Chrome automatically.
 	ChromePath string
+	// RemoteURL connects to a running Chrome instead of starting one, e.g.
+	// a shared headless Chrome sidecar started with
+	// --remote-debugging-port. It is the DevTools WebSocket URL
+	// ("ws://chrome:9222/devtools/browser/<id>") or just the address
+	// ("ws://chrome:9222"). Each render opens a new tab; an unreachable
+	// browser is retried with backoff. ChromePath is then ignored.
+	RemoteURL string
+
+	// ChromeFlags are extra command-line flags for the Chrome started by
+	// ejspdf, e.g. "--font-render-hinting=none", "--lang=th-TH",
+	// "--user-data-dir=/tmp/chrome" or "--proxy-server=http://proxy:3128".
+	// They override the defaults; "--name=false" drops a default flag.
+	ChromeFlags []string
+	// ChromeEnv are extra NAME=value environment variables for Chrome,
+	// added to those of the current process.
+	ChromeEnv []string
+	// Sandbox keeps Chrome's sandbox enabled. By default Chrome runs with
+	// --no-sandbox, which most containers and CI runners require.
+	Sandbox bool
+	// Browser pins the Chromium revision downloaded when ChromePath is
+	// empty and no installed Chrome is found, and the checksum it is
+	// verified against. See browser.Config.
+	Browser browser.Config
+
+	// Printer converts the rendered HTML to PDF. Default is a
+	// ChromePrinter using ChromePath. A ChromePrinter given here carries
+	// its own browser settings: setting ChromePath, RemoteURL,
+	// ChromeFlags, ChromeEnv, Sandbox, Browser or StrictBrowserVersion
+	// as well is an error.
+	Printer Printer
+
+	// Logger receives browser discovery, download and render messages,
+	// with routine steps at debug level and problems as warnings.
+	// Default is slog.Default().
+	Logger *slog.Logger
+	// StrictBrowserVersion fails a render when Chrome is too old for a
+	// requested option, such as Tagged before Chrome 116. By default the
+	// option is ignored with a warning; GenerateOutline then falls back
+	// to the bookmarks built by ejspdf.
+	StrictBrowserVersion bool
 
 	// PageSize sets the paper size (e.g., "A4", "A3", "Letter", "Legal").
 	// Default is "A4".
@@ -73,26 +125,306 @@ type Options struct {
 	// IgnoreBackground disables printing of background graphics.
 	// Default is false (backgrounds are printed).
 	IgnoreBackground bool
+
+	// GenerateOutline adds bookmarks built from the h1-h6 headings and from
+	// elements marked with data-ejspdf-outline="Title" (the element text is
+	// used when the attribute is empty; data-ejspdf-outline-level sets the
+	// nesting level, and data-ejspdf-outline="false" excludes a heading).
+	// Chrome's own outline is used when available; otherwise ejspdf builds
+	// the bookmarks itself.
+	GenerateOutline bool
+
+	// Tagged asks Chrome for a tagged (accessible) PDF and sets the document
+	// language from <html lang>. The rendered page is also checked for
+	// common accessibility problems, which are reported in
+	// Document.AccessibilityIssues.
+	Tagged bool
+
+	// PDFA converts the output for long-term archival. Supported levels are
+	// PDFA2B ("PDF/A-2b") and PDFA3B ("PDF/A-3b"). An sRGB output intent and
+	// XMP metadata are embedded; a *ConformanceError is returned if the
+	// document still violates the level (e.g. a font is not embedded).
+	PDFA string
+
+	// Encryption protects the output with passwords and permissions.
+	// It cannot be combined with PDFA.
+	Encryption *Encryption
+
+	// Sign digitally signs the output (PAdES, ETSI.CAdES.detached).
+	// The signature is invisible unless the template contains an element
+	// marked with data-ejspdf-signature; a signature field is then placed
+	// over that element (the attribute value, if any, names the field).
+	Sign *Signature
+
+	// Fields turns the form elements of the template into fillable form
+	// fields placed where they were printed: text inputs and textareas
+	// become text fields, checkboxes become checkboxes. Any element can be
+	// made a field with data-ejspdf-field="text|checkbox|signature", and
+	// data-ejspdf-field="false" excludes one. Field names come from
+	// data-ejspdf-field-name, name or id. Field fonts are not embedded, so
+	// text fields and checkboxes cannot be combined with PDFA.
+	Fields bool
+
+	// Watermark stamps a text or image (e.g. "DRAFT") onto the pages
+	// without changing the template layout.
+	Watermark *Watermark
+
+	// Attachments are embedded in the PDF, e.g. the XML of an e-tax
+	// invoice. With PDFA3B they are recorded as associated files.
+	Attachments []Attachment
+
+	// Sections reports, in Document.Sections, the pages covered by each
+	// element marked with data-ejspdf-section="<id>", e.g. one customer
+	// statement of a batch render.
+	Sections bool
+	// SplitSections also cuts the output into one PDF per section,
+	// returned in Section.PDF. It implies Sections.
+	SplitSections bool
+
+	// Optimize reduces the file size: identical images and fonts are
+	// stored once, streams are recompressed and images drawn above
+	// Optimization.MaxImageDPI are downsampled. The savings are reported
+	// in Document.Optimization.
+	Optimize *Optimization
+
+	// Linearize writes the PDF in linearized ("fast web view") form, so
+	// that viewers can display the first page before the whole file is
+	// downloaded. It combines with encryption and signing.
+	Linearize bool
+}
+
+// Optimization configures the size optimization of the generated PDF.
+type Optimization struct {
+	// MaxImageDPI is the highest resolution kept for images, e.g. 150
+	// for screen or 300 for print. Zero keeps the images as they are.
+	MaxImageDPI float64
+	// JPEGQuality (1-100) is used to re-encode downsampled JPEG images.
+	// Default is 85.
+	JPEGQuality int
+}
+
+// OptimizationReport describes what Options.Optimize saved.
+type OptimizationReport struct {
+	// OriginalSize and Size are the sizes of the document just before
+	// and after the optimization, in bytes. Encryption, signing and
+	// linearization come later and only change len(Document.PDF).
+	OriginalSize int
+	Size         int
+	BytesSaved   int
+
+	DuplicatesRemoved   int
+	ImagesDownsampled   int
+	StreamsRecompressed int
+}
+
+// Attachment is a file embedded in the generated PDF.
+type Attachment struct {
+	// Name is the file name, e.g. "factur-x.xml".
+	Name string
+	// MIMEType is the media type, e.g. "text/xml". Default is
+	// "application/octet-stream".
+	MIMEType string
+	// Data is the file content.
+	Data []byte
+	// Description is shown by PDF viewers.
+	Description string
+	// Relationship describes how the file relates to the document:
+	// RelationshipSource, RelationshipData, RelationshipAlternative,
+	// RelationshipSupplement or RelationshipUnspecified (the default).
+	Relationship string
+}
+
+// Associated file relationships of an Attachment.
+const (
+	RelationshipSource      = "Source"
+	RelationshipData        = "Data"
+	RelationshipAlternative = "Alternative"
+	RelationshipSupplement  = "Supplement"
+	RelationshipUnspecified = "Unspecified"
+)
+
+// Signature configures the digital signature of the generated PDF.
+type Signature struct {
+	// Signer is the private key (RSA or ECDSA) used to sign.
+	Signer crypto.Signer
+	// Certificates is the signing certificate followed by the rest of its
+	// chain, if any.
+	Certificates []*x509.Certificate
+
+	// Name, Reason, Location and ContactInfo are shown by PDF viewers.
+	Name        string
+	Reason      string
+	Location    string
+	ContactInfo string
+}
+
+// Encryption configures password protection of the generated PDF.
+type Encryption struct {
+	// UserPassword is required to open the document. If empty, the
+	// document opens without a password but the permissions still apply.
+	UserPassword string
+	// OwnerPassword grants full access. A random one is used if empty.
+	OwnerPassword string
+	// KeyLength is the AES key size in bits: 128 or 256. Default is 256.
+	KeyLength int
+
+	// AllowPrint allows printing the document.
+	AllowPrint bool
+	// AllowCopy allows copying text and images.
+	AllowCopy bool
+	// AllowModify allows editing, annotating, filling in forms and
+	// assembling (inserting, rotating or deleting pages).
+	AllowModify bool
+}
+
+// PDF/A conformance levels supported by Options.PDFA.
+const (
+	PDFA2B = "PDF/A-2b"
+	PDFA3B = "PDF/A-3b"
+)
+
+// ConformanceError lists why a document does not conform to the requested
+// PDF/A level.
+type ConformanceError struct {
+	Level      string
+	Violations []string
+}
+
+func (e *ConformanceError) Error() string {
+	return fmt.Sprintf("ejspdf: document does not conform to %s: %s", e.Level, strings.Join(e.Violations, "; "))
+}
+
+// Document is the result of rendering a template.
+type Document struct {
+	// PDF is the generated PDF file.
+	PDF []byte
+	// BrowserVersion is the version of the Chrome that printed the PDF,
+	// e.g. "HeadlessChrome/120.0.6099.109". It is empty for a custom
+	// Printer.
+	BrowserVersion string
+
+	// AccessibilityIssues lists the problems found in the rendered page when
+	// Options.Tagged is set.
+	AccessibilityIssues []AccessibilityIssue
+
+	// Sections lists the data-ejspdf-section elements when
+	// Options.Sections or Options.SplitSections is set.
+	Sections []Section
+
+	// Optimization reports the savings when Options.Optimize is set.
+	Optimization *OptimizationReport
+}
+
+// Section is the page range of the elements marked with the same
+// data-ejspdf-section id.
+type Section struct {
+	ID string
+	// FirstPage and LastPage are 1-based page numbers in Document.PDF.
+	FirstPage int
+	LastPage  int
+	// PDF holds the pages of the section when Options.SplitSections is
+	// set.
+	PDF []byte
+}
+
+// AccessibilityIssue is an accessibility problem found in the rendered page.
+type AccessibilityIssue struct {
+	// Rule identifies the check, e.g. "image-alt", "heading-order",
+	// "document-lang" or "untagged".
+	Rule string
+	// Message describes the problem.
+	Message string
+	// Element is a short description of the offending element,
+	// e.g. `img.logo[src="logo.png"]`.
+	Element string
 }
 
 // Render generates a PDF from an EJS template using the provided options and context.
 // If the context already contains a chromedp session, it will be reused.
 func Render(ctx context.Context, opt Options) ([]byte, error) {
+	doc, err := RenderDocument(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	return doc.PDF, nil
+}
+
+// RenderDocument is like Render but also returns the information gathered
+// while rendering, such as accessibility issues.
+func RenderDocument(ctx context.Context, opt Options) (*Document, error) {
 	if opt.Template == "" {
 		return nil, fmt.Errorf("ejspdf: template is required")
 	}
+	if err := validateOptions(opt); err != nil {
+		return nil, err
+	}
+
+	logger := opt.logger()
 
 	// 1. Render EJS -> HTML
+	start := time.Now()
+	html, err := renderHTML(opt)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("rendered template", "bytes", len(html), "duration", time.Since(start))
+
+	// 2. HTML -> PDF
+	start = time.Now()
+	res, err := printHTML(ctx, html, opt)
+	if err != nil {
+		return nil, fmt.Errorf("ejspdf: render pdf failed: %w", err)
+	}
+	logger.Debug("printed pdf", "bytes", len(res.PDF), "duration", time.Since(start))
+
+	// 3. Post-process
+	doc := &Document{BrowserVersion: res.Browser}
+	for _, is := range res.Issues {
+		doc.AccessibilityIssues = append(doc.AccessibilityIssues, AccessibilityIssue(is))
+	}
+	start = time.Now()
+	if err := postProcess(doc, res, opt); err != nil {
+		return nil, fmt.Errorf("ejspdf: post-process pdf failed: %w", err)
+	}
+	logger.Debug("post-processed pdf", "bytes", len(doc.PDF), "duration", time.Since(start))
+	if n := len(doc.AccessibilityIssues); n > 0 {
+		logger.Warn("pdf has accessibility issues", "count", n)
+	}
+
+	return doc, nil
+}
+
+func (opt Options) logger() *slog.Logger {
+	if opt.Logger == nil {
+		return slog.Default()
+	}
+	return opt.Logger
+}
+
+// renderHTML renders the EJS template of opt to HTML.
+func renderHTML(opt Options) (string, error) {
 	rt := renderer.New()
+	rt.FS = opt.TemplateFS
 
 	html, err := rt.RenderEJS(assets.EJS, opt.Template, opt.Data, opt.TemplatePath)
 	if err != nil {
-		return nil, fmt.Errorf("ejspdf: render ejs failed: %w", err)
+		return "", fmt.Errorf("ejspdf: render ejs failed: %w", err)
 	}
+	return html, nil
+}
 
-	// 2. HTML -> PDF
-	chrome := pdf.New(pdf.Options{
+// chromeOptions returns the Chrome settings for opt, with defaults
+// applied.
+func chromeOptions(opt Options) pdf.Options {
+	return pdf.Options{
 		ChromePath:          opt.ChromePath,
+		RemoteURL:           opt.RemoteURL,
+		Flags:               opt.ChromeFlags,
+		Env:                 opt.ChromeEnv,
+		Sandbox:             opt.Sandbox,
+		Browser:             opt.Browser,
+		Logger:              opt.Logger,
+		StrictVersion:       opt.StrictBrowserVersion,
 		PageSize:            defaultString(opt.PageSize, "A4"),
 		Landscape:           opt.Landscape,
 		PaperWidth:          opt.PaperWidth,
@@ -109,14 +441,9 @@ func Render(ctx context.Context, opt Options) ([]byte, error) {
 		Scale:               opt.Scale,
 		PageRanges:          opt.PageRanges,
 		IgnoreBackground:    opt.IgnoreBackground,
-	})
-
-	pdfBytes, err := chrome.FromHTML(ctx, html)
-	if err != nil {
-		return nil, fmt.Errorf("ejspdf: render pdf failed: %w", err)
+		GenerateOutline:     opt.GenerateOutline,
+		Tagged:              opt.Tagged,
 	}
-
-	return pdfBytes, nil
 }
 
 // ImageFileToBase64 reads a local image file and returns a Data URI string
@@ -198,9 +525,94 @@ func FontFileToCSS(path string, fontFamily string) (string, error) {
 }`, fontFamily, mime, encoded, format), nil
 }
 
+// Validate checks opt without rendering, e.g. to reject the options of a
+// request before queueing it. RenderDocument validates the options too.
+func (opt Options) Validate() error {
+	if opt.Template == "" {
+		return fmt.Errorf("ejspdf: template is required")
+	}
+	if err := validateOptions(opt); err != nil {
+		return err
+	}
+	if _, err := printOptions(opt); err != nil {
+		return fmt.Errorf("ejspdf: %w", err)
+	}
+	return nil
+}
+
+// validateOptions checks the options that are not validated by Chrome.
+func validateOptions(opt Options) error {
+	if opt.RemoteURL != "" {
+		if u, err := url.Parse(opt.RemoteURL); err != nil || u.Host == "" {
+			return fmt.Errorf("ejspdf: invalid remote url %q", opt.RemoteURL)
+		}
+		if len(opt.ChromeFlags) > 0 || len(opt.ChromeEnv) > 0 || opt.Sandbox {
+			return fmt.Errorf("ejspdf: chrome flags, environment and sandbox do not apply to a remote browser")
+		}
+	}
+	if err := pdf.ValidateLaunch(opt.ChromeFlags, opt.ChromeEnv); err != nil {
+		return fmt.Errorf("ejspdf: %w", err)
+	}
+	switch opt.PDFA {
+	case "", PDFA2B, PDFA3B:
+	default:
+		return fmt.Errorf("ejspdf: unsupported PDF/A level %q", opt.PDFA)
+	}
+	if e := opt.Encryption; e != nil {
+		if opt.PDFA != "" {
+			return fmt.Errorf("ejspdf: PDF/A documents cannot be encrypted")
+		}
+		switch e.KeyLength {
+		case 0, 128, 256:
+		default:
+			return fmt.Errorf("ejspdf: unsupported encryption key length %d", e.KeyLength)
+		}
+	}
+	if s := opt.Sign; s != nil && (s.Signer == nil || len(s.Certificates) == 0) {
+		return fmt.Errorf("ejspdf: signing requires a signer and a certificate")
+	}
+	if _, chrome := opt.Printer.(*ChromePrinter); opt.Printer != nil && !chrome && (opt.Sections || opt.SplitSections || opt.Fields) {
+		return fmt.Errorf("ejspdf: sections and form fields require ChromePrinter")
+	}
+	if _, chrome := opt.Printer.(*ChromePrinter); chrome && (opt.ChromePath != "" || opt.RemoteURL != "" ||
+		len(opt.ChromeFlags) > 0 || len(opt.ChromeEnv) > 0 || opt.Sandbox || opt.StrictBrowserVersion ||
+		!reflect.ValueOf(opt.Browser).IsZero()) {
+		return fmt.Errorf("ejspdf: browser options must be set on the ChromePrinter")
+	}
+	if opt.Watermark != nil {
+		if err := opt.Watermark.validate(); err != nil {
+			return err
+		}
+	}
+	if o := opt.Optimize; o != nil && (o.MaxImageDPI < 0 || o.JPEGQuality < 0 || o.JPEGQuality > 100) {
+		return fmt.Errorf("ejspdf: invalid optimization settings")
+	}
+	if len(opt.Attachments) > 0 && opt.PDFA == PDFA2B {
+		return fmt.Errorf("ejspdf: attachments require PDF/A-3")
+	}
+	for _, a := range opt.Attachments {
+		if a.Name == "" {
+			return fmt.Errorf("ejspdf: attachment name is required")
+		}
+		switch a.Relationship {
+		case "", RelationshipSource, RelationshipData, RelationshipAlternative, RelationshipSupplement, RelationshipUnspecified:
+		default:
+			return fmt.Errorf("ejspdf: unsupported attachment relationship %q", a.Relationship)
+		}
+	}
+	return nil
+}
+
 func defaultString(v, d string) string {
 	if v == "" {
 		return d
 	}
 	return v
 }
+
+func defaultInt(v, d int) int {
+	if v == 0 {
+		return d
+	}
+	return v
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/yodsakorn-so/ejspdf"
+	"github.com/yodsakorn-so/ejspdf/pdfutil"
 )
 
 func TestRender_Integration(t *testing.T) {
@@ -77,4 +78,31 @@ func TestRender_Integration(t *testing.T) {
 			t.Error("PDF output is empty")
 		}
 	})
+
+	t.Run("Sections", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		doc, err := ejspdf.RenderDocument(ctx, ejspdf.Options{
+			Template: `<% customers.forEach(function (c) { %>
+				<section data-ejspdf-section="<%= c %>" style="break-after: page"><h1><%= c %></h1></section>
+			<% }) %>`,
+			Data:          map[string]any{"customers": []string{"cust-1", "cust-2"}},
+			SplitSections: true,
+		})
+		if err != nil {
+			t.Fatalf("Failed to render: %v", err)
+		}
+		if len(doc.Sections) != 2 {
+			t.Fatalf("got %d sections, want 2", len(doc.Sections))
+		}
+		for i, s := range doc.Sections {
+			if s.FirstPage != i+1 || s.LastPage != i+1 {
+				t.Errorf("section %s: pages %d-%d, want %d", s.ID, s.FirstPage, s.LastPage, i+1)
+			}
+			if n, err := pdfutil.PageCount(s.PDF); err != nil || n != 1 {
+				t.Errorf("section %s: %d pages (%v), want 1", s.ID, n, err)
+			}
+		}
+	})
 }
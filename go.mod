@@ -7,6 +7,7 @@ toolchain go1.24.5
 require (
 	github.com/chromedp/cdproto v0.0.0-20250724212937-08a3db8b4327
 	github.com/dop251/goja v0.0.0-20251201205617-2bb4c724c0f9
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -15,11 +16,7 @@ require (
 	github.com/gobwas/httphead v0.1.0 // indirect
 	github.com/gobwas/pool v0.2.1 // indirect
 	github.com/gobwas/ws v1.4.0 // indirect
-	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db // indirect
-	github.com/rivo/uniseg v0.4.7 // indirect
-	github.com/schollz/progressbar/v3 v3.19.0 // indirect
 	golang.org/x/sys v0.34.0 // indirect
-	golang.org/x/term v0.28.0 // indirect
 )
 
 require (
@@ -0,0 +1,156 @@
+package ejspdf
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yodsakorn-so/ejspdf/internal/pdf"
+	"github.com/yodsakorn-so/ejspdf/pdfutil"
+)
+
+// ImageFormat is the encoding of a rendered image.
+type ImageFormat string
+
+// Supported image formats.
+const (
+	ImagePNG  ImageFormat = "png"
+	ImageJPEG ImageFormat = "jpeg"
+	ImageWebP ImageFormat = "webp"
+)
+
+// ImageOptions configures RenderImage. Sizes are in CSS pixels.
+type ImageOptions struct {
+	// Format is the image encoding. Default is ImagePNG.
+	Format ImageFormat
+	// Quality is the compression quality of JPEG and WebP images, from 1
+	// to 100. Default is 90.
+	Quality int
+
+	// Width and Height are the size of the viewport. They default to the
+	// paper size of the Options, e.g. 794x1123 for A4 portrait.
+	Width  int
+	Height int
+	// DeviceScaleFactor is the number of image pixels per CSS pixel, e.g.
+	// 2 for a high-density image. Default is 1.
+	DeviceScaleFactor float64
+
+	// FullPage captures the whole page instead of the viewport.
+	FullPage bool
+	// Clip captures the given area of the page instead of the viewport.
+	Clip *ImageClip
+}
+
+// ImageClip is an area of the page, in CSS pixels from its top-left
+// corner.
+type ImageClip struct {
+	X, Y, Width, Height float64
+}
+
+// RenderImage renders an EJS template in Chrome and captures it as an
+// image. Paper size and orientation set the default viewport; the
+// options that only apply to PDF output, such as margins or encryption,
+// are ignored. Options.Printer, if set, must be a ChromePrinter, whose
+// browser settings are then used.
+func RenderImage(ctx context.Context, opt Options, img ImageOptions) ([]byte, error) {
+	if opt.Template == "" {
+		return nil, fmt.Errorf("ejspdf: template is required")
+	}
+	if err := validateOptions(opt); err != nil {
+		return nil, err
+	}
+	copt := chromeOptions(opt)
+	if opt.Printer != nil {
+		cp, ok := opt.Printer.(*ChromePrinter)
+		if !ok {
+			return nil, fmt.Errorf("ejspdf: images require ChromePrinter")
+		}
+		browser := cp.options(PrintOptions{})
+		copt.ChromePath, copt.RemoteURL = browser.ChromePath, browser.RemoteURL
+		copt.Flags, copt.Env, copt.Sandbox = browser.Flags, browser.Env, browser.Sandbox
+		copt.Browser, copt.Logger = browser.Browser, browser.Logger
+	}
+	sopt, err := screenshotOptions(opt, img)
+	if err != nil {
+		return nil, err
+	}
+
+	html, err := renderHTML(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := pdf.New(copt).Screenshot(ctx, html, sopt)
+	if err != nil {
+		return nil, fmt.Errorf("ejspdf: render image failed: %w", err)
+	}
+	return out, nil
+}
+
+// RenderPageImages renders the template to PDF, like Render, and
+// rasterizes each page to a PNG image at dpi pixels per inch. Zero means
+// 96, the CSS resolution.
+func RenderPageImages(ctx context.Context, opt Options, dpi float64) ([][]byte, error) {
+	if dpi < 0 {
+		return nil, fmt.Errorf("ejspdf: invalid resolution %g", dpi)
+	}
+	if dpi == 0 {
+		dpi = 96
+	}
+	out, err := Render(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	images, err := pdfutil.RenderPNG(out, dpi)
+	if err != nil {
+		return nil, fmt.Errorf("ejspdf: rasterize pdf failed: %w", err)
+	}
+	return images, nil
+}
+
+// screenshotOptions validates img and applies its defaults.
+func screenshotOptions(opt Options, img ImageOptions) (pdf.ScreenshotOptions, error) {
+	sopt := pdf.ScreenshotOptions{
+		Format:            string(img.Format),
+		Quality:           defaultInt(img.Quality, 90),
+		Width:             img.Width,
+		Height:            img.Height,
+		DeviceScaleFactor: img.DeviceScaleFactor,
+		FullPage:          img.FullPage,
+	}
+	switch img.Format {
+	case "":
+		sopt.Format = string(ImagePNG)
+	case ImagePNG, ImageJPEG, ImageWebP:
+	default:
+		return sopt, fmt.Errorf("ejspdf: unsupported image format %q", img.Format)
+	}
+	if img.Quality < 0 || img.Quality > 100 {
+		return sopt, fmt.Errorf("ejspdf: image quality must be between 1 and 100")
+	}
+	if img.Width < 0 || img.Height < 0 || img.DeviceScaleFactor < 0 {
+		return sopt, fmt.Errorf("ejspdf: invalid image size")
+	}
+	if c := img.Clip; c != nil {
+		if c.Width <= 0 || c.Height <= 0 {
+			return sopt, fmt.Errorf("ejspdf: image clip must have a positive size")
+		}
+		sopt.Clip = &pdf.Clip{X: c.X, Y: c.Y, Width: c.Width, Height: c.Height}
+	}
+	if sopt.DeviceScaleFactor == 0 {
+		sopt.DeviceScaleFactor = 1
+	}
+
+	if sopt.Width == 0 || sopt.Height == 0 {
+		w, h, err := pdf.New(chromeOptions(opt)).PageSizePixels()
+		if err != nil {
+			return sopt, fmt.Errorf("ejspdf: %w", err)
+		}
+		if sopt.Width == 0 {
+			sopt.Width = w
+		}
+		if sopt.Height == 0 {
+			sopt.Height = h
+		}
+	}
+	return sopt, nil
+}
@@ -4,16 +4,37 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"log/slog"
+	"math"
 	"time"
 
+	"github.com/chromedp/cdproto/emulation"
 	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
-	"github.com/yodsakorn-so/ejspdf/internal/browser"
+	"github.com/yodsakorn-so/ejspdf/browser"
 )
 
 // Options defines PDF rendering options.
 type Options struct {
 	ChromePath string
+	// RemoteURL is the DevTools address of a running browser to use
+	// instead of starting one, e.g. "ws://chrome:9222".
+	RemoteURL string
+
+	// Flags are extra command-line flags of a started browser, such as
+	// "--lang=th-TH", and Env extra NAME=value environment variables.
+	Flags []string
+	Env   []string
+	// Sandbox keeps Chrome's sandbox enabled; it is disabled by default.
+	Sandbox bool
+	// Browser configures the Chromium installed when ChromePath is empty
+	// and no browser is found.
+	Browser browser.Config
+	// Logger receives the browser messages. Default is slog.Default().
+	Logger *slog.Logger
+	// StrictVersion fails a print when the browser is too old for a
+	// requested option, instead of ignoring the option with a warning.
+	StrictVersion bool
 
 	PageSize  string
 	Landscape bool
@@ -40,6 +61,49 @@ type Options struct {
 	Scale            float64
 	PageRanges       string
 	IgnoreBackground bool
+
+	// Document structure
+	GenerateOutline bool
+	Tagged          bool
+
+	// Signature locates the data-ejspdf-signature placeholder.
+	Signature bool
+	// Sections locates the data-ejspdf-section elements.
+	Sections bool
+	// Fields locates the form elements to turn into form fields.
+	Fields bool
+
+	// Overlay is an HTML page printed on a single sheet of the same paper
+	// size, without margins or background, e.g. a watermark to stamp onto
+	// the document.
+	Overlay string
+}
+
+// Result is the output of a print run.
+type Result struct {
+	PDF []byte
+	// Browser is the version of the browser that printed the PDF, e.g.
+	// "HeadlessChrome/120.0.6099.109".
+	Browser string
+
+	// Lang is the lang attribute of the <html> element.
+	Lang string
+	// Headings are the outline entries marked in the page. They are only
+	// collected when GenerateOutline is set.
+	Headings []Heading
+	// Issues are the accessibility problems found in the page. They are
+	// only collected when Tagged is set.
+	Issues []Issue
+	// Signature is the signature placeholder, if Signature is set and the
+	// page has one.
+	Signature *Placeholder
+	// Sections are the data-ejspdf-section elements, in document order.
+	Sections []Section
+	// Fields are the form elements found if Fields is set, in document
+	// order.
+	Fields []Field
+	// Overlay is the printed Overlay page.
+	Overlay []byte
 }
 
 // Chrome represents a Chrome-based PDF renderer.
@@ -54,6 +118,16 @@ func New(opt Options) *Chrome {
 
 // FromHTML converts an HTML string into a PDF document.
 func (c *Chrome) FromHTML(ctx context.Context, html string) ([]byte, error) {
+	res, err := c.Print(ctx, html)
+	if err != nil {
+		return nil, err
+	}
+	return res.PDF, nil
+}
+
+// Print converts an HTML string into a PDF document and returns it along
+// with the information collected from the page.
+func (c *Chrome) Print(ctx context.Context, html string) (*Result, error) {
 	// 1. Validation & Unit Conversion
 	mt, mb, ml, mr, err := c.parseAllMargins()
 	if err != nil {
@@ -65,59 +139,45 @@ func (c *Chrome) FromHTML(ctx context.Context, html string) ([]byte, error) {
 		return nil, err
 	}
 
-	// 2. Chrome Setup (Reuse or Create)
-	// Check if the provided context already has a chromedp session
-	var chromeCtx context.Context
-	var cancel context.CancelFunc
+	res := &Result{}
 
-	if chromedp.FromContext(ctx) != nil {
-		// Reuse existing session, but create a new tab (context)
-		chromeCtx, cancel = chromedp.NewContext(ctx)
-	} else {
-		// Create new allocator and session
-		// Default options usually include Headless, DisableGPU, etc.
-		// We append NoSandbox to support running in CI/Docker environments.
-		allocOpts := append(chromedp.DefaultExecAllocatorOptions[:],
-			chromedp.NoSandbox,
-		)
-		
-		// Find or download browser
-		execPath := c.opt.ChromePath
-		if execPath == "" {
-			var err error
-			execPath, err = browser.FindOrDownload()
-			if err != nil {
-				return nil, fmt.Errorf("could not find or download chrome: %w", err)
-			}
+	// 2. Build Actions
+	// The browser version is checked before the page is loaded.
+	var support printSupport
+	actions := []chromedp.Action{chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		if res.Browser, err = browserVersion(ctx); err != nil {
+			return err
 		}
+		support, err = c.support(res.Browser)
+		return err
+	})}
+	actions = append(actions, c.load(html)...)
 
-		allocOpts = append(allocOpts, chromedp.ExecPath(execPath))
-		allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, allocOpts...)
-		defer allocCancel()
-
-		chromeCtx, cancel = chromedp.NewContext(allocCtx)
-	}
-	defer cancel()
-
-	var pdfBytes []byte
-
-	// 3. Prepare Content
-	encodedHTML := base64.StdEncoding.EncodeToString([]byte(html))
-	dataURL := "data:text/html;charset=utf-8;base64," + encodedHTML
-
-	// 4. Build Actions
-	actions := []chromedp.Action{
-		chromedp.Navigate(dataURL),
-	}
-
-	if c.opt.WaitSelector != "" {
-		actions = append(actions, chromedp.WaitVisible(c.opt.WaitSelector))
-	} else {
-		actions = append(actions, chromedp.WaitReady("body"))
+	// Mark elements whose printed position we need to know
+	inspect := inspectOptions{
+		Outline:       c.opt.GenerateOutline,
+		Accessibility: c.opt.Tagged,
+		Signature:     c.opt.Signature,
+		Sections:      c.opt.Sections,
+		Fields:        c.opt.Fields,
 	}
-
-	if c.opt.WaitDelay > 0 {
-		actions = append(actions, chromedp.Sleep(c.opt.WaitDelay))
+	if inspect.any() {
+		script := buildInspectScript(inspect)
+		actions = append(actions, c.printViewport(width, height, ml+mr, mt+mb))
+		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			var found inspection
+			if err := chromedp.Evaluate(script, &found).Do(ctx); err != nil {
+				return fmt.Errorf("inspect page: %w", err)
+			}
+			res.Lang = found.Lang
+			res.Headings = found.Headings
+			res.Issues = found.Issues
+			res.Signature = found.Signature
+			res.Sections = found.Sections
+			res.Fields = found.Fields
+			return nil
+		}))
 	}
 
 	// Handle Header/Footer defaults
@@ -141,7 +201,7 @@ func (c *Chrome) FromHTML(ctx context.Context, html string) ([]byte, error) {
 	// Print Action
 	actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
 		var err error
-		pdfBytes, _, err = page.PrintToPDF().
+		res.PDF, _, err = page.PrintToPDF().
 			WithPrintBackground(!c.opt.IgnoreBackground).
 			WithLandscape(c.opt.Landscape).
 			WithPaperWidth(width).
@@ -155,16 +215,207 @@ func (c *Chrome) FromHTML(ctx context.Context, html string) ([]byte, error) {
 			WithFooterTemplate(footerTpl).
 			WithScale(scale).
 			WithPageRanges(c.opt.PageRanges).
+			WithGenerateTaggedPDF(support.tagged).
+			WithGenerateDocumentOutline(support.outline).
 			Do(ctx)
 		return err
 	}))
 
-	// 5. Execute
+	if c.opt.Overlay != "" {
+		actions = append(actions,
+			chromedp.Navigate(dataURL(c.opt.Overlay)),
+			chromedp.WaitReady("body"),
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				var err error
+				res.Overlay, _, err = page.PrintToPDF().
+					WithPrintBackground(false).
+					WithLandscape(c.opt.Landscape).
+					WithPaperWidth(width).
+					WithPaperHeight(height).
+					WithMarginTop(0).
+					WithMarginBottom(0).
+					WithMarginLeft(0).
+					WithMarginRight(0).
+					WithPageRanges("1").
+					Do(ctx)
+				return err
+			}),
+		)
+	}
+
+	// 3. Execute
+	if err := c.run(ctx, actions...); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// printViewport lays the page out as it is printed, so that the boxes
+// measured by the inspect script match the printed ones: print media is
+// emulated and the viewport is the printable area of the paper, in CSS
+// pixels at the print scale. width and height are the paper size and
+// marginX and marginY the sum of the margins, in inches.
+func (c *Chrome) printViewport(width, height, marginX, marginY float64) chromedp.Action {
+	if c.opt.Landscape {
+		width, height = height, width
+	}
+	scale := c.opt.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	w := int64(math.Round((width - marginX) * 96 / scale))
+	h := int64(math.Round((height - marginY) * 96 / scale))
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := emulation.SetEmulatedMedia().WithMedia("print").Do(ctx); err != nil {
+			return fmt.Errorf("emulate print media: %w", err)
+		}
+		if err := emulation.SetDeviceMetricsOverride(max(w, 1), max(h, 1), 1, false).Do(ctx); err != nil {
+			return fmt.Errorf("set print viewport: %w", err)
+		}
+		return nil
+	})
+}
+
+// load returns the actions that open html and wait until it is ready.
+func (c *Chrome) load(html string) []chromedp.Action {
+	actions := []chromedp.Action{
+		chromedp.Navigate(dataURL(html)),
+	}
+
+	if c.opt.WaitSelector != "" {
+		actions = append(actions, chromedp.WaitVisible(c.opt.WaitSelector))
+	} else {
+		actions = append(actions, chromedp.WaitReady("body"))
+	}
+
+	if c.opt.WaitDelay > 0 {
+		actions = append(actions, chromedp.Sleep(c.opt.WaitDelay))
+	}
+	return actions
+}
+
+// run executes actions in a new tab. If ctx already has a chromedp
+// session it is reused; otherwise the remote browser is used, or a
+// browser is started.
+func (c *Chrome) run(ctx context.Context, actions ...chromedp.Action) error {
+	var chromeCtx context.Context
+	var cancel context.CancelFunc
+
+	if chromedp.FromContext(ctx) != nil {
+		// Reuse existing session, but create a new tab (context)
+		chromeCtx, cancel = chromedp.NewContext(ctx)
+	} else if c.opt.RemoteURL != "" {
+		return c.runRemote(ctx, actions...)
+	} else {
+		// Create new allocator and session
+		allocCtx, allocCancel, err := c.allocator(ctx, ctx)
+		if err != nil {
+			return err
+		}
+		defer allocCancel()
+
+		chromeCtx, cancel = chromedp.NewContext(allocCtx)
+	}
+	defer cancel()
+
 	if err := chromedp.Run(chromeCtx, actions...); err != nil {
-		return nil, fmt.Errorf("chromedp run failed: %w", err)
+		return fmt.Errorf("chromedp run failed: %w", err)
+	}
+	return nil
+}
+
+// allocator finds or downloads the local browser, using ctx, and returns
+// an allocator that starts it, derived from parent.
+func (c *Chrome) allocator(ctx, parent context.Context) (context.Context, context.CancelFunc, error) {
+	execPath := c.opt.ChromePath
+	if execPath == "" {
+		cfg := c.opt.Browser
+		if cfg.Logger == nil {
+			cfg.Logger = c.opt.Logger
+		}
+		var err error
+		execPath, err = browser.FindOrDownload(ctx, cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not find or download chrome: %w", err)
+		}
+	}
+
+	c.logger().Debug("starting chrome", "path", execPath)
+	allocOpts, err := c.allocatorOptions(execPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	allocCtx, cancel := chromedp.NewExecAllocator(parent, allocOpts...)
+	return allocCtx, cancel, nil
+}
+
+// Start starts the local browser and returns a context holding its
+// session, which runs with a context derived from it reuse. The browser
+// outlives ctx, which only bounds finding and starting it, and is closed
+// by cancel.
+func (c *Chrome) Start(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	allocCtx, allocCancel, err := c.allocator(ctx, context.WithoutCancel(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	browserCtx, browserCancel := chromedp.NewContext(allocCtx)
+	cancel := func() {
+		browserCancel()
+		allocCancel()
+	}
+	// The first run launches the browser.
+	stop := context.AfterFunc(ctx, cancel)
+	err = chromedp.Run(browserCtx)
+	if !stop() || err != nil {
+		cancel()
+		if err == nil {
+			err = ctx.Err()
+		}
+		return nil, nil, fmt.Errorf("start chrome: %w", err)
+	}
+	return browserCtx, cancel, nil
+}
+
+// HasSession reports whether ctx holds a browser session, e.g. from
+// Start.
+func HasSession(ctx context.Context) bool {
+	return chromedp.FromContext(ctx) != nil
+}
+
+// Ping checks that the browser of the session in ctx answers.
+func Ping(ctx context.Context) error {
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		_, err := browserVersion(ctx)
+		return err
+	}))
+	if err != nil {
+		return fmt.Errorf("chrome not responding: %w", err)
 	}
+	return nil
+}
+
+func (c *Chrome) logger() *slog.Logger {
+	if c.opt.Logger == nil {
+		return slog.Default()
+	}
+	return c.opt.Logger
+}
+
+// dataURL encodes an HTML document as a data: URL.
+func dataURL(html string) string {
+	return "data:text/html;charset=utf-8;base64," + base64.StdEncoding.EncodeToString([]byte(html))
+}
+
+// PaperSize returns the paper width and height in inches, before the
+// orientation is applied.
+func (c *Chrome) PaperSize() (width, height float64, err error) {
+	return c.calculateDimensions()
+}
 
-	return pdfBytes, nil
+// Margins returns the page margins in inches.
+func (c *Chrome) Margins() (top, bottom, left, right float64, err error) {
+	return c.parseAllMargins()
 }
 
 func (c *Chrome) parseAllMargins() (mt, mb, ml, mr float64, err error) {
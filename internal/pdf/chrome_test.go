@@ -0,0 +1,39 @@
+package pdf
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPrintFieldBoxes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping chrome test in short mode")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// The box of a field is measured in the print layout: here the
+	// printable width is 80mm and the input takes half of it in print.
+	c := New(Options{
+		PaperWidth: "100mm", PaperHeight: "100mm",
+		MarginTop: "10mm", MarginBottom: "10mm", MarginLeft: "10mm", MarginRight: "10mm",
+		Fields: true,
+	})
+	res, err := c.Print(ctx, `<style>
+		body { margin: 0 }
+		input { box-sizing: border-box; width: 100%; height: 20px; border: 0 }
+		@media print { input { width: 50% } }
+	</style><input name="customer">`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Fields) != 1 {
+		t.Fatalf("found %d fields, want 1", len(res.Fields))
+	}
+	f := res.Fields[0]
+	if want := 40 / 25.4 * 96; math.Abs(f.Width-want) > 1 || math.Abs(f.Height-20) > 0.5 {
+		t.Errorf("field box %.1fx%.1f px, want %.1fx20", f.Width, f.Height, want)
+	}
+}
@@ -0,0 +1,79 @@
+package pdf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// reservedFlags are managed by ejspdf or chromedp and cannot be set in
+// Options.Flags.
+var reservedFlags = map[string]string{
+	"remote-debugging-port": "it is chosen by chromedp",
+	"remote-debugging-pipe": "chromedp connects over a port",
+	"no-sandbox":            "use the Sandbox option",
+}
+
+// parseFlag splits a command-line flag such as "--lang=th-TH" or
+// "--disable-gpu" into its name and value. A flag without a value is
+// true; "false" drops a default flag.
+func parseFlag(flag string) (string, any, error) {
+	if !strings.HasPrefix(flag, "--") || len(flag) == 2 {
+		return "", nil, fmt.Errorf("invalid chrome flag %q: must start with --", flag)
+	}
+	name, value, hasValue := strings.Cut(flag[2:], "=")
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return "", nil, fmt.Errorf("invalid chrome flag %q", flag)
+	}
+	if why, ok := reservedFlags[name]; ok {
+		return "", nil, fmt.Errorf("chrome flag --%s cannot be set: %s", name, why)
+	}
+	switch {
+	case !hasValue, value == "true":
+		return name, true, nil
+	case value == "false":
+		return name, false, nil
+	}
+	return name, value, nil
+}
+
+// ValidateLaunch checks launch flags and environment variables.
+func ValidateLaunch(flags, env []string) error {
+	for _, f := range flags {
+		if _, _, err := parseFlag(f); err != nil {
+			return err
+		}
+	}
+	for _, e := range env {
+		if name, _, ok := strings.Cut(e, "="); !ok || name == "" {
+			return fmt.Errorf("invalid chrome environment variable %q: must be NAME=value", e)
+		}
+	}
+	return nil
+}
+
+// allocatorOptions returns the options of a locally started browser:
+// chromedp's defaults, the sandbox setting, then the custom flags and
+// environment, which override the defaults.
+func (c *Chrome) allocatorOptions(execPath string) ([]chromedp.ExecAllocatorOption, error) {
+	opts := append([]chromedp.ExecAllocatorOption{}, chromedp.DefaultExecAllocatorOptions[:]...)
+	if !c.opt.Sandbox {
+		// Chrome's sandbox does not work in most CI/Docker environments.
+		opts = append(opts, chromedp.NoSandbox)
+	}
+	for _, f := range c.opt.Flags {
+		name, value, err := parseFlag(f)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, chromedp.Flag(name, value))
+	}
+	if err := ValidateLaunch(nil, c.opt.Env); err != nil {
+		return nil, err
+	}
+	if len(c.opt.Env) > 0 {
+		opts = append(opts, chromedp.Env(c.opt.Env...))
+	}
+	return append(opts, chromedp.ExecPath(execPath)), nil
+}
@@ -0,0 +1,60 @@
+package pdf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFlag(t *testing.T) {
+	for _, tc := range []struct {
+		flag  string
+		name  string
+		value any
+	}{
+		{"--lang=th-TH", "lang", "th-TH"},
+		{"--font-render-hinting=none", "font-render-hinting", "none"},
+		{"--disable-gpu", "disable-gpu", true},
+		{"--headless=false", "headless", false},
+		{"--window-size=1280,720", "window-size", "1280,720"},
+	} {
+		name, value, err := parseFlag(tc.flag)
+		if err != nil {
+			t.Errorf("%s: %v", tc.flag, err)
+			continue
+		}
+		if name != tc.name || !reflect.DeepEqual(value, tc.value) {
+			t.Errorf("%s: got %s=%v", tc.flag, name, value)
+		}
+	}
+	for _, flag := range []string{"lang=th", "-lang", "--", "--=x", "--no-sandbox", "--remote-debugging-port=9222"} {
+		if _, _, err := parseFlag(flag); err == nil {
+			t.Errorf("%s: expected an error", flag)
+		}
+	}
+}
+
+func TestValidateLaunch(t *testing.T) {
+	if err := ValidateLaunch([]string{"--lang=th-TH"}, []string{"TZ=Asia/Bangkok", "EMPTY="}); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateLaunch(nil, []string{"TZ"}); err == nil {
+		t.Error("expected an error for a variable without a value")
+	}
+	if err := ValidateLaunch(nil, []string{"=x"}); err == nil {
+		t.Error("expected an error for a variable without a name")
+	}
+
+	c := New(Options{Flags: []string{"--lang=th-TH"}, Env: []string{"TZ=UTC"}})
+	opts, err := c.allocatorOptions("/usr/bin/chromium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sandboxed, err := New(Options{Sandbox: true}).allocatorOptions("/usr/bin/chromium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The sandboxed browser has no --no-sandbox, flags or environment.
+	if len(opts) != len(sandboxed)+3 {
+		t.Errorf("got %d and %d allocator options", len(opts), len(sandboxed))
+	}
+}
@@ -0,0 +1,242 @@
+package pdf
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// AnchorPrefix prefixes the ids of the marker elements injected into the
+// page. Chrome emits a named destination for each of them, which tells us
+// on which page (and where) the marked element was printed.
+const AnchorPrefix = "ejspdf-"
+
+// Heading is an outline entry found in the rendered page.
+type Heading struct {
+	Anchor string `json:"anchor"`
+	Title  string `json:"title"`
+	Level  int    `json:"level"`
+}
+
+// Issue is an accessibility problem found in the rendered page.
+type Issue struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	Element string `json:"element"`
+}
+
+// Placeholder is an element whose printed box is needed, e.g. to place a
+// signature widget over it. Sizes are in CSS pixels; OffsetX and OffsetY
+// give the position of the anchor relative to the top-left corner of the
+// element's border box.
+type Placeholder struct {
+	Anchor  string  `json:"anchor"`
+	Name    string  `json:"name"`
+	Width   float64 `json:"width"`
+	Height  float64 `json:"height"`
+	OffsetX float64 `json:"offsetX"`
+	OffsetY float64 `json:"offsetY"`
+}
+
+// Section is an element marked with data-ejspdf-section. Start and End
+// are the anchors at the beginning and at the end of the element.
+type Section struct {
+	ID    string `json:"id"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Field is a form element (or an element marked with data-ejspdf-field)
+// to turn into an interactive form field. Type is "text", "checkbox" or
+// "signature"; FontSize is in CSS pixels.
+type Field struct {
+	Placeholder
+	Type      string  `json:"type"`
+	Value     string  `json:"value"`
+	Checked   bool    `json:"checked"`
+	Multiline bool    `json:"multiline"`
+	Password  bool    `json:"password"`
+	ReadOnly  bool    `json:"readOnly"`
+	Required  bool    `json:"required"`
+	MaxLength int     `json:"maxLength"`
+	FontSize  float64 `json:"fontSize"`
+}
+
+// inspection is the data collected from the page before printing.
+type inspection struct {
+	Lang      string       `json:"lang"`
+	Headings  []Heading    `json:"headings"`
+	Issues    []Issue      `json:"issues"`
+	Signature *Placeholder `json:"signature"`
+	Sections  []Section    `json:"sections"`
+	Fields    []Field      `json:"fields"`
+}
+
+type inspectOptions struct {
+	Outline       bool `json:"outline"`
+	Accessibility bool `json:"accessibility"`
+	Signature     bool `json:"signature"`
+	Sections      bool `json:"sections"`
+	Fields        bool `json:"fields"`
+}
+
+func (o inspectOptions) any() bool {
+	return o.Outline || o.Accessibility || o.Signature || o.Sections || o.Fields
+}
+
+// inspectScript marks elements of interest with anchors and returns what
+// it found. Each marker is an empty <span id="ejspdf-..."> inserted as the
+// first (or last) child of the element, or before elements that cannot
+// render children such as <input>, referenced by a hidden link so
+// that Chrome outputs a named destination for it.
+const inspectScript = `(function (opts) {
+	var prefix = %q;
+	var anchors = document.createElement('div');
+	anchors.setAttribute('data-ejspdf-anchors', '');
+	anchors.style.cssText = 'position:absolute;left:0;top:0;width:0;height:0;overflow:hidden;';
+	var seq = 0;
+	function mark(el, kind, atEnd) {
+		var id = prefix + kind + '-' + (++seq);
+		var span = document.createElement('span');
+		span.id = id;
+		if (/^(INPUT|TEXTAREA|SELECT|IMG)$/.test(el.tagName)) {
+			el.parentNode.insertBefore(span, el);
+		} else {
+			el.insertBefore(span, atEnd ? null : el.firstChild);
+		}
+		var a = document.createElement('a');
+		a.href = '#' + id;
+		anchors.appendChild(a);
+		return id;
+	}
+	function text(el) {
+		return (el.textContent || '').replace(/\s+/g, ' ').trim();
+	}
+	function describe(el) {
+		var s = el.tagName.toLowerCase();
+		if (el.id) s += '#' + el.id;
+		if (el.classList && el.classList.length) s += '.' + Array.prototype.join.call(el.classList, '.');
+		if (el.tagName === 'IMG' && el.getAttribute('src')) {
+			var src = el.getAttribute('src');
+			s += '[src="' + (src.length > 60 ? src.slice(0, 60) + '...' : src) + '"]';
+		}
+		return s;
+	}
+
+	function placeholder(el, kind, name) {
+		var anchor = mark(el, kind);
+		var box = el.getBoundingClientRect();
+		var at = document.getElementById(anchor).getBoundingClientRect();
+		return {
+			anchor: anchor, name: name || '',
+			width: box.width, height: box.height,
+			offsetX: at.left - box.left, offsetY: at.top - box.top
+		};
+	}
+
+	var out = { lang: document.documentElement.lang || '', headings: [], issues: [], signature: null, sections: [], fields: [] };
+
+	if (opts.outline) {
+		var els = document.querySelectorAll('h1,h2,h3,h4,h5,h6,[data-ejspdf-outline]');
+		for (var i = 0; i < els.length; i++) {
+			var el = els[i];
+			var attr = el.getAttribute('data-ejspdf-outline');
+			if (attr === 'false') continue;
+			var title = attr && attr !== 'true' ? attr : text(el);
+			if (!title) continue;
+			var level = parseInt(el.getAttribute('data-ejspdf-outline-level'), 10);
+			if (!level) level = /^H[1-6]$/.test(el.tagName) ? +el.tagName.charAt(1) : 1;
+			out.headings.push({ anchor: mark(el, 'h'), title: title, level: level });
+		}
+	}
+
+	if (opts.accessibility) {
+		if (!out.lang) {
+			out.issues.push({ rule: 'document-lang', message: 'the <html> element has no lang attribute', element: 'html' });
+		}
+		var imgs = document.querySelectorAll('img, [role="img"]');
+		for (var i = 0; i < imgs.length; i++) {
+			var img = imgs[i];
+			if (img.closest('[aria-hidden="true"]')) continue;
+			var named = img.hasAttribute('alt') || img.getAttribute('aria-label') || img.getAttribute('aria-labelledby') || img.getAttribute('title');
+			if (!named) {
+				out.issues.push({ rule: 'image-alt', message: 'image has no alternative text', element: describe(img) });
+			}
+		}
+		var hs = document.querySelectorAll('h1,h2,h3,h4,h5,h6');
+		var prev = 0;
+		for (var i = 0; i < hs.length; i++) {
+			var level = +hs[i].tagName.charAt(1);
+			if (prev === 0 && level !== 1) {
+				out.issues.push({ rule: 'heading-order', message: 'first heading is h' + level + ', expected h1', element: describe(hs[i]) });
+			} else if (prev > 0 && level > prev + 1) {
+				out.issues.push({ rule: 'heading-order', message: 'heading level skips from h' + prev + ' to h' + level, element: describe(hs[i]) });
+			}
+			prev = level;
+		}
+	}
+
+	if (opts.signature) {
+		var sig = document.querySelector('[data-ejspdf-signature]');
+		if (sig) out.signature = placeholder(sig, 's', sig.getAttribute('data-ejspdf-signature'));
+	}
+
+	if (opts.sections) {
+		var secs = document.querySelectorAll('[data-ejspdf-section]');
+		for (var i = 0; i < secs.length; i++) {
+			out.sections.push({
+				id: secs[i].getAttribute('data-ejspdf-section'),
+				start: mark(secs[i], 'sec'),
+				end: mark(secs[i], 'end', true)
+			});
+		}
+	}
+
+	if (opts.fields) {
+		var textTypes = /^(|text|email|number|tel|url|search|date|time|datetime-local|month|week|password)$/;
+		var els = document.querySelectorAll('input, textarea, [data-ejspdf-field]');
+		var hide = [];
+		for (var i = 0; i < els.length; i++) {
+			var el = els[i];
+			var type = el.getAttribute('data-ejspdf-field');
+			var input = el.tagName === 'INPUT' || el.tagName === 'TEXTAREA';
+			if (type === 'false') continue;
+			if (!type) {
+				var t = (el.getAttribute('type') || '').toLowerCase();
+				if (el.tagName === 'TEXTAREA' || (el.tagName === 'INPUT' && textTypes.test(t))) type = 'text';
+				else if (el.tagName === 'INPUT' && t === 'checkbox') type = 'checkbox';
+				else continue;
+			}
+			if (type !== 'text' && type !== 'checkbox' && type !== 'signature') continue;
+			var box = el.getBoundingClientRect();
+			if (!box.width || !box.height) continue;
+			var name = el.getAttribute('data-ejspdf-field-name') || el.getAttribute('name') || el.id || type + (out.fields.length + 1);
+			var f = placeholder(el, 'f', name);
+			f.type = type;
+			f.value = input ? el.value : (el.getAttribute('data-ejspdf-field-value') || '');
+			f.checked = input ? el.checked : el.hasAttribute('data-ejspdf-field-checked');
+			f.multiline = el.tagName === 'TEXTAREA' || el.hasAttribute('data-ejspdf-field-multiline');
+			f.password = el.tagName === 'INPUT' && (el.getAttribute('type') || '').toLowerCase() === 'password';
+			f.readOnly = el.hasAttribute('readonly') || el.disabled === true;
+			f.required = el.hasAttribute('required');
+			f.maxLength = el.maxLength > 0 ? el.maxLength : 0;
+			f.fontSize = parseFloat(getComputedStyle(el).fontSize) || 0;
+			out.fields.push(f);
+			if (input) hide.push(el);
+		}
+		// The fields show their own value, so the printed one is hidden.
+		for (var i = 0; i < hide.length; i++) {
+			hide[i].style.color = 'transparent';
+			hide[i].style.webkitTextFillColor = 'transparent';
+			hide[i].removeAttribute('placeholder');
+			if (hide[i].type === 'checkbox') hide[i].checked = false;
+		}
+	}
+
+	if (document.body) document.body.appendChild(anchors);
+	return out;
+})(%s)`
+
+func buildInspectScript(opts inspectOptions) string {
+	b, _ := json.Marshal(opts)
+	return fmt.Sprintf(inspectScript, AnchorPrefix, string(b))
+}
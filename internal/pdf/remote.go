@@ -0,0 +1,122 @@
+package pdf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// remoteAttempts is the number of times a remote browser is tried
+// before a render fails, and remoteBackoff the delay before the first
+// retry. The delay doubles on each retry.
+const (
+	remoteAttempts = 3
+	remoteBackoff  = 500 * time.Millisecond
+)
+
+// RemoteVersion describes a browser reachable over the DevTools protocol.
+type RemoteVersion struct {
+	Browser              string `json:"Browser"`
+	ProtocolVersion      string `json:"Protocol-Version"`
+	WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+}
+
+// CheckRemote queries the /json/version endpoint of a browser started
+// with --remote-debugging-port. rawURL is its DevTools WebSocket URL or
+// its http:// address.
+func CheckRemote(ctx context.Context, rawURL string) (*RemoteVersion, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote url: %w", err)
+	}
+	switch u.Scheme {
+	case "ws", "http":
+		u.Scheme = "http"
+	case "wss", "https":
+		u.Scheme = "https"
+	default:
+		return nil, fmt.Errorf("invalid remote url %q: unsupported scheme", rawURL)
+	}
+	u.Path, u.RawQuery = "/json/version", ""
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("remote chrome unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote chrome unhealthy: %s", resp.Status)
+	}
+	var v RemoteVersion
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return nil, fmt.Errorf("remote chrome: invalid version response: %w", err)
+	}
+	return &v, nil
+}
+
+// runRemote executes actions in a new tab of the remote browser. Each
+// render opens its own connection, so a restarted browser is picked up
+// by the next render. The browser is checked before connecting, and the
+// render is retried if the browser went away during it. Each attempt
+// connects to the WebSocket URL the check returns, as a restarted
+// browser has a new id.
+func (c *Chrome) runRemote(ctx context.Context, actions ...chromedp.Action) error {
+	delay := remoteBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		var v *RemoteVersion
+		if v, err = CheckRemote(ctx, c.opt.RemoteURL); err == nil {
+			if err = c.runRemoteOnce(ctx, debuggerURL(c.opt.RemoteURL, v), actions...); err == nil {
+				return nil
+			}
+			if _, health := CheckRemote(ctx, c.opt.RemoteURL); health == nil {
+				// The browser is fine: the render itself failed.
+				return err
+			}
+		}
+		if attempt == remoteAttempts {
+			return err
+		}
+		c.logger().Warn("remote chrome failed, retrying", "attempt", attempt, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (c *Chrome) runRemoteOnce(ctx context.Context, wsURL string, actions ...chromedp.Action) error {
+	allocCtx, allocCancel := chromedp.NewRemoteAllocator(ctx, wsURL)
+	defer allocCancel()
+
+	chromeCtx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	if err := chromedp.Run(chromeCtx, actions...); err != nil {
+		return fmt.Errorf("chromedp run failed: %w", err)
+	}
+	return nil
+}
+
+// debuggerURL returns the WebSocket URL of the browser described by v,
+// or rawURL if the browser did not report one. The browser builds the
+// URL from the Host header of the check, so it is reachable as rawURL is.
+func debuggerURL(rawURL string, v *RemoteVersion) string {
+	if v.WebSocketDebuggerURL == "" {
+		return rawURL
+	}
+	return v.WebSocketDebuggerURL
+}
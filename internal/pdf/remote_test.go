@@ -0,0 +1,109 @@
+package pdf
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckRemote(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/json/version" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"Browser": "HeadlessChrome/120.0.6099.109", "Protocol-Version": "1.3",
+			"webSocketDebuggerUrl": "ws://127.0.0.1:9222/devtools/browser/abc"}`))
+	}))
+	defer srv.Close()
+
+	ws := "ws" + strings.TrimPrefix(srv.URL, "http") + "/devtools/browser/abc"
+	for _, u := range []string{srv.URL, ws} {
+		v, err := CheckRemote(context.Background(), u)
+		if err != nil {
+			t.Fatalf("%s: %v", u, err)
+		}
+		if v.Browser != "HeadlessChrome/120.0.6099.109" || v.WebSocketDebuggerURL == "" {
+			t.Errorf("%s: unexpected version %+v", u, v)
+		}
+	}
+
+	if _, err := CheckRemote(context.Background(), "ftp://chrome:9222"); err == nil {
+		t.Error("expected an error for an unsupported scheme")
+	}
+}
+
+func TestRunRemoteRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c := New(Options{
+		RemoteURL: "ws" + strings.TrimPrefix(srv.URL, "http"),
+		MarginTop: "0mm", MarginBottom: "0mm", MarginLeft: "0mm", MarginRight: "0mm",
+	})
+	_, err := c.FromHTML(context.Background(), "<p>x</p>")
+	if err == nil || !strings.Contains(err.Error(), "unhealthy") {
+		t.Fatalf("expected a health check error, got %v", err)
+	}
+	if n := calls.Load(); n != remoteAttempts {
+		t.Errorf("browser checked %d times, want %d", n, remoteAttempts)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.FromHTML(ctx, "<p>x</p>"); err == nil {
+		t.Error("expected an error for a canceled context")
+	}
+}
+
+func TestRunRemoteRestart(t *testing.T) {
+	// The browser restarts during the first render and comes back with a
+	// new id, which the retry must connect to.
+	var checks atomic.Int32
+	var mu sync.Mutex
+	var dialed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/json/version" {
+			mu.Lock()
+			dialed = append(dialed, r.URL.Path)
+			mu.Unlock()
+			http.NotFound(w, r)
+			return
+		}
+		id := "old"
+		switch checks.Add(1) {
+		case 1:
+		case 2:
+			http.Error(w, "restarting", http.StatusServiceUnavailable)
+			return
+		default:
+			id = "new"
+		}
+		fmt.Fprintf(w, `{"Browser": "HeadlessChrome/120.0.6099.109", "webSocketDebuggerUrl": "ws://%s/devtools/browser/%s"}`, r.Host, id)
+	}))
+	defer srv.Close()
+
+	c := New(Options{
+		RemoteURL: "ws" + strings.TrimPrefix(srv.URL, "http") + "/devtools/browser/old",
+		MarginTop: "0mm", MarginBottom: "0mm", MarginLeft: "0mm", MarginRight: "0mm",
+	})
+	if _, err := c.FromHTML(context.Background(), "<p>x</p>"); err == nil {
+		t.Fatal("expected an error from the fake browser")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"/devtools/browser/old", "/devtools/browser/new"}
+	if !slices.Equal(dialed, want) {
+		t.Errorf("dialed %q, want %q", dialed, want)
+	}
+}
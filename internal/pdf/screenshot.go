@@ -0,0 +1,93 @@
+package pdf
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// ScreenshotOptions defines screenshot options. Sizes are in CSS pixels.
+type ScreenshotOptions struct {
+	// Format is "png", "jpeg" or "webp".
+	Format string
+	// Quality is the compression quality of JPEG and WebP images, 0-100.
+	Quality int
+
+	// Width and Height are the size of the viewport.
+	Width  int
+	Height int
+	// DeviceScaleFactor is the number of device pixels per CSS pixel.
+	DeviceScaleFactor float64
+
+	// FullPage captures the whole page instead of the viewport.
+	FullPage bool
+	// Clip captures the given area of the page.
+	Clip *Clip
+}
+
+// Clip is an area of the page.
+type Clip struct {
+	X, Y, Width, Height float64
+}
+
+// Screenshot renders an HTML string in a viewport of the given size and
+// captures it as an image.
+func (c *Chrome) Screenshot(ctx context.Context, html string, opt ScreenshotOptions) ([]byte, error) {
+	format := page.CaptureScreenshotFormat(opt.Format)
+	switch format {
+	case page.CaptureScreenshotFormatPng, page.CaptureScreenshotFormatJpeg, page.CaptureScreenshotFormatWebp:
+	default:
+		return nil, fmt.Errorf("unsupported image format %q", opt.Format)
+	}
+
+	var img []byte
+	actions := []chromedp.Action{
+		emulation.SetDeviceMetricsOverride(int64(opt.Width), int64(opt.Height), opt.DeviceScaleFactor, false),
+	}
+	actions = append(actions, c.load(html)...)
+	actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+		capture := page.CaptureScreenshot().WithFormat(format).WithFromSurface(true)
+		if format != page.CaptureScreenshotFormatPng {
+			capture = capture.WithQuality(int64(opt.Quality))
+		}
+		switch {
+		case opt.Clip != nil:
+			capture = capture.
+				WithClip(&page.Viewport{X: opt.Clip.X, Y: opt.Clip.Y, Width: opt.Clip.Width, Height: opt.Clip.Height, Scale: 1}).
+				WithCaptureBeyondViewport(true)
+		case opt.FullPage:
+			_, _, _, _, _, size, err := page.GetLayoutMetrics().Do(ctx)
+			if err != nil {
+				return err
+			}
+			capture = capture.
+				WithClip(&page.Viewport{Width: math.Ceil(size.Width), Height: math.Ceil(size.Height), Scale: 1}).
+				WithCaptureBeyondViewport(true)
+		}
+		var err error
+		img, err = capture.Do(ctx)
+		return err
+	}))
+
+	if err := c.run(ctx, actions...); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// PageSizePixels returns the paper size in CSS pixels.
+func (c *Chrome) PageSizePixels() (width, height int, err error) {
+	w, h, err := c.calculateDimensions()
+	if err != nil {
+		return 0, 0, err
+	}
+	if c.opt.Landscape {
+		w, h = h, w
+	}
+	// Paper sizes are in inches; CSS has 96 pixels per inch.
+	return int(math.Round(w * 96)), int(math.Round(h * 96)), nil
+}
@@ -0,0 +1,86 @@
+package pdf
+
+import (
+	"context"
+	"fmt"
+
+	cdpbrowser "github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/chromedp"
+	"github.com/yodsakorn-so/ejspdf/browser"
+)
+
+// The first Chrome versions whose PrintToPDF supports the tagged PDF and
+// document outline parameters. Older browsers reject or ignore them; the
+// outline is then built from the marked headings instead.
+const (
+	minTaggedVersion  = 116
+	minOutlineVersion = 126
+)
+
+// printSupport is what a print run asks of the browser, once the
+// options it does not support are dropped.
+type printSupport struct {
+	tagged  bool
+	outline bool
+}
+
+// support checks the browser version against the requested options.
+// With StrictVersion, an unsupported option is an error; otherwise it is
+// dropped with a warning. GenerateOutline is always met: without Chrome's
+// outline, the marked headings are used. An unknown version is assumed
+// to support everything.
+func (c *Chrome) support(version string) (printSupport, error) {
+	s := printSupport{
+		// Chrome builds the outline from the tagged structure tree
+		tagged:  c.opt.Tagged || c.opt.GenerateOutline,
+		outline: c.opt.GenerateOutline,
+	}
+	major, ok := browser.MajorVersion(version)
+	if !ok {
+		c.logger().Warn("unknown browser version, assuming all features", "version", version)
+		return s, nil
+	}
+	if s.outline && major < minOutlineVersion {
+		s.outline = false
+		s.tagged = c.opt.Tagged
+		c.logger().Debug("browser cannot generate the outline, building it from the headings",
+			"version", version, "required", minOutlineVersion)
+	}
+	unsupported := func(option string, min int) error {
+		if c.opt.StrictVersion {
+			return fmt.Errorf("%s requires Chrome %d or later, found %s", option, min, version)
+		}
+		c.logger().Warn("browser too old for option, ignoring it",
+			"option", option, "version", version, "required", min)
+		return nil
+	}
+	if s.tagged && major < minTaggedVersion {
+		s.tagged = false
+		if err := unsupported("Tagged", minTaggedVersion); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// browserVersion returns the product of the connected browser, e.g.
+// "HeadlessChrome/120.0.6099.109".
+func browserVersion(ctx context.Context) (string, error) {
+	_, product, _, _, _, err := cdpbrowser.GetVersion().Do(ctx)
+	if err != nil {
+		return "", fmt.Errorf("get browser version: %w", err)
+	}
+	return product, nil
+}
+
+// Version starts or connects to the browser, like a render does, and
+// returns its version.
+func (c *Chrome) Version(ctx context.Context) (string, error) {
+	var version string
+	err := c.run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		version, err = browserVersion(ctx)
+		return err
+	}))
+	return version, err
+}
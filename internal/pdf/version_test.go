@@ -0,0 +1,53 @@
+package pdf
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSupport(t *testing.T) {
+	tests := []struct {
+		name     string
+		opt      Options
+		version  string
+		want     printSupport
+		warnings int
+	}{
+		{"recent", Options{Tagged: true, GenerateOutline: true}, "HeadlessChrome/130.0.6723.58", printSupport{true, true}, 0},
+		{"outline implies tags", Options{GenerateOutline: true}, "HeadlessChrome/130.0.6723.58", printSupport{true, true}, 0},
+		{"no outline", Options{GenerateOutline: true}, "HeadlessChrome/120.0.6099.109", printSupport{}, 0},
+		{"no outline, tagged", Options{Tagged: true, GenerateOutline: true}, "HeadlessChrome/120.0.6099.109", printSupport{true, false}, 0},
+		{"old", Options{Tagged: true, GenerateOutline: true}, "HeadlessChrome/109.0.5414.0", printSupport{}, 1},
+		{"old outline", Options{GenerateOutline: true}, "HeadlessChrome/109.0.5414.0", printSupport{}, 0},
+		{"not requested", Options{}, "HeadlessChrome/90.0.4430.0", printSupport{}, 0},
+		{"unknown", Options{Tagged: true}, "Chrome", printSupport{tagged: true}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.opt.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+			got, err := New(tt.opt).support(tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("support = %+v, want %+v", got, tt.want)
+			}
+			if n := strings.Count(buf.String(), "level=WARN"); n != tt.warnings {
+				t.Errorf("%d warnings, want %d:\n%s", n, tt.warnings, buf.String())
+			}
+		})
+	}
+
+	strict := New(Options{Tagged: true, StrictVersion: true})
+	if _, err := strict.support("HeadlessChrome/109.0.5414.0"); err == nil || !strings.Contains(err.Error(), "Tagged requires Chrome 116") {
+		t.Errorf("expected a version error, got %v", err)
+	}
+	// The outline is built from the headings when Chrome cannot do it.
+	strict = New(Options{GenerateOutline: true, StrictVersion: true})
+	if _, err := strict.support("HeadlessChrome/109.0.5414.0"); err != nil {
+		t.Errorf("unexpected error for GenerateOutline: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"crypto/md5"
+	"slices"
+	"sort"
+	"time"
+)
+
+// EmbeddedFile is a file attached to the document.
+type EmbeddedFile struct {
+	Name string
+	// MIMEType is the media type, written as the /Subtype PDF/A-3
+	// requires. Default is "application/octet-stream".
+	MIMEType    string
+	Data        []byte
+	Description string
+	// Relationship is the PDF/A-3 associated file relationship: Source,
+	// Data, Alternative, Supplement or Unspecified (the default).
+	Relationship string
+	// ModTime is the modification date. Default is now.
+	ModTime time.Time
+}
+
+// AttachFile embeds a file in the document. It is listed in the
+// /EmbeddedFiles name tree and, as PDF/A-3 requires, in the associated
+// files (/AF) of the document. A file of the same name is replaced.
+func (d *Document) AttachFile(f EmbeddedFile) Ref {
+	if f.ModTime.IsZero() {
+		f.ModTime = time.Now()
+	}
+	if f.Relationship == "" {
+		f.Relationship = "Unspecified"
+	}
+	if f.MIMEType == "" {
+		f.MIMEType = "application/octet-stream"
+	}
+	sum := md5.Sum(f.Data)
+	ef := Dict{
+		"Type":    Name("EmbeddedFile"),
+		"Subtype": Name(f.MIMEType),
+		"Params": Dict{
+			"Size":     len(f.Data),
+			"ModDate":  FormatDate(f.ModTime),
+			"CheckSum": String(sum[:]),
+		},
+	}
+	stream := d.Add(NewStream(ef, f.Data))
+
+	spec := Dict{
+		"Type":           Name("Filespec"),
+		"F":              TextString(f.Name),
+		"UF":             TextString(f.Name),
+		"EF":             Dict{"F": stream, "UF": stream},
+		"AFRelationship": Name(f.Relationship),
+	}
+	if f.Description != "" {
+		spec["Desc"] = TextString(f.Description)
+	}
+	ref := d.Add(spec)
+
+	cat := d.Catalog()
+	names := d.Dict(cat["Names"])
+	if names == nil {
+		names = Dict{}
+		cat["Names"] = names
+	}
+	type entry struct {
+		key []byte
+		val Object
+	}
+	key := TextString(f.Name)
+	entries := []entry{{key, ref}}
+	var replaced []Ref
+	d.walkNameTree(names["EmbeddedFiles"], func(k []byte, v Object) {
+		if bytes.Equal(k, key) {
+			if r, ok := v.(Ref); ok {
+				replaced = append(replaced, r)
+			}
+		} else {
+			entries = append(entries, entry{k, v})
+		}
+	})
+
+	// The replaced file is no longer an associated file either.
+	af := Array{}
+	for _, v := range d.Array(cat["AF"]) {
+		if r, ok := v.(Ref); !ok || !slices.Contains(replaced, r) {
+			af = append(af, v)
+		}
+	}
+	cat["AF"] = append(af, ref)
+	// Name tree keys must be sorted.
+	sort.SliceStable(entries, func(i, j int) bool { return bytes.Compare(entries[i].key, entries[j].key) < 0 })
+	tree := make(Array, 0, 2*len(entries))
+	for _, e := range entries {
+		tree = append(tree, String(e.key), e.val)
+	}
+	names["EmbeddedFiles"] = d.Add(Dict{"Names": tree})
+	return ref
+}
+
+// EmbeddedFiles returns the files attached to the document.
+func (d *Document) EmbeddedFiles() []EmbeddedFile {
+	names := d.Dict(d.Catalog()["Names"])
+	var files []EmbeddedFile
+	d.walkNameTree(names["EmbeddedFiles"], func(key []byte, v Object) {
+		spec := d.Dict(v)
+		stream, ok := d.Resolve(d.Dict(spec["EF"])["F"]).(*Stream)
+		if !ok {
+			return
+		}
+		data, err := stream.Decode()
+		if err != nil {
+			return
+		}
+		f := EmbeddedFile{Name: DecodeTextString(key), Data: data}
+		if mime, ok := stream.Dict["Subtype"].(Name); ok {
+			f.MIMEType = string(mime)
+		}
+		if desc, ok := StringBytes(d.Resolve(spec["Desc"])); ok {
+			f.Description = DecodeTextString(desc)
+		}
+		if rel, ok := spec["AFRelationship"].(Name); ok {
+			f.Relationship = string(rel)
+		}
+		if date, ok := StringBytes(d.Resolve(d.Dict(stream.Dict["Params"])["ModDate"])); ok {
+			f.ModTime, _ = ParseDate(string(date))
+		}
+		files = append(files, f)
+	})
+	return files
+}
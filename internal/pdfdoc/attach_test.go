@@ -0,0 +1,73 @@
+package pdfdoc
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAttachFile(t *testing.T) {
+	d, err := Parse(chromeLike(t, 1, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	d.AttachFile(EmbeddedFile{Name: "invoice.xml", MIMEType: "text/xml", Data: []byte("<Invoice/>"), Relationship: "Source", ModTime: mod})
+	d.AttachFile(EmbeddedFile{Name: "factur-x.xml", MIMEType: "text/xml", Data: []byte("<CII/>"), Relationship: "Data", Description: "Factur-X"})
+	if v := d.ConvertPDFA(3, "B"); len(v) > 0 {
+		t.Errorf("PDF/A-3 violations: %v", v)
+	}
+
+	out, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "/Subtype /text#2Fxml") {
+		t.Error("MIME type not written as the stream subtype")
+	}
+	d, err = Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := d.EmbeddedFiles()
+	if len(files) != 2 {
+		t.Fatalf("got %d embedded files, want 2", len(files))
+	}
+	// Name tree entries are sorted by name.
+	if files[0].Name != "factur-x.xml" || files[1].Name != "invoice.xml" {
+		t.Errorf("unexpected order %q, %q", files[0].Name, files[1].Name)
+	}
+	inv := files[1]
+	if string(inv.Data) != "<Invoice/>" || inv.MIMEType != "text/xml" || inv.Relationship != "Source" || !inv.ModTime.Equal(mod) {
+		t.Errorf("unexpected file %+v", inv)
+	}
+	if files[0].Description != "Factur-X" {
+		t.Errorf("description %q", files[0].Description)
+	}
+	if af := d.Array(d.Catalog()["AF"]); len(af) != 2 {
+		t.Errorf("catalog has %d associated files, want 2", len(af))
+	}
+}
+
+func TestAttachFileReplace(t *testing.T) {
+	d, err := Parse(chromeLike(t, 1, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.AttachFile(EmbeddedFile{Name: "data.bin", Data: []byte("old")})
+	ref := d.AttachFile(EmbeddedFile{Name: "data.bin", Data: []byte("new")})
+
+	files := d.EmbeddedFiles()
+	if len(files) != 1 || string(files[0].Data) != "new" {
+		t.Fatalf("embedded files %+v", files)
+	}
+	if files[0].MIMEType != "application/octet-stream" {
+		t.Errorf("MIME type %q, want the default", files[0].MIMEType)
+	}
+	if af := d.Array(d.Catalog()["AF"]); len(af) != 1 || af[0] != ref {
+		t.Errorf("associated files %v, want [%v]", af, ref)
+	}
+	if v := d.ConvertPDFA(3, "B"); len(v) > 0 {
+		t.Errorf("PDF/A-3 violations: %v", v)
+	}
+}
@@ -0,0 +1,181 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+var (
+	oidData                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidSHA256               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSAEncryption        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA256      = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type signerInfo struct {
+	Version            int
+	SID                issuerAndSerial
+	DigestAlgorithm    algorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm algorithmIdentifier
+	Signature          []byte
+}
+
+type encapContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []algorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // [0] EXPLICIT
+}
+
+type essCertIDv2 struct {
+	CertHash []byte
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+var asn1Null = asn1.RawValue{Tag: asn1.TagNull}
+
+// signCMS returns a detached CMS SignedData over content, as required by
+// the ETSI.CAdES.detached (PAdES) signature sub-filter.
+func signCMS(content []byte, signer crypto.Signer, chain []*x509.Certificate) ([]byte, error) {
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("pdf: signing certificate is required")
+	}
+	cert := chain[0]
+
+	var sigAlg algorithmIdentifier
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		sigAlg = algorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1Null}
+	case *ecdsa.PublicKey:
+		sigAlg = algorithmIdentifier{Algorithm: oidECDSAWithSHA256}
+	default:
+		return nil, fmt.Errorf("pdf: unsupported signing key type %T", signer.Public())
+	}
+
+	digest := sha256.Sum256(content)
+	certHash := sha256.Sum256(cert.Raw)
+
+	attrs, err := signedAttributes(
+		attr(oidContentType, oidData),
+		attr(oidMessageDigest, digest[:]),
+		attr(oidSigningCertificateV2, signingCertificateV2{Certs: []essCertIDv2{{CertHash: certHash[:]}}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature covers the DER encoding of the attributes as a SET.
+	attrsDigest := sha256.Sum256(attrs)
+	sig, err := signer.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("pdf: sign: %w", err)
+	}
+
+	var certs []byte
+	for _, c := range chain {
+		certs = append(certs, c.Raw...)
+	}
+
+	// Re-tag the attribute SET as [0] IMPLICIT for the SignerInfo.
+	signed := asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: stripHeader(attrs)}
+
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []algorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: encapContentInfo{ContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                issuerAndSerial{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, Serial: cert.SerialNumber},
+			DigestAlgorithm:    algorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttrs:        signed,
+			SignatureAlgorithm: sigAlg,
+			Signature:          sig,
+		}},
+	}
+	inner, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{ContentType: oidSignedData, Content: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: inner}})
+}
+
+func attr(oid asn1.ObjectIdentifier, value any) func() (attribute, error) {
+	return func() (attribute, error) {
+		b, err := asn1.Marshal(value)
+		if err != nil {
+			return attribute{}, err
+		}
+		return attribute{Type: oid, Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: b}}, nil
+	}
+}
+
+// signedAttributes DER-encodes the attributes as a SET OF, sorted as DER
+// requires.
+func signedAttributes(fns ...func() (attribute, error)) ([]byte, error) {
+	var encoded [][]byte
+	for _, fn := range fns {
+		a, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		b, err := asn1.Marshal(a)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, b)
+	}
+	sort.Slice(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 })
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(encoded, nil)})
+}
+
+// stripHeader returns the contents of a DER TLV.
+func stripHeader(der []byte) []byte {
+	var v asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &v); err != nil {
+		return nil
+	}
+	return v.Bytes
+}
@@ -0,0 +1,294 @@
+package pdfdoc
+
+import (
+	"math"
+)
+
+// colorSpace converts color components to RGB for rendering.
+type colorSpace struct {
+	n       int
+	rgb     func(c []float64) [3]float64
+	initial []float64
+	// pattern is set for Pattern color spaces; base is the color space
+	// of uncolored patterns, if any.
+	pattern bool
+	base    *colorSpace
+	// indexed is set for Indexed color spaces, whose image samples are
+	// not scaled to 0-1.
+	indexed bool
+}
+
+var (
+	deviceGray = &colorSpace{n: 1, initial: []float64{0}, rgb: func(c []float64) [3]float64 {
+		return [3]float64{c[0], c[0], c[0]}
+	}}
+	deviceRGB = &colorSpace{n: 3, initial: []float64{0, 0, 0}, rgb: func(c []float64) [3]float64 {
+		return [3]float64{c[0], c[1], c[2]}
+	}}
+	deviceCMYK = &colorSpace{n: 4, initial: []float64{0, 0, 0, 1}, rgb: func(c []float64) [3]float64 {
+		k := 1 - c[3]
+		return [3]float64{(1 - c[0]) * k, (1 - c[1]) * k, (1 - c[2]) * k}
+	}}
+)
+
+// color returns the RGB color of components c, which may be too few.
+func (cs *colorSpace) color(c []float64) [3]float64 {
+	if len(c) < cs.n {
+		c = append(append([]float64{}, c...), cs.initial[len(c):]...)
+	}
+	rgb := cs.rgb(c)
+	for i, v := range rgb {
+		rgb[i] = math.Min(math.Max(v, 0), 1)
+	}
+	return rgb
+}
+
+// colorSpace returns the color space o, which may be a name defined in
+// the ColorSpace resources. Unknown color spaces are treated as gray.
+func (d *Document) colorSpace(o Object, res Dict, depth int) *colorSpace {
+	o = d.Resolve(o)
+	if depth > 8 {
+		return deviceGray
+	}
+	var family Name
+	var arr Array
+	switch v := o.(type) {
+	case Name:
+		family = v
+	case Array:
+		if len(v) == 0 {
+			return deviceGray
+		}
+		family, _ = d.Resolve(v[0]).(Name)
+		arr = v
+	}
+	arg := func(i int) Object {
+		if i < len(arr) {
+			return d.Resolve(arr[i])
+		}
+		return nil
+	}
+
+	switch family {
+	case "DeviceGray", "G", "CalGray":
+		return deviceGray
+	case "DeviceRGB", "RGB", "CalRGB":
+		return deviceRGB
+	case "DeviceCMYK", "CMYK":
+		return deviceCMYK
+	case "ICCBased":
+		if s, ok := arg(1).(*Stream); ok {
+			switch intOr(d.Resolve(s.Dict["N"]), 0) {
+			case 1:
+				return deviceGray
+			case 4:
+				return deviceCMYK
+			}
+		}
+		return deviceRGB
+	case "Lab":
+		return labSpace(d.Dict(arg(1)))
+	case "Pattern":
+		cs := &colorSpace{pattern: true, rgb: deviceGray.rgb, initial: []float64{0}}
+		if arr != nil && len(arr) > 1 {
+			cs.base = d.colorSpace(arr[1], res, depth+1)
+		}
+		return cs
+	case "Indexed", "I":
+		base := d.colorSpace(arg(1), res, depth+1)
+		hival := intOr(arg(2), 0)
+		lookup, _ := StringBytes(arg(3))
+		if s, ok := arg(3).(*Stream); ok {
+			lookup, _ = s.Decode()
+		}
+		return &colorSpace{n: 1, initial: []float64{0}, indexed: true, rgb: func(c []float64) [3]float64 {
+			i := min(max(int(c[0]), 0), hival)
+			comps := make([]float64, base.n)
+			for k := range comps {
+				if j := i*base.n + k; j < len(lookup) {
+					comps[k] = float64(lookup[j]) / 255
+				}
+			}
+			return base.color(comps)
+		}}
+	case "Separation", "DeviceN":
+		n := 1
+		if names, ok := arg(1).(Array); ok {
+			n = len(names)
+		}
+		alt := d.colorSpace(arg(2), res, depth+1)
+		tint := d.parseFunction(arg(3), 0)
+		initial := make([]float64, n)
+		for i := range initial {
+			initial[i] = 1
+		}
+		cs := &colorSpace{n: n, initial: initial}
+		switch {
+		case arg(1) == Name("None"):
+			cs.rgb = func([]float64) [3]float64 { return [3]float64{1, 1, 1} }
+		case arg(1) == Name("All"):
+			cs.rgb = func(c []float64) [3]float64 { return [3]float64{1 - c[0], 1 - c[0], 1 - c[0]} }
+		case n == 1 && tint != nil:
+			cs.rgb = func(c []float64) [3]float64 { return alt.color(tint(c[0])) }
+		default:
+			// Tint functions of several inputs are approximated by the
+			// darkest colorant.
+			cs.rgb = func(c []float64) [3]float64 {
+				v := 0.0
+				for _, t := range c {
+					v = math.Max(v, t)
+				}
+				return [3]float64{1 - v, 1 - v, 1 - v}
+			}
+		}
+		return cs
+	}
+	if family != "" && arr == nil {
+		if def, ok := d.Dict(res["ColorSpace"])[family]; ok {
+			return d.colorSpace(def, res, depth+1)
+		}
+	}
+	return deviceGray
+}
+
+// labSpace returns a CIE L*a*b* color space, converted to sRGB.
+func labSpace(dict Dict) *colorSpace {
+	white := [3]float64{0.9505, 1, 1.089}
+	if wp, ok := dict["WhitePoint"].(Array); ok && len(wp) == 3 {
+		for i := range white {
+			white[i], _ = Number(wp[i])
+		}
+	}
+	finv := func(t float64) float64 {
+		if t > 6.0/29 {
+			return t * t * t
+		}
+		return 3 * (6.0 / 29) * (6.0 / 29) * (t - 4.0/29)
+	}
+	gamma := func(v float64) float64 {
+		if v <= 0.0031308 {
+			return 12.92 * v
+		}
+		return 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	return &colorSpace{n: 3, initial: []float64{0, 0, 0}, rgb: func(c []float64) [3]float64 {
+		fy := (c[0] + 16) / 116
+		x := white[0] * finv(fy+c[1]/500)
+		y := white[1] * finv(fy)
+		z := white[2] * finv(fy-c[2]/200)
+		return [3]float64{
+			gamma(3.2406*x - 1.5372*y - 0.4986*z),
+			gamma(-0.9689*x + 1.8758*y + 0.0415*z),
+			gamma(0.0557*x - 0.2040*y + 1.0570*z),
+		}
+	}}
+}
+
+// shading returns the color of each device pixel painted by a shading
+// whose space maps to device space by m. Only axial and radial
+// shadings are supported; nil is returned for others.
+func (d *Document) shading(o Object, m matrix) func(x, y int) [4]float64 {
+	var dict Dict
+	switch v := d.Resolve(o).(type) {
+	case Dict:
+		dict = v
+	case *Stream:
+		dict = v.Dict
+	default:
+		return nil
+	}
+	inv, ok := m.invert()
+	if !ok {
+		return nil
+	}
+	nums := func(key Name, def ...float64) []float64 {
+		arr := d.Array(dict[key])
+		if len(arr) < len(def) {
+			return def
+		}
+		out := make([]float64, len(arr))
+		for i, v := range arr {
+			out[i], _ = Number(d.Resolve(v))
+		}
+		return out
+	}
+	cs := d.colorSpace(dict["ColorSpace"], nil, 0)
+	fn := d.parseFunction(dict["Function"], 0)
+	if fn == nil {
+		return nil
+	}
+	domain := nums("Domain", 0, 1)
+	var extend [2]bool
+	if arr := d.Array(dict["Extend"]); len(arr) == 2 {
+		extend[0], _ = d.Resolve(arr[0]).(bool)
+		extend[1], _ = d.Resolve(arr[1]).(bool)
+	}
+
+	// Colors are looked up in a table of evenly spaced parameters.
+	var lut [256][4]float64
+	for i := range lut {
+		t := domain[0] + float64(i)/255*(domain[1]-domain[0])
+		c := cs.color(fn(t))
+		lut[i] = [4]float64{c[0], c[1], c[2], 1}
+	}
+	at := func(s float64) [4]float64 {
+		switch {
+		case s < 0 && !extend[0], s > 1 && !extend[1], math.IsNaN(s):
+			return [4]float64{}
+		}
+		return lut[int(math.Round(math.Min(math.Max(s, 0), 1)*255))]
+	}
+
+	switch intOr(d.Resolve(dict["ShadingType"]), 0) {
+	case 2:
+		c := nums("Coords", 0, 0, 0, 0)
+		if len(c) < 4 {
+			return nil
+		}
+		dx, dy := c[2]-c[0], c[3]-c[1]
+		den := dx*dx + dy*dy
+		if den == 0 {
+			return nil
+		}
+		return func(x, y int) [4]float64 {
+			p := inv.apply(float64(x)+0.5, float64(y)+0.5)
+			return at(((p.x-c[0])*dx + (p.y-c[1])*dy) / den)
+		}
+	case 3:
+		c := nums("Coords", 0, 0, 0, 0, 0, 0)
+		if len(c) < 6 {
+			return nil
+		}
+		cx, cy, dr := c[3]-c[0], c[4]-c[1], c[5]-c[2]
+		a := cx*cx + cy*cy - dr*dr
+		return func(x, y int) [4]float64 {
+			p := inv.apply(float64(x)+0.5, float64(y)+0.5)
+			px, py := p.x-c[0], p.y-c[1]
+			// Find the largest s whose circle passes through p.
+			b := px*cx + py*cy + c[2]*dr
+			cc := px*px + py*py - c[2]*c[2]
+			var roots []float64
+			if math.Abs(a) < 1e-9 {
+				if b != 0 {
+					roots = []float64{cc / (2 * b)}
+				}
+			} else if disc := b*b - a*cc; disc >= 0 {
+				q := math.Sqrt(disc)
+				roots = []float64{(b + q) / a, (b - q) / a}
+				if roots[0] < roots[1] {
+					roots[0], roots[1] = roots[1], roots[0]
+				}
+			}
+			for _, s := range roots {
+				if c[2]+s*dr < 0 {
+					continue
+				}
+				if col := at(s); col[3] > 0 {
+					return col
+				}
+			}
+			return [4]float64{}
+		}
+	}
+	return nil
+}
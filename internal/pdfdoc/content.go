@@ -0,0 +1,91 @@
+package pdfdoc
+
+import "bytes"
+
+// scanContent calls fn for each operator of a content stream, with its
+// operands. Inline images are skipped.
+func scanContent(data []byte, fn func(op string, args []Object)) error {
+	p := &parser{data: data}
+	var args []Object
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil
+		}
+		c := p.data[p.pos]
+		if c == '/' || c == '(' || c == '<' || c == '[' || c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9') {
+			o, err := p.parseObject()
+			if err != nil {
+				return err
+			}
+			args = append(args, o)
+			continue
+		}
+		kw := p.keyword()
+		switch kw {
+		case "":
+			// Stray delimiter, e.g. a closing bracket.
+			p.pos++
+			continue
+		case "true":
+			args = append(args, true)
+			continue
+		case "false":
+			args = append(args, false)
+			continue
+		case "null":
+			args = append(args, nil)
+			continue
+		case "BI":
+			// Skip the inline image up to its EI operator.
+			end := p.pos
+			for {
+				i := bytes.Index(p.data[end:], []byte("EI"))
+				if i < 0 {
+					return nil
+				}
+				end += i + 2
+				if (end >= len(p.data) || isWhite(p.data[end])) && isWhite(p.data[end-3]) {
+					break
+				}
+			}
+			p.pos = end
+			args = nil
+			continue
+		}
+		fn(kw, args)
+		args = nil
+	}
+}
+
+// matrix is a PDF transformation matrix [a b c d e f].
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// mul returns m × n, i.e. m applied first, then n.
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func toMatrix(args []Object) (matrix, bool) {
+	var m matrix
+	if len(args) != 6 {
+		return m, false
+	}
+	for i, a := range args {
+		v, ok := Number(a)
+		if !ok {
+			return m, false
+		}
+		m[i] = v
+	}
+	return m, true
+}
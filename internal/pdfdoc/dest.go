@@ -0,0 +1,218 @@
+package pdfdoc
+
+import "strings"
+
+// Destination is a resolved position in the document.
+type Destination struct {
+	// Page is the zero-based page index.
+	Page int
+	// X and Y are the target point in default user space (origin at the
+	// bottom left of the page).
+	X, Y float64
+}
+
+// pageIndex maps page object numbers to their zero-based index.
+func (d *Document) pageIndex() map[int]int {
+	idx := map[int]int{}
+	for i, p := range d.Pages() {
+		idx[p.Num] = i
+	}
+	return idx
+}
+
+// NamedDestinations returns all named destinations of the document, from
+// both the PDF 1.1 /Dests dictionary and the /Names name tree.
+func (d *Document) NamedDestinations() map[string]Destination {
+	out := map[string]Destination{}
+	cat := d.Catalog()
+	if cat == nil {
+		return out
+	}
+	index := d.pageIndex()
+
+	add := func(name string, v Object) {
+		v = d.Resolve(v)
+		if dict, ok := v.(Dict); ok {
+			v = d.Resolve(dict["D"])
+		}
+		if dest, ok := d.explicitDest(v, index); ok {
+			out[name] = dest
+		}
+	}
+
+	if dests := d.Dict(cat["Dests"]); dests != nil {
+		for k, v := range dests {
+			add(string(k), v)
+		}
+	}
+	if names := d.Dict(cat["Names"]); names != nil {
+		d.walkNameTree(names["Dests"], func(key []byte, v Object) {
+			add(string(key), v)
+		})
+	}
+	return out
+}
+
+func (d *Document) explicitDest(v Object, index map[int]int) (Destination, bool) {
+	arr, ok := v.(Array)
+	if !ok || len(arr) < 2 {
+		return Destination{}, false
+	}
+	ref, ok := arr[0].(Ref)
+	if !ok {
+		return Destination{}, false
+	}
+	page, ok := index[ref.Num]
+	if !ok {
+		return Destination{}, false
+	}
+	dest := Destination{Page: page}
+	box := d.PageBox(ref, "MediaBox")
+	dest.Y = box[3]
+	switch arr[1] {
+	case Name("XYZ"):
+		if len(arr) > 2 {
+			if x, ok := Number(arr[2]); ok {
+				dest.X = x
+			}
+		}
+		if len(arr) > 3 {
+			if y, ok := Number(arr[3]); ok {
+				dest.Y = y
+			}
+		}
+	case Name("FitH"), Name("FitBH"):
+		if len(arr) > 2 {
+			if y, ok := Number(arr[2]); ok {
+				dest.Y = y
+			}
+		}
+	}
+	return dest, true
+}
+
+func (d *Document) walkNameTree(node Object, fn func(key []byte, v Object)) {
+	seen := map[int]bool{}
+	var walk func(o Object, depth int)
+	walk = func(o Object, depth int) {
+		if r, ok := o.(Ref); ok {
+			if seen[r.Num] {
+				return
+			}
+			seen[r.Num] = true
+		}
+		n := d.Dict(o)
+		if n == nil || depth > 32 {
+			return
+		}
+		names := d.Array(n["Names"])
+		for i := 0; i+1 < len(names); i += 2 {
+			if key, ok := StringBytes(d.Resolve(names[i])); ok {
+				fn(key, names[i+1])
+			}
+		}
+		for _, kid := range d.Array(n["Kids"]) {
+			walk(kid, depth+1)
+		}
+	}
+	walk(node, 0)
+}
+
+// filterNameTree removes the entries of a name tree for which keep returns
+// false.
+func (d *Document) filterNameTree(node Object, keep func(key []byte, v Object) bool) {
+	seen := map[int]bool{}
+	var walk func(o Object)
+	walk = func(o Object) {
+		if r, ok := o.(Ref); ok {
+			if seen[r.Num] {
+				return
+			}
+			seen[r.Num] = true
+		}
+		n := d.Dict(o)
+		if n == nil {
+			return
+		}
+		if arr := d.Array(n["Names"]); arr != nil {
+			kept := Array{}
+			for i := 0; i+1 < len(arr); i += 2 {
+				key, _ := StringBytes(d.Resolve(arr[i]))
+				if keep(key, arr[i+1]) {
+					kept = append(kept, arr[i], arr[i+1])
+				}
+			}
+			n["Names"] = kept
+		}
+		for _, kid := range d.Array(n["Kids"]) {
+			walk(kid)
+		}
+	}
+	walk(node)
+}
+
+// RemoveNamedDestinations deletes the named destinations whose names start
+// with prefix, together with the link annotations pointing at them.
+func (d *Document) RemoveNamedDestinations(prefix string) {
+	cat := d.Catalog()
+	if cat == nil {
+		return
+	}
+	if dests := d.Dict(cat["Dests"]); dests != nil {
+		for k := range dests {
+			if strings.HasPrefix(string(k), prefix) {
+				delete(dests, k)
+			}
+		}
+		if len(dests) == 0 {
+			delete(cat, "Dests")
+		}
+	}
+	if names := d.Dict(cat["Names"]); names != nil {
+		d.filterNameTree(names["Dests"], func(key []byte, _ Object) bool {
+			return !strings.HasPrefix(string(key), prefix)
+		})
+	}
+
+	for _, p := range d.Pages() {
+		page := d.Dict(p)
+		annots := d.Array(page["Annots"])
+		if annots == nil {
+			continue
+		}
+		kept := Array{}
+		for _, a := range annots {
+			if d.linksTo(d.Dict(a), prefix) {
+				continue
+			}
+			kept = append(kept, a)
+		}
+		if len(kept) == 0 {
+			delete(page, "Annots")
+		} else {
+			page["Annots"] = kept
+		}
+	}
+}
+
+// linksTo reports whether annot is a link to a named destination that
+// starts with prefix.
+func (d *Document) linksTo(annot Dict, prefix string) bool {
+	if annot == nil || annot["Subtype"] != Name("Link") {
+		return false
+	}
+	target := d.Resolve(annot["Dest"])
+	if target == nil {
+		if action := d.Dict(annot["A"]); action != nil && action["S"] == Name("GoTo") {
+			target = d.Resolve(action["D"])
+		}
+	}
+	switch t := target.(type) {
+	case Name:
+		return strings.HasPrefix(string(t), prefix)
+	case String, HexString:
+		b, _ := StringBytes(t)
+		return strings.HasPrefix(string(b), prefix)
+	}
+	return false
+}
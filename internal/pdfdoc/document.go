@@ -0,0 +1,577 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Document is an in-memory PDF document.
+type Document struct {
+	// Version is the PDF version from the header, e.g. "1.4".
+	Version string
+	// Trailer is the trailer dictionary. /Size and /Prev are managed by
+	// the writer.
+	Trailer Dict
+	// Linearize makes Write produce a linearized ("fast web view") file,
+	// whose first page can be displayed before the rest is downloaded.
+	Linearize bool
+
+	objects map[int]Object
+	next    int
+
+	// security encrypts the document when it is written. It is set by
+	// Encrypt.
+	security *security
+}
+
+// New returns an empty document with a catalog and an empty page tree.
+func New() *Document {
+	d := &Document{Version: "1.7", Trailer: Dict{}, objects: map[int]Object{}, next: 1}
+	pages := d.Add(Dict{"Type": Name("Pages"), "Kids": Array{}, "Count": 0})
+	d.Trailer["Root"] = d.Add(Dict{"Type": Name("Catalog"), "Pages": pages})
+	return d
+}
+
+// Clone returns a copy of d that can be modified independently, e.g. to
+// cut one document into several without parsing it again. String and
+// stream bytes are shared, as they are never modified in place.
+func (d *Document) Clone() *Document {
+	c := *d
+	c.Trailer = cloneObject(d.Trailer).(Dict)
+	c.objects = make(map[int]Object, len(d.objects))
+	for num, o := range d.objects {
+		c.objects[num] = cloneObject(o)
+	}
+	return &c
+}
+
+// cloneObject copies the dictionaries, arrays and streams of o.
+func cloneObject(o Object) Object {
+	switch v := o.(type) {
+	case Dict:
+		c := make(Dict, len(v))
+		for k, e := range v {
+			c[k] = cloneObject(e)
+		}
+		return c
+	case Array:
+		c := make(Array, len(v))
+		for i, e := range v {
+			c[i] = cloneObject(e)
+		}
+		return c
+	case *Stream:
+		return &Stream{Dict: cloneObject(v.Dict).(Dict), Data: v.Data}
+	}
+	return o
+}
+
+// Get returns the object referenced by ref, or nil if it does not exist.
+func (d *Document) Get(ref Ref) Object {
+	return d.objects[ref.Num]
+}
+
+// Resolve follows o if it is a Ref and returns the direct object.
+func (d *Document) Resolve(o Object) Object {
+	for i := 0; i < 32; i++ {
+		r, ok := o.(Ref)
+		if !ok {
+			return o
+		}
+		o = d.objects[r.Num]
+	}
+	return nil
+}
+
+// Dict resolves o and returns it as a dictionary. The dictionary of a
+// stream is returned for streams.
+func (d *Document) Dict(o Object) Dict {
+	switch v := d.Resolve(o).(type) {
+	case Dict:
+		return v
+	case *Stream:
+		return v.Dict
+	}
+	return nil
+}
+
+// Array resolves o and returns it as an array.
+func (d *Document) Array(o Object) Array {
+	a, _ := d.Resolve(o).(Array)
+	return a
+}
+
+// Add stores o as a new indirect object and returns its reference.
+func (d *Document) Add(o Object) Ref {
+	ref := Ref{Num: d.next}
+	d.objects[d.next] = o
+	d.next++
+	return ref
+}
+
+// Set replaces the object referenced by ref.
+func (d *Document) Set(ref Ref, o Object) {
+	d.objects[ref.Num] = o
+	if ref.Num >= d.next {
+		d.next = ref.Num + 1
+	}
+}
+
+// Catalog returns the document catalog.
+func (d *Document) Catalog() Dict {
+	return d.Dict(d.Trailer["Root"])
+}
+
+// Info returns the document information dictionary, creating it if needed.
+func (d *Document) Info() Dict {
+	if info := d.Dict(d.Trailer["Info"]); info != nil {
+		return info
+	}
+	info := Dict{}
+	d.Trailer["Info"] = d.Add(info)
+	return info
+}
+
+// Parse reads a PDF document. Encrypted documents are rejected with
+// ErrEncrypted; use ParseWithPassword to open them.
+func Parse(data []byte) (*Document, error) {
+	d, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if d.Trailer["Encrypt"] != nil {
+		return nil, ErrEncrypted
+	}
+	return d, nil
+}
+
+// ParseWithPassword reads a PDF document, decrypting it with password
+// (either the user or the owner password) if it is encrypted. The returned
+// document is no longer encrypted.
+func ParseWithPassword(data []byte, password string) (*Document, error) {
+	d, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	ref, _ := d.Trailer["Encrypt"].(Ref)
+	dict := d.Dict(d.Trailer["Encrypt"])
+	if dict == nil {
+		return d, nil
+	}
+	sec, err := openSecurity(dict, d.fileID(), password)
+	if err != nil {
+		return nil, err
+	}
+	for num, o := range d.objects {
+		if num == ref.Num {
+			continue
+		}
+		if s, ok := o.(*Stream); ok && s.Dict["Type"] == Name("XRef") {
+			continue
+		}
+		plain, err := cryptObjectKeepSignature(o, func(b []byte) ([]byte, error) {
+			return sec.decrypt(num, 0, b)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("pdf: decrypt object %d: %w", num, err)
+		}
+		d.objects[num] = plain
+	}
+	delete(d.Trailer, "Encrypt")
+	return d, nil
+}
+
+func parse(data []byte) (*Document, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\f\r "), []byte("%PDF-")) {
+		return nil, fmt.Errorf("pdf: missing %%PDF header")
+	}
+
+	d := &Document{Version: "1.4", objects: map[int]Object{}}
+	if i := bytes.Index(data, []byte("%PDF-")); i >= 0 && i+8 <= len(data) {
+		d.Version = string(data[i+5 : i+8])
+	}
+
+	l := &loader{doc: d, data: data, entries: map[int]xrefEntry{}}
+	if err := l.loadXref(); err != nil {
+		if err := l.reconstruct(); err != nil {
+			return nil, err
+		}
+	} else if err := l.loadObjects(); err != nil {
+		l.entries = map[int]xrefEntry{}
+		d.objects = map[int]Object{}
+		if err := l.reconstruct(); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.Catalog() == nil && d.Trailer["Encrypt"] == nil {
+		return nil, fmt.Errorf("pdf: document catalog not found")
+	}
+	for num := range d.objects {
+		if num >= d.next {
+			d.next = num + 1
+		}
+	}
+	return d, nil
+}
+
+type xrefEntry struct {
+	compressed bool
+	offset     int // byte offset, or index within the object stream
+	stream     int // object stream number for compressed entries
+}
+
+type loader struct {
+	doc     *Document
+	data    []byte
+	entries map[int]xrefEntry
+}
+
+func (l *loader) parserAt(offset int) *parser {
+	return &parser{data: l.data, pos: offset, resolveLength: l.resolveLength}
+}
+
+// resolveLength resolves an indirect /Length while objects are being loaded.
+func (l *loader) resolveLength(ref Ref) (int, bool) {
+	if n, ok := l.doc.objects[ref.Num].(int); ok {
+		return n, true
+	}
+	e, ok := l.entries[ref.Num]
+	if !ok || e.compressed {
+		return 0, false
+	}
+	q := &parser{data: l.data, pos: e.offset}
+	_, o, err := q.parseIndirect()
+	if err != nil {
+		return 0, false
+	}
+	n, ok := o.(int)
+	return n, ok
+}
+
+func (l *loader) loadXref() error {
+	i := bytes.LastIndex(l.data, []byte("startxref"))
+	if i < 0 {
+		return fmt.Errorf("pdf: startxref not found")
+	}
+	p := &parser{data: l.data, pos: i + len("startxref")}
+	offset, ok := p.readInt()
+	if !ok {
+		return fmt.Errorf("pdf: invalid startxref")
+	}
+
+	seen := map[int]bool{}
+	for offset > 0 {
+		if seen[offset] || offset >= len(l.data) {
+			break
+		}
+		seen[offset] = true
+
+		trailer, err := l.readXrefSection(offset)
+		if err != nil {
+			return err
+		}
+		if l.doc.Trailer == nil {
+			l.doc.Trailer = Dict{}
+			for k, v := range trailer {
+				switch k {
+				case "Prev", "XRefStm", "Type", "W", "Index", "Filter", "DecodeParms", "Length":
+					continue
+				}
+				l.doc.Trailer[k] = v
+			}
+		}
+		prev, _ := trailer["Prev"].(int)
+		offset = prev
+	}
+	if l.doc.Trailer == nil {
+		return fmt.Errorf("pdf: trailer not found")
+	}
+	return nil
+}
+
+// readXrefSection reads a cross-reference table or stream at offset and
+// returns its trailer dictionary. Entries already known (from a newer
+// section) take precedence.
+func (l *loader) readXrefSection(offset int) (Dict, error) {
+	p := l.parserAt(offset)
+	p.skipSpace()
+	if p.peekKeyword("xref") {
+		p.keyword()
+		for {
+			if p.peekKeyword("trailer") {
+				p.skipSpace()
+				p.keyword()
+				o, err := p.parseObject()
+				if err != nil {
+					return nil, err
+				}
+				trailer, ok := o.(Dict)
+				if !ok {
+					return nil, fmt.Errorf("pdf: invalid trailer")
+				}
+				// Hybrid files carry an additional cross-reference stream.
+				if stm, ok := trailer["XRefStm"].(int); ok {
+					if _, err := l.readXrefSection(stm); err != nil {
+						return nil, err
+					}
+				}
+				return trailer, nil
+			}
+			start, ok1 := p.readInt()
+			count, ok2 := p.readInt()
+			if !ok1 || !ok2 {
+				return nil, p.errorf("invalid xref subsection")
+			}
+			for i := 0; i < count; i++ {
+				off, ok1 := p.readInt()
+				_, ok2 := p.readInt()
+				p.skipSpace()
+				kind := p.keyword()
+				if !ok1 || !ok2 || (kind != "n" && kind != "f") {
+					return nil, p.errorf("invalid xref entry")
+				}
+				num := start + i
+				if _, known := l.entries[num]; known {
+					continue
+				}
+				if kind == "f" {
+					l.entries[num] = xrefEntry{offset: -1}
+					continue
+				}
+				l.entries[num] = xrefEntry{offset: off}
+			}
+		}
+	}
+
+	_, o, err := p.parseIndirect()
+	if err != nil {
+		return nil, err
+	}
+	s, ok := o.(*Stream)
+	if !ok || s.Dict["Type"] != Name("XRef") {
+		return nil, fmt.Errorf("pdf: invalid cross-reference stream at %d", offset)
+	}
+	if err := l.readXrefStream(s); err != nil {
+		return nil, err
+	}
+	return s.Dict, nil
+}
+
+func (l *loader) readXrefStream(s *Stream) error {
+	data, err := s.Decode()
+	if err != nil {
+		return err
+	}
+	w, _ := s.Dict["W"].(Array)
+	if len(w) != 3 {
+		return fmt.Errorf("pdf: invalid /W in cross-reference stream")
+	}
+	var widths [3]int
+	for i := range widths {
+		widths[i], _ = w[i].(int)
+	}
+	size, _ := s.Dict["Size"].(int)
+	index := Array{0, size}
+	if idx, ok := s.Dict["Index"].(Array); ok {
+		index = idx
+	}
+
+	field := func(b []byte) int {
+		v := 0
+		for _, c := range b {
+			v = v<<8 | int(c)
+		}
+		return v
+	}
+	rowLen := widths[0] + widths[1] + widths[2]
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, _ := index[i].(int)
+		count, _ := index[i+1].(int)
+		for j := 0; j < count; j++ {
+			if pos+rowLen > len(data) {
+				return nil
+			}
+			row := data[pos : pos+rowLen]
+			pos += rowLen
+			typ := 1
+			if widths[0] > 0 {
+				typ = field(row[:widths[0]])
+			}
+			f2 := field(row[widths[0] : widths[0]+widths[1]])
+			f3 := field(row[widths[0]+widths[1]:])
+
+			num := start + j
+			if _, known := l.entries[num]; known {
+				continue
+			}
+			switch typ {
+			case 0:
+				l.entries[num] = xrefEntry{offset: -1}
+			case 1:
+				l.entries[num] = xrefEntry{offset: f2}
+			case 2:
+				l.entries[num] = xrefEntry{compressed: true, stream: f2, offset: f3}
+			}
+		}
+	}
+	return nil
+}
+
+func (l *loader) loadObjects() error {
+	nums := make([]int, 0, len(l.entries))
+	for num := range l.entries {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	streams := map[int][]int{}
+	for _, num := range nums {
+		e := l.entries[num]
+		if e.compressed {
+			streams[e.stream] = append(streams[e.stream], num)
+			continue
+		}
+		if e.offset < 0 || num == 0 {
+			continue
+		}
+		if e.offset >= len(l.data) {
+			return fmt.Errorf("pdf: object %d offset out of range", num)
+		}
+		p := l.parserAt(e.offset)
+		got, o, err := p.parseIndirect()
+		if err != nil {
+			return err
+		}
+		if got != num {
+			return fmt.Errorf("pdf: xref points object %d at object %d", num, got)
+		}
+		l.doc.objects[num] = o
+	}
+
+	for stm, members := range streams {
+		if err := l.loadObjectStream(stm, members); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadObjectStream extracts the given compressed objects from object
+// stream num.
+func (l *loader) loadObjectStream(num int, members []int) error {
+	s, ok := l.doc.objects[num].(*Stream)
+	if !ok {
+		return fmt.Errorf("pdf: object stream %d not found", num)
+	}
+	data, err := s.Decode()
+	if err != nil {
+		return err
+	}
+	n, _ := s.Dict["N"].(int)
+	first, _ := s.Dict["First"].(int)
+
+	p := &parser{data: data}
+	offsets := map[int]int{}
+	for i := 0; i < n; i++ {
+		objNum, ok1 := p.readInt()
+		off, ok2 := p.readInt()
+		if !ok1 || !ok2 {
+			return fmt.Errorf("pdf: invalid object stream %d header", num)
+		}
+		offsets[objNum] = first + off
+	}
+	for _, m := range members {
+		off, ok := offsets[m]
+		if !ok {
+			continue
+		}
+		q := &parser{data: data, pos: off}
+		o, err := q.parseObject()
+		if err != nil {
+			return err
+		}
+		l.doc.objects[m] = o
+	}
+	return nil
+}
+
+var objHeader = regexp.MustCompile(`(?m)(?:^|[\r\n\s])(\d+)\s+(\d+)\s+obj\b`)
+
+// reconstruct rebuilds the object table by scanning the file for object
+// headers. It is used when the cross-reference data is missing or broken.
+func (l *loader) reconstruct() error {
+	l.doc.objects = map[int]Object{}
+	var xrefStream Dict
+	for _, m := range objHeader.FindAllSubmatchIndex(l.data, -1) {
+		p := l.parserAt(m[2])
+		num, o, err := p.parseIndirect()
+		if err != nil {
+			continue
+		}
+		if s, ok := o.(*Stream); ok && s.Dict["Type"] == Name("XRef") {
+			xrefStream = s.Dict
+		}
+		l.doc.objects[num] = o
+	}
+	if len(l.doc.objects) == 0 {
+		return fmt.Errorf("pdf: no objects found")
+	}
+
+	// Expand object streams.
+	for num, o := range l.doc.objects {
+		s, ok := o.(*Stream)
+		if !ok || s.Dict["Type"] != Name("ObjStm") {
+			continue
+		}
+		data, err := s.Decode()
+		if err != nil {
+			continue
+		}
+		n, _ := s.Dict["N"].(int)
+		p := &parser{data: data}
+		var members []int
+		for i := 0; i < n; i++ {
+			objNum, ok1 := p.readInt()
+			_, ok2 := p.readInt()
+			if !ok1 || !ok2 {
+				break
+			}
+			if _, exists := l.doc.objects[objNum]; !exists {
+				members = append(members, objNum)
+			}
+		}
+		_ = l.loadObjectStream(num, members)
+	}
+
+	trailer := Dict{}
+	if i := bytes.LastIndex(l.data, []byte("trailer")); i >= 0 {
+		p := l.parserAt(i + len("trailer"))
+		if o, err := p.parseObject(); err == nil {
+			if t, ok := o.(Dict); ok {
+				trailer = t
+			}
+		}
+	} else if xrefStream != nil {
+		trailer = xrefStream
+	}
+
+	l.doc.Trailer = Dict{}
+	for _, k := range []Name{"Root", "Info", "ID", "Encrypt"} {
+		if v, ok := trailer[k]; ok {
+			l.doc.Trailer[k] = v
+		}
+	}
+	if l.doc.Catalog() == nil {
+		for num, o := range l.doc.objects {
+			if dict, ok := o.(Dict); ok && dict["Type"] == Name("Catalog") {
+				l.doc.Trailer["Root"] = Ref{Num: num}
+				break
+			}
+		}
+	}
+	return nil
+}
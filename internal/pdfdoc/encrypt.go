@@ -0,0 +1,446 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// Permission flags of the standard security handler (PDF 32000-1,
+// table 22).
+const (
+	PermPrint            = 1 << 2
+	PermModify           = 1 << 3
+	PermCopy             = 1 << 4
+	PermAnnotate         = 1 << 5
+	PermFillForms        = 1 << 8
+	PermExtract          = 1 << 9
+	PermAssemble         = 1 << 10
+	PermPrintHighQuality = 1 << 11
+)
+
+// EncryptOptions configures password protection.
+type EncryptOptions struct {
+	UserPassword  string
+	OwnerPassword string
+	// KeyBits is the AES key size: 128 or 256.
+	KeyBits int
+	// Permissions is a combination of the Perm* flags.
+	Permissions int
+}
+
+// ErrPassword is returned when a password does not open a document.
+var ErrPassword = errors.New("pdf: incorrect password")
+
+// security holds the state of the standard security handler.
+type security struct {
+	revision int // 4 (AES-128) or 6 (AES-256)
+	key      []byte
+	dict     Dict
+}
+
+// passwordPad is the padding string of the standard security handler.
+var passwordPad = []byte{
+	0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41, 0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
+	0x2E, 0x2E, 0x00, 0xB6, 0xD0, 0x68, 0x3E, 0x80, 0x2F, 0x0C, 0xA9, 0xFE, 0x64, 0x53, 0x69, 0x7A,
+}
+
+// Encrypt protects the document with the standard security handler. The
+// encryption is applied when the document is written.
+func (d *Document) Encrypt(opt EncryptOptions) error {
+	if opt.OwnerPassword == "" {
+		// Without an owner password anyone could lift the restrictions, so
+		// use a random one like other PDF tools do.
+		b := make([]byte, 16)
+		rand.Read(b)
+		opt.OwnerPassword = fmt.Sprintf("%x", b)
+	}
+	// Reserved bits 7-8 and 13-32 must be set.
+	p := int32(uint32(opt.Permissions)&0xF3C | 0xFFFFF0C0)
+
+	if d.Trailer["ID"] == nil {
+		id := make([]byte, 16)
+		rand.Read(id)
+		d.Trailer["ID"] = Array{HexString(id), HexString(id)}
+	}
+	d.Version = "1.7"
+
+	var err error
+	switch opt.KeyBits {
+	case 128:
+		d.security, err = newSecurityR4(opt, p, d.fileID())
+	case 256:
+		d.security, err = newSecurityR6(opt, p)
+		d.Catalog()["Extensions"] = Dict{"ADBE": Dict{"BaseVersion": Name("1.7"), "ExtensionLevel": 8}}
+	default:
+		return fmt.Errorf("pdf: unsupported key size %d", opt.KeyBits)
+	}
+	if err != nil {
+		return err
+	}
+	d.Trailer["Encrypt"] = d.Add(d.security.dict)
+	return nil
+}
+
+func (d *Document) fileID() []byte {
+	b, _ := StringBytes(d.Array(d.Trailer["ID"]).first())
+	return b
+}
+
+func padPassword(pw string) []byte {
+	b := append([]byte(pw), passwordPad...)
+	return b[:32]
+}
+
+func rc4Crypt(key, data []byte) []byte {
+	c, _ := rc4.NewCipher(key)
+	out := make([]byte, len(data))
+	c.XORKeyStream(out, data)
+	return out
+}
+
+func newSecurityR4(opt EncryptOptions, p int32, id []byte) (*security, error) {
+	// Algorithm 3: the owner entry.
+	sum := md5.Sum(padPassword(opt.OwnerPassword))
+	for i := 0; i < 50; i++ {
+		sum = md5.Sum(sum[:])
+	}
+	o := rc4Crypt(sum[:], padPassword(opt.UserPassword))
+	for i := 1; i <= 19; i++ {
+		o = rc4Crypt(xorKey(sum[:], byte(i)), o)
+	}
+
+	key := r4Key(opt.UserPassword, o, p, id)
+	return &security{
+		revision: 4,
+		key:      key,
+		dict: Dict{
+			"Filter": Name("Standard"),
+			"V":      4,
+			"R":      4,
+			"Length": 128,
+			"CF": Dict{"StdCF": Dict{
+				"AuthEvent": Name("DocOpen"),
+				"CFM":       Name("AESV2"),
+				"Length":    16,
+			}},
+			"StmF":            Name("StdCF"),
+			"StrF":            Name("StdCF"),
+			"O":               HexString(o),
+			"U":               HexString(r4UserEntry(key, id)),
+			"P":               int(p),
+			"EncryptMetadata": true,
+		},
+	}, nil
+}
+
+// r4Key computes the file key from the user password (algorithm 2).
+func r4Key(password string, o []byte, p int32, id []byte) []byte {
+	h := md5.New()
+	h.Write(padPassword(password))
+	h.Write(o)
+	binary.Write(h, binary.LittleEndian, p)
+	h.Write(id)
+	key := h.Sum(nil)
+	for i := 0; i < 50; i++ {
+		s := md5.Sum(key)
+		key = s[:]
+	}
+	return key
+}
+
+// r4UserEntry computes the /U entry (algorithm 5).
+func r4UserEntry(key, id []byte) []byte {
+	h := md5.New()
+	h.Write(passwordPad)
+	h.Write(id)
+	u := rc4Crypt(key, h.Sum(nil))
+	for i := 1; i <= 19; i++ {
+		u = rc4Crypt(xorKey(key, byte(i)), u)
+	}
+	return append(u, make([]byte, 16)...)
+}
+
+func xorKey(key []byte, v byte) []byte {
+	out := make([]byte, len(key))
+	for i := range key {
+		out[i] = key[i] ^ v
+	}
+	return out
+}
+
+func newSecurityR6(opt EncryptOptions, p int32) (*security, error) {
+	key := make([]byte, 32)
+	salts := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	rand.Read(salts)
+
+	user := truncatePassword(opt.UserPassword)
+	owner := truncatePassword(opt.OwnerPassword)
+
+	u := append(r6Hash(user, salts[0:8], nil), salts[0:16]...)
+	ue := aesNoPad(r6Hash(user, salts[8:16], nil), key)
+	o := append(r6Hash(owner, salts[16:24], u), salts[16:32]...)
+	oe := aesNoPad(r6Hash(owner, salts[24:32], u), key)
+
+	perms := make([]byte, 16)
+	binary.LittleEndian.PutUint32(perms, uint32(p))
+	copy(perms[4:], []byte{0xFF, 0xFF, 0xFF, 0xFF, 'T', 'a', 'd', 'b'})
+	rand.Read(perms[12:])
+	block, _ := aes.NewCipher(key)
+	block.Encrypt(perms, perms)
+
+	return &security{
+		revision: 6,
+		key:      key,
+		dict: Dict{
+			"Filter": Name("Standard"),
+			"V":      5,
+			"R":      6,
+			"Length": 256,
+			"CF": Dict{"StdCF": Dict{
+				"AuthEvent": Name("DocOpen"),
+				"CFM":       Name("AESV3"),
+				"Length":    32,
+			}},
+			"StmF":            Name("StdCF"),
+			"StrF":            Name("StdCF"),
+			"O":               HexString(o),
+			"U":               HexString(u),
+			"OE":              HexString(oe),
+			"UE":              HexString(ue),
+			"Perms":           HexString(perms),
+			"P":               int(p),
+			"EncryptMetadata": true,
+		},
+	}, nil
+}
+
+func truncatePassword(pw string) []byte {
+	b := []byte(pw)
+	if len(b) > 127 {
+		b = b[:127]
+	}
+	return b
+}
+
+// r6Hash is the password hash of revision 6 (algorithm 2.B).
+func r6Hash(password, salt, udata []byte) []byte {
+	h := sha256.New()
+	h.Write(password)
+	h.Write(salt)
+	h.Write(udata)
+	k := h.Sum(nil)
+
+	for i := 0; ; i++ {
+		seq := make([]byte, 0, len(password)+len(k)+len(udata))
+		seq = append(seq, password...)
+		seq = append(seq, k...)
+		seq = append(seq, udata...)
+		k1 := bytes.Repeat(seq, 64)
+
+		block, _ := aes.NewCipher(k[:16])
+		e := make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+
+		sum := 0
+		for _, c := range e[:16] {
+			sum += int(c)
+		}
+		var next hash.Hash
+		switch sum % 3 {
+		case 0:
+			next = sha256.New()
+		case 1:
+			next = sha512.New384()
+		default:
+			next = sha512.New()
+		}
+		next.Write(e)
+		k = next.Sum(nil)
+
+		if i >= 63 && int(e[len(e)-1]) <= i+1-32 {
+			break
+		}
+	}
+	return k[:32]
+}
+
+// aesNoPad encrypts data (a multiple of the block size) with AES-CBC, a
+// zero IV and no padding.
+func aesNoPad(key, data []byte) []byte {
+	block, _ := aes.NewCipher(key)
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, data)
+	return out
+}
+
+// objectKey returns the key used for the strings and streams of object num.
+func (s *security) objectKey(num, gen int) []byte {
+	if s.revision >= 5 {
+		return s.key
+	}
+	h := md5.New()
+	h.Write(s.key)
+	h.Write([]byte{byte(num), byte(num >> 8), byte(num >> 16), byte(gen), byte(gen >> 8)})
+	h.Write([]byte("sAlT"))
+	return h.Sum(nil)
+}
+
+// encrypt encrypts data with AES-CBC, a random IV and PKCS#5 padding.
+func (s *security) encrypt(num, gen int, data []byte) []byte {
+	block, _ := aes.NewCipher(s.objectKey(num, gen))
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	plain := append(append([]byte(nil), data...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	out := make([]byte, aes.BlockSize+len(plain))
+	rand.Read(out[:aes.BlockSize])
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], plain)
+	return out
+}
+
+func (s *security) decrypt(num, gen int, data []byte) ([]byte, error) {
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		if len(data) == 0 {
+			return data, nil
+		}
+		return nil, fmt.Errorf("pdf: invalid encrypted data length %d", len(data))
+	}
+	block, _ := aes.NewCipher(s.objectKey(num, gen))
+	out := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(out, data[aes.BlockSize:])
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(out) {
+		return nil, fmt.Errorf("pdf: invalid padding in encrypted data")
+	}
+	return out[:len(out)-pad], nil
+}
+
+// openSecurity authenticates password (user or owner) against the
+// encryption dictionary and returns the security handler state.
+func openSecurity(dict Dict, id []byte, password string) (*security, error) {
+	if dict["Filter"] != Name("Standard") {
+		return nil, fmt.Errorf("pdf: unsupported security handler %v", dict["Filter"])
+	}
+	r, _ := dict["R"].(int)
+	o, _ := StringBytes(dict["O"])
+	u, _ := StringBytes(dict["U"])
+	p, _ := dict["P"].(int)
+
+	switch r {
+	case 4:
+		if cf := asDict(asDict(dict["CF"])["StdCF"]); cf["CFM"] != Name("AESV2") {
+			return nil, fmt.Errorf("pdf: unsupported crypt filter %v", cf["CFM"])
+		}
+		// Try the password as user password.
+		key := r4Key(password, o, int32(p), id)
+		if len(u) >= 16 && bytes.Equal(r4UserEntry(key, id)[:16], u[:16]) {
+			return &security{revision: 4, key: key, dict: dict}, nil
+		}
+		// Then as owner password: recover the user password from /O.
+		sum := md5.Sum(padPassword(password))
+		for i := 0; i < 50; i++ {
+			sum = md5.Sum(sum[:])
+		}
+		user := o
+		for i := 19; i >= 0; i-- {
+			user = rc4Crypt(xorKey(sum[:], byte(i)), user)
+		}
+		key = r4Key(string(trimPad(user)), o, int32(p), id)
+		if len(u) >= 16 && bytes.Equal(r4UserEntry(key, id)[:16], u[:16]) {
+			return &security{revision: 4, key: key, dict: dict}, nil
+		}
+	case 6:
+		pw := truncatePassword(password)
+		oe, _ := StringBytes(dict["OE"])
+		ue, _ := StringBytes(dict["UE"])
+		if len(o) < 48 || len(u) < 48 {
+			return nil, fmt.Errorf("pdf: invalid encryption dictionary")
+		}
+		if bytes.Equal(r6Hash(pw, o[32:40], u[:48]), o[:32]) {
+			return &security{revision: 6, key: aesDecryptNoPad(r6Hash(pw, o[40:48], u[:48]), oe), dict: dict}, nil
+		}
+		if bytes.Equal(r6Hash(pw, u[32:40], nil), u[:32]) {
+			return &security{revision: 6, key: aesDecryptNoPad(r6Hash(pw, u[40:48], nil), ue), dict: dict}, nil
+		}
+	default:
+		return nil, fmt.Errorf("pdf: unsupported security handler revision %d", r)
+	}
+	return nil, ErrPassword
+}
+
+// trimPad strips the padding from a padded password.
+func trimPad(pw []byte) []byte {
+	for i := 0; i <= len(pw); i++ {
+		if bytes.Equal(pw[i:], passwordPad[:len(pw)-i]) {
+			return pw[:i]
+		}
+	}
+	return pw
+}
+
+func aesDecryptNoPad(key, data []byte) []byte {
+	block, _ := aes.NewCipher(key)
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, data)
+	return out
+}
+
+func asDict(o Object) Dict {
+	d, _ := o.(Dict)
+	return d
+}
+
+// cryptObject applies fn to every string in o and to stream data,
+// returning the transformed copy.
+func cryptObject(o Object, fn func([]byte) ([]byte, error)) (Object, error) {
+	switch v := o.(type) {
+	case String:
+		b, err := fn(v)
+		return String(b), err
+	case HexString:
+		b, err := fn(v)
+		return HexString(b), err
+	case Array:
+		out := make(Array, len(v))
+		for i, e := range v {
+			c, err := cryptObject(e, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = c
+		}
+		return out, nil
+	case Dict:
+		out := make(Dict, len(v))
+		for k, e := range v {
+			c, err := cryptObject(e, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = c
+		}
+		return out, nil
+	case *Stream:
+		dict, err := cryptObject(v.Dict, fn)
+		if err != nil {
+			return nil, err
+		}
+		data, err := fn(v.Data)
+		if err != nil {
+			return nil, err
+		}
+		return &Stream{Dict: dict.(Dict), Data: data}, nil
+	}
+	return o, nil
+}
@@ -0,0 +1,75 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncrypt(t *testing.T) {
+	for _, bits := range []int{128, 256} {
+		d, err := Parse(chromeLike(t, 2, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Info()["Title"] = String("Payslip")
+		err = d.Encrypt(EncryptOptions{
+			UserPassword:  "user",
+			OwnerPassword: "owner",
+			KeyBits:       bits,
+			Permissions:   PermPrint,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := d.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(out, []byte("Payslip")) {
+			t.Errorf("AES-%d: title written in clear text", bits)
+		}
+
+		if _, err := Parse(out); !errors.Is(err, ErrEncrypted) {
+			t.Errorf("AES-%d: Parse returned %v, want ErrEncrypted", bits, err)
+		}
+		if _, err := ParseWithPassword(out, "wrong"); !errors.Is(err, ErrPassword) {
+			t.Errorf("AES-%d: wrong password returned %v, want ErrPassword", bits, err)
+		}
+
+		for _, pw := range []string{"user", "owner"} {
+			d, err := ParseWithPassword(out, pw)
+			if err != nil {
+				t.Fatalf("AES-%d: open with %q: %v", bits, pw, err)
+			}
+			title, _ := StringBytes(d.Info()["Title"])
+			if string(title) != "Payslip" {
+				t.Errorf("AES-%d: decrypted title %q", bits, title)
+			}
+			data, err := d.Resolve(d.Dict(d.Pages()[0])["Contents"]).(*Stream).Decode()
+			if err != nil {
+				t.Fatalf("AES-%d: decode content: %v", bits, err)
+			}
+			if !bytes.Contains(data, []byte("(Page 1)")) {
+				t.Errorf("AES-%d: unexpected content %q", bits, data)
+			}
+		}
+	}
+}
+
+func TestEncryptPermissions(t *testing.T) {
+	d, err := Parse(chromeLike(t, 1, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Encrypt(EncryptOptions{KeyBits: 128, Permissions: PermPrint | PermCopy}); err != nil {
+		t.Fatal(err)
+	}
+	p := d.security.dict["P"].(int)
+	if p&PermPrint == 0 || p&PermCopy == 0 || p&PermModify != 0 {
+		t.Errorf("unexpected permission bits %032b", uint32(p))
+	}
+	if p >= 0 {
+		t.Errorf("reserved high bits not set: %d", p)
+	}
+}
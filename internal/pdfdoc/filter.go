@@ -0,0 +1,196 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+// Filters returns the filter names of the stream, in decoding order.
+func (s *Stream) Filters() []Name {
+	switch f := s.Dict["Filter"].(type) {
+	case Name:
+		return []Name{f}
+	case Array:
+		names := make([]Name, 0, len(f))
+		for _, e := range f {
+			if n, ok := e.(Name); ok {
+				names = append(names, n)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// Decode returns the decoded stream data. Only FlateDecode (with optional
+// PNG/TIFF predictors) is decoded; other filters return an error.
+func (s *Stream) Decode() ([]byte, error) {
+	data := s.Data
+	filters := s.Filters()
+	parms := s.decodeParms()
+	for i, f := range filters {
+		switch f {
+		case "FlateDecode", "Fl":
+			out, err := inflate(data)
+			if err != nil {
+				return nil, err
+			}
+			if i < len(parms) && parms[i] != nil {
+				if out, err = unpredict(out, parms[i]); err != nil {
+					return nil, err
+				}
+			}
+			data = out
+		default:
+			return nil, fmt.Errorf("pdf: unsupported filter %s", f)
+		}
+	}
+	return data, nil
+}
+
+// Decodable reports whether Decode can decode the stream.
+func (s *Stream) Decodable() bool {
+	for _, f := range s.Filters() {
+		if f != "FlateDecode" && f != "Fl" {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Stream) decodeParms() []Dict {
+	switch p := s.Dict["DecodeParms"].(type) {
+	case Dict:
+		return []Dict{p}
+	case Array:
+		out := make([]Dict, len(p))
+		for i, e := range p {
+			out[i], _ = e.(Dict)
+		}
+		return out
+	}
+	return nil
+}
+
+// SetData replaces the stream contents with data compressed using
+// FlateDecode.
+func (s *Stream) SetData(data []byte) {
+	s.Data = deflate(data, zlib.DefaultCompression)
+	s.Dict["Filter"] = Name("FlateDecode")
+	delete(s.Dict, "DecodeParms")
+	s.Dict["Length"] = len(s.Data)
+}
+
+// NewStream returns a FlateDecode stream holding data. dict may be nil.
+func NewStream(dict Dict, data []byte) *Stream {
+	if dict == nil {
+		dict = Dict{}
+	}
+	s := &Stream{Dict: dict}
+	s.SetData(data)
+	return s
+}
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("pdf: flate: %w", err)
+	}
+	defer r.Close()
+	out, err := io.ReadAll(r)
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("pdf: flate: %w", err)
+	}
+	// Truncated streams are common; keep whatever was decoded.
+	return out, nil
+}
+
+func deflate(data []byte, level int) []byte {
+	var buf bytes.Buffer
+	w, _ := zlib.NewWriterLevel(&buf, level)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+// unpredict reverses PNG (>= 10) and TIFF (2) predictors.
+func unpredict(data []byte, parms Dict) ([]byte, error) {
+	predictor, _ := parms["Predictor"].(int)
+	if predictor <= 1 {
+		return data, nil
+	}
+	colors := intOr(parms["Colors"], 1)
+	bpc := intOr(parms["BitsPerComponent"], 8)
+	columns := intOr(parms["Columns"], 1)
+	bpp := (colors*bpc + 7) / 8
+	rowLen := (colors*bpc*columns + 7) / 8
+
+	if predictor == 2 {
+		if bpc != 8 {
+			return nil, fmt.Errorf("pdf: unsupported TIFF predictor depth %d", bpc)
+		}
+		out := append([]byte(nil), data...)
+		for r := 0; r+rowLen <= len(out); r += rowLen {
+			for i := bpp; i < rowLen; i++ {
+				out[r+i] += out[r+i-bpp]
+			}
+		}
+		return out, nil
+	}
+
+	var out []byte
+	prev := make([]byte, rowLen)
+	for pos := 0; pos+1+rowLen <= len(data); pos += 1 + rowLen {
+		typ := data[pos]
+		row := append([]byte(nil), data[pos+1:pos+1+rowLen]...)
+		for i := range row {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up = prev[i]
+			switch typ {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func intOr(o Object, d int) int {
+	if v, ok := o.(int); ok {
+		return v
+	}
+	return d
+}
@@ -0,0 +1,166 @@
+package pdfdoc
+
+import "encoding/binary"
+
+// renderFont is a font as needed to render text: glyph outlines or, for
+// Type 3 fonts, glyph procedures, and glyph widths.
+type renderFont struct {
+	twoByte bool
+	// glyphs are the embedded TrueType outlines, and gid maps a character
+	// code to a glyph.
+	glyphs *trueType
+	gid    func(code int) int
+	// procs are the glyph procedures of a Type 3 font, drawn with its
+	// resources.
+	procs map[int]*Stream
+	res   Dict
+	// matrix maps glyph space to text space.
+	matrix matrix
+	// widths are the glyph advances in text space, dw the default.
+	widths map[int]float64
+	dw     float64
+}
+
+// codes splits a string shown with the font into character codes.
+func (f *renderFont) codes(s []byte) []int {
+	var out []int
+	if f.twoByte {
+		for i := 0; i+1 < len(s); i += 2 {
+			out = append(out, int(binary.BigEndian.Uint16(s[i:])))
+		}
+		return out
+	}
+	for _, c := range s {
+		out = append(out, int(c))
+	}
+	return out
+}
+
+func (f *renderFont) width(code int) float64 {
+	if w, ok := f.widths[code]; ok {
+		return w
+	}
+	return f.dw
+}
+
+// loadFont reads a font dictionary. Only embedded TrueType fonts, simple
+// or CID-keyed, and Type 3 fonts have glyphs; text in other fonts, e.g.
+// ones embedding a CFF program (FontFile3), only advances.
+func (d *Document) loadFont(o Object) *renderFont {
+	font := d.Dict(o)
+	f := &renderFont{widths: map[int]float64{}, dw: 0.5, matrix: matrix{0.001, 0, 0, 0.001, 0, 0}}
+	if font == nil {
+		return f
+	}
+	num := func(o Object) float64 {
+		v, _ := Number(d.Resolve(o))
+		return v
+	}
+
+	switch d.Resolve(font["Subtype"]) {
+	case Name("Type0"):
+		f.twoByte = true
+		desc := d.Dict(d.Array(font["DescendantFonts"]).first())
+		f.dw = 1
+		if dw, ok := Number(d.Resolve(desc["DW"])); ok {
+			f.dw = dw / 1000
+		}
+		w := d.Array(desc["W"])
+		for i := 0; i+1 < len(w); {
+			first := int(num(w[i]))
+			if ws := d.Array(w[i+1]); ws != nil {
+				for k, v := range ws {
+					f.widths[first+k] = num(v) / 1000
+				}
+				i += 2
+				continue
+			}
+			if i+2 >= len(w) {
+				break
+			}
+			last, v := int(num(w[i+1])), num(w[i+2])/1000
+			for c := first; c <= last && c-first < 0x10000; c++ {
+				f.widths[c] = v
+			}
+			i += 3
+		}
+		f.glyphs = d.fontProgram(desc)
+		f.gid = func(cid int) int { return cid }
+		if s, ok := d.Resolve(desc["CIDToGIDMap"]).(*Stream); ok {
+			if m, err := s.Decode(); err == nil {
+				f.gid = func(cid int) int {
+					if 2*cid+1 < len(m) {
+						return int(binary.BigEndian.Uint16(m[2*cid:]))
+					}
+					return 0
+				}
+			}
+		}
+
+	case Name("Type3"):
+		if m, ok := toMatrix(d.Array(font["FontMatrix"])); ok {
+			f.matrix = m
+		}
+		f.res = d.Dict(font["Resources"])
+		names := map[int]Name{}
+		code := 0
+		for _, v := range d.Array(d.Dict(font["Encoding"])["Differences"]) {
+			switch v := d.Resolve(v).(type) {
+			case int:
+				code = v
+			case Name:
+				names[code] = v
+				code++
+			}
+		}
+		procs := d.Dict(font["CharProcs"])
+		f.procs = map[int]*Stream{}
+		for c, name := range names {
+			if s, ok := d.Resolve(procs[name]).(*Stream); ok {
+				f.procs[c] = s
+			}
+		}
+		f.dw = 0
+		first := int(num(font["FirstChar"]))
+		for i, w := range d.Array(font["Widths"]) {
+			f.widths[first+i] = num(w) * f.matrix[0]
+		}
+
+	default:
+		first := int(num(font["FirstChar"]))
+		for i, w := range d.Array(font["Widths"]) {
+			f.widths[first+i] = num(w) / 1000
+		}
+		f.glyphs = d.fontProgram(d.Dict(font["FontDescriptor"]))
+		if f.glyphs != nil {
+			cmap := f.glyphs.cmap
+			f.gid = func(code int) int { return cmap[code] }
+		}
+	}
+	if f.glyphs != nil {
+		s := 1 / f.glyphs.unitsPerEm
+		f.matrix = matrix{s, 0, 0, s, 0, 0}
+	}
+	return f
+}
+
+// fontProgram parses the TrueType program embedded in a font descriptor
+// (or a CID font, whose descriptor it looks up).
+func (d *Document) fontProgram(desc Dict) *trueType {
+	if fd := d.Dict(desc["FontDescriptor"]); fd != nil {
+		desc = fd
+	}
+	s, ok := d.Resolve(desc["FontFile2"]).(*Stream)
+	if !ok {
+		return nil
+	}
+	data, err := s.Decode()
+	if err != nil {
+		return nil
+	}
+	t, err := parseTrueType(data)
+	if err != nil {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,271 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// FieldType is the kind of an interactive form field.
+type FieldType int
+
+const (
+	FieldText FieldType = iota
+	FieldCheckbox
+	FieldSignature
+)
+
+// FormField describes an interactive form field.
+type FormField struct {
+	// Name is the partial field name. It must be unique and may not
+	// contain periods.
+	Name string
+	Type FieldType
+	// Page is the index of the page the widget is on, from 0.
+	Page int
+	// Rect is the widget rectangle [llx lly urx ury] in default user space.
+	Rect [4]float64
+
+	// Value is the initial value of a text field.
+	Value string
+	// Checked is the initial state of a checkbox.
+	Checked bool
+	// FontSize is the text size in points; 0 sizes the text to the field.
+	FontSize float64
+	// MaxLength limits the length of a text field, if positive.
+	MaxLength int
+
+	Multiline bool
+	Password  bool
+	ReadOnly  bool
+	Required  bool
+}
+
+// Field flags (ISO 32000-1, Tables 221 and 228).
+const (
+	flagReadOnly  = 1 << 0
+	flagRequired  = 1 << 1
+	flagMultiline = 1 << 12
+	flagPassword  = 1 << 13
+)
+
+// AddField adds a form field with a single widget to the document. The
+// widget has an appearance showing its initial value, drawn with the
+// standard Helvetica and ZapfDingbats fonts, which are not embedded. If a
+// text value has characters outside Latin-1, the form asks viewers to
+// regenerate the appearances.
+func (d *Document) AddField(f FormField) (Ref, error) {
+	if f.Name == "" || strings.Contains(f.Name, ".") {
+		return Ref{}, fmt.Errorf("pdf: invalid field name %q", f.Name)
+	}
+	pages := d.Pages()
+	if f.Page < 0 || f.Page >= len(pages) {
+		return Ref{}, fmt.Errorf("pdf: field %q: page %d out of range", f.Name, f.Page+1)
+	}
+	for _, ref := range d.Array(d.AcroForm()["Fields"]) {
+		if name, _ := StringBytes(d.Dict(ref)["T"]); DecodeTextString(name) == f.Name {
+			return Ref{}, fmt.Errorf("pdf: duplicate field name %q", f.Name)
+		}
+	}
+	w, h := f.Rect[2]-f.Rect[0], f.Rect[3]-f.Rect[1]
+	if w <= 0 || h <= 0 {
+		return Ref{}, fmt.Errorf("pdf: field %q has an empty rectangle", f.Name)
+	}
+
+	page := pages[f.Page]
+	widget := Dict{
+		"Type":    Name("Annot"),
+		"Subtype": Name("Widget"),
+		"T":       TextString(f.Name),
+		"P":       page,
+		"Rect":    Array{f.Rect[0], f.Rect[1], f.Rect[2], f.Rect[3]},
+		"F":       4, // print
+	}
+	flags := 0
+	if f.ReadOnly {
+		flags |= flagReadOnly
+	}
+	if f.Required {
+		flags |= flagRequired
+	}
+
+	switch f.Type {
+	case FieldText:
+		size := f.FontSize
+		if size <= 0 {
+			size = min(12, h*0.7)
+			if f.Multiline {
+				size = min(12, h)
+			}
+		}
+		if f.Multiline {
+			flags |= flagMultiline
+		}
+		if f.Password {
+			flags |= flagPassword
+		}
+		widget["FT"] = Name("Tx")
+		widget["DA"] = String(fmt.Sprintf("/Helv %s Tf 0 g", formatReal(size)))
+		if f.Value != "" && !f.Password {
+			widget["V"] = TextString(f.Value)
+		}
+		if f.MaxLength > 0 {
+			widget["MaxLen"] = f.MaxLength
+		}
+		widget["AP"] = Dict{"N": d.Add(d.textAppearance(f, w, h, size))}
+		if !f.Password && !winAnsiEncodable(f.Value) {
+			// Helvetica cannot show the value, so viewers are asked to
+			// draw it with a font of their own.
+			d.AcroForm()["NeedAppearances"] = true
+		}
+	case FieldCheckbox:
+		state := Name("Off")
+		if f.Checked {
+			state = "Yes"
+		}
+		widget["FT"] = Name("Btn")
+		widget["V"] = state
+		widget["AS"] = state
+		widget["DA"] = String("/ZaDb 0 Tf 0 g")
+		widget["MK"] = Dict{"CA": String("4")} // check mark
+		widget["AP"] = Dict{"N": Dict{
+			"Yes": d.Add(d.checkAppearance(w, h)),
+			"Off": d.Add(appearance(w, h, nil, nil)),
+		}}
+	case FieldSignature:
+		// An unsigned signature field, to be signed in a viewer. The
+		// template draws the placeholder, so the appearance is empty.
+		widget["FT"] = Name("Sig")
+		widget["AP"] = Dict{"N": d.Add(appearance(w, h, nil, nil))}
+	default:
+		return Ref{}, fmt.Errorf("pdf: field %q: unknown type %d", f.Name, f.Type)
+	}
+	if flags != 0 {
+		widget["Ff"] = flags
+	}
+
+	ref := d.Add(widget)
+	d.AddAnnotation(page, ref)
+	d.AddFormField(ref)
+	return ref, nil
+}
+
+// textAppearance draws the initial value of a text field.
+func (d *Document) textAppearance(f FormField, w, h, size float64) *Stream {
+	var lines []string
+	if f.Value != "" && !f.Password {
+		lines = strings.Split(strings.ReplaceAll(f.Value, "\r\n", "\n"), "\n")
+		if !f.Multiline {
+			lines = []string{strings.Join(lines, " ")}
+		}
+	}
+	var b bytes.Buffer
+	b.WriteString("/Tx BMC\n")
+	if len(lines) > 0 {
+		// Single lines are centered vertically; multiple lines start at
+		// the top. Helvetica caps are about 0.72 em high.
+		y := (h - size*0.72) / 2
+		if f.Multiline {
+			y = h - 2 - size
+		}
+		fmt.Fprintf(&b, "q 1 1 %s %s re W n BT /Helv %s Tf 0 g %s TL 2 %s Td\n",
+			formatReal(w-2), formatReal(h-2), formatReal(size), formatReal(size*1.15), formatReal(y))
+		for i, line := range lines {
+			if i > 0 {
+				b.WriteString("T* ")
+			}
+			writeLiteral(&b, winAnsi(line))
+			b.WriteString(" Tj\n")
+		}
+		b.WriteString("ET Q\n")
+	}
+	b.WriteString("EMC")
+	font := d.formFont("Helv", Dict{
+		"Type":     Name("Font"),
+		"Subtype":  Name("Type1"),
+		"BaseFont": Name("Helvetica"),
+		"Encoding": Name("WinAnsiEncoding"),
+	})
+	return appearance(w, h, Dict{"Font": Dict{"Helv": font}}, b.Bytes())
+}
+
+// checkAppearance draws the check mark of a checked checkbox.
+func (d *Document) checkAppearance(w, h float64) *Stream {
+	// The ZapfDingbats check mark is 0.846 em wide and about 0.7 em high.
+	size := min(w, h) * 0.8
+	content := fmt.Sprintf("q BT 0 g /ZaDb %s Tf %s %s Td (4) Tj ET Q",
+		formatReal(size), formatReal((w-size*0.846)/2), formatReal((h-size*0.7)/2))
+	font := d.formFont("ZaDb", Dict{
+		"Type":     Name("Font"),
+		"Subtype":  Name("Type1"),
+		"BaseFont": Name("ZapfDingbats"),
+	})
+	return appearance(w, h, Dict{"Font": Dict{"ZaDb": font}}, []byte(content))
+}
+
+// formFont returns the font registered under name in the default
+// resources of the interactive form, adding font if there is none.
+func (d *Document) formFont(name Name, font Dict) Ref {
+	form := d.AcroForm()
+	dr := d.Dict(form["DR"])
+	if dr == nil {
+		dr = Dict{}
+		form["DR"] = dr
+	}
+	fonts := d.Dict(dr["Font"])
+	if fonts == nil {
+		fonts = Dict{}
+		dr["Font"] = fonts
+	}
+	if ref, ok := fonts[name].(Ref); ok {
+		return ref
+	}
+	ref := d.Add(font)
+	fonts[name] = ref
+	if form["DA"] == nil {
+		form["DA"] = String("/Helv 0 Tf 0 g")
+	}
+	return ref
+}
+
+// appearance returns a form XObject of the given size.
+func appearance(w, h float64, resources Dict, content []byte) *Stream {
+	dict := Dict{
+		"Type":    Name("XObject"),
+		"Subtype": Name("Form"),
+		"BBox":    Array{0, 0, w, h},
+	}
+	if resources != nil {
+		dict["Resources"] = resources
+	}
+	return NewStream(dict, content)
+}
+
+// winAnsi encodes s for a WinAnsiEncoding font. Characters outside
+// Latin-1 are replaced with a question mark.
+func winAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r == '\t' {
+			r = ' '
+		}
+		if r < 0x20 || r > 0xff || (r >= 0x7f && r < 0xa0) {
+			r = '?'
+		}
+		out = append(out, byte(r))
+	}
+	return out
+}
+
+// winAnsiEncodable reports whether winAnsi encodes s without replacing
+// any character.
+func winAnsiEncodable(s string) bool {
+	for _, r := range s {
+		switch {
+		case r == '\t' || r == '\r' || r == '\n':
+		case r < 0x20 || r > 0xff || (r >= 0x7f && r < 0xa0):
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAddField(t *testing.T) {
+	d, err := Parse(chromeLike(t, 2, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := []FormField{
+		{Name: "name", Type: FieldText, Page: 0, Rect: [4]float64{72, 700, 272, 720}, Value: "Jane Doe", Required: true},
+		{Name: "notes", Type: FieldText, Page: 0, Rect: [4]float64{72, 600, 272, 680}, Value: "a\nb", Multiline: true},
+		{Name: "agree", Type: FieldCheckbox, Page: 1, Rect: [4]float64{72, 700, 84, 712}, Checked: true},
+		{Name: "approval", Type: FieldSignature, Page: 1, Rect: [4]float64{72, 100, 272, 150}},
+	}
+	for _, f := range fields {
+		if _, err := d.AddField(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.AddField(fields[0]); err == nil {
+		t.Error("expected an error for a duplicate name")
+	}
+	if _, err := d.AddField(FormField{Name: "a.b", Rect: [4]float64{0, 0, 1, 1}}); err == nil {
+		t.Error("expected an error for a name with a period")
+	}
+	if _, err := d.AddField(FormField{Name: "empty", Page: 0}); err == nil {
+		t.Error("expected an error for an empty rectangle")
+	}
+
+	out, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err = Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := d.Dict(d.Catalog()["AcroForm"])
+	if got := len(d.Array(form["Fields"])); got != len(fields) {
+		t.Fatalf("got %d fields, want %d", got, len(fields))
+	}
+	if d.Dict(d.Dict(form["DR"])["Font"])["Helv"] == nil {
+		t.Error("Helv missing from the default resources")
+	}
+
+	byName := map[string]Dict{}
+	for _, ref := range d.Array(form["Fields"]) {
+		w := d.Dict(ref)
+		name, _ := StringBytes(w["T"])
+		byName[DecodeTextString(name)] = w
+	}
+
+	name := byName["name"]
+	if name["FT"] != Name("Tx") || name["Ff"] != flagRequired {
+		t.Errorf("unexpected text field %v", name)
+	}
+	if v, _ := StringBytes(name["V"]); DecodeTextString(v) != "Jane Doe" {
+		t.Errorf("value %q", v)
+	}
+	ap, err := d.Resolve(d.Dict(name["AP"])["N"]).(*Stream).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(ap, []byte("(Jane Doe) Tj")) {
+		t.Errorf("appearance does not show the value: %q", ap)
+	}
+	if notes := byName["notes"]; notes["Ff"] != flagMultiline {
+		t.Errorf("unexpected multiline field %v", notes)
+	}
+
+	agree := byName["agree"]
+	if agree["FT"] != Name("Btn") || agree["V"] != Name("Yes") || agree["AS"] != Name("Yes") {
+		t.Errorf("unexpected checkbox %v", agree)
+	}
+	if d.Dict(agree["P"]) == nil || d.Dict(agree["AP"])["N"] == nil {
+		t.Errorf("checkbox without page or appearance")
+	}
+	if annots := d.Array(d.Dict(d.Pages()[1])["Annots"]); len(annots) != 2 {
+		t.Errorf("page 2 has %d annotations, want 2", len(annots))
+	}
+
+	if sig := byName["approval"]; sig["FT"] != Name("Sig") || sig["V"] != nil {
+		t.Errorf("unexpected signature field %v", sig)
+	}
+}
+
+func TestAddFieldNeedAppearances(t *testing.T) {
+	d, err := Parse(chromeLike(t, 1, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.AddField(FormField{Name: "latin", Rect: [4]float64{72, 700, 272, 720}, Value: "Crème brûlée"}); err != nil {
+		t.Fatal(err)
+	}
+	if d.AcroForm()["NeedAppearances"] != nil {
+		t.Error("NeedAppearances set for a Latin-1 value")
+	}
+	if _, err := d.AddField(FormField{Name: "thai", Rect: [4]float64{72, 600, 272, 620}, Value: "สวัสดี"}); err != nil {
+		t.Fatal(err)
+	}
+	if d.AcroForm()["NeedAppearances"] != true {
+		t.Error("NeedAppearances not set for a value Helvetica cannot show")
+	}
+}
@@ -0,0 +1,429 @@
+package pdfdoc
+
+import (
+	"math"
+	"strconv"
+)
+
+// function is a PDF function (ISO 32000-1, 7.10) of one input, as used
+// by shadings.
+type function func(t float64) []float64
+
+// parseFunction returns the function described by o, which may also be
+// an array of functions returning one component each.
+func (d *Document) parseFunction(o Object, depth int) function {
+	o = d.Resolve(o)
+	if arr, ok := o.(Array); ok {
+		var fns []function
+		for _, f := range arr {
+			if fn := d.parseFunction(f, depth+1); fn != nil {
+				fns = append(fns, fn)
+			}
+		}
+		if len(fns) == 0 {
+			return nil
+		}
+		return func(t float64) []float64 {
+			out := make([]float64, 0, len(fns))
+			for _, fn := range fns {
+				out = append(out, fn(t)[0])
+			}
+			return out
+		}
+	}
+	var dict Dict
+	var data []byte
+	switch v := o.(type) {
+	case Dict:
+		dict = v
+	case *Stream:
+		dict = v.Dict
+		var err error
+		if data, err = v.Decode(); err != nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+	if depth > 8 {
+		return nil
+	}
+	nums := func(key Name) []float64 {
+		var out []float64
+		for _, v := range d.Array(dict[key]) {
+			f, _ := Number(d.Resolve(v))
+			out = append(out, f)
+		}
+		return out
+	}
+	domain := nums("Domain")
+	if len(domain) < 2 {
+		domain = []float64{0, 1}
+	}
+	clampIn := func(t float64) float64 { return math.Min(math.Max(t, domain[0]), domain[1]) }
+	rng := nums("Range")
+	clampOut := func(out []float64) []float64 {
+		for i := range out {
+			if 2*i+1 < len(rng) {
+				out[i] = math.Min(math.Max(out[i], rng[2*i]), rng[2*i+1])
+			}
+		}
+		return out
+	}
+
+	switch t, _ := d.Resolve(dict["FunctionType"]).(int); t {
+	case 0:
+		return sampledFunction(dict, data, nums, domain, clampOut)
+	case 2:
+		c0, c1 := nums("C0"), nums("C1")
+		if c0 == nil {
+			c0 = []float64{0}
+		}
+		if c1 == nil {
+			c1 = []float64{1}
+		}
+		n, _ := Number(d.Resolve(dict["N"]))
+		return func(t float64) []float64 {
+			x := math.Pow(clampIn(t), n)
+			out := make([]float64, min(len(c0), len(c1)))
+			for i := range out {
+				out[i] = c0[i] + x*(c1[i]-c0[i])
+			}
+			return clampOut(out)
+		}
+	case 3:
+		var fns []function
+		for _, f := range d.Array(dict["Functions"]) {
+			fns = append(fns, d.parseFunction(f, depth+1))
+		}
+		bounds, encode := nums("Bounds"), nums("Encode")
+		if len(fns) == 0 || len(bounds) != len(fns)-1 || len(encode) < 2*len(fns) {
+			return nil
+		}
+		return func(t float64) []float64 {
+			t = clampIn(t)
+			k := 0
+			for k < len(bounds) && t >= bounds[k] {
+				k++
+			}
+			lo, hi := domain[0], domain[1]
+			if k > 0 {
+				lo = bounds[k-1]
+			}
+			if k < len(bounds) {
+				hi = bounds[k]
+			}
+			x := encode[2*k]
+			if hi > lo {
+				x += (t - lo) / (hi - lo) * (encode[2*k+1] - encode[2*k])
+			}
+			if fns[k] == nil {
+				return []float64{0}
+			}
+			return clampOut(fns[k](x))
+		}
+	case 4:
+		prog := parsePostScript(data)
+		if prog == nil {
+			return nil
+		}
+		return func(t float64) []float64 {
+			stack := []float64{clampIn(t)}
+			runPostScript(prog, &stack, 0)
+			return clampOut(stack)
+		}
+	}
+	return nil
+}
+
+// sampledFunction evaluates a type 0 function of one input by linear
+// interpolation between samples.
+func sampledFunction(dict Dict, data []byte, nums func(Name) []float64, domain []float64, clampOut func([]float64) []float64) function {
+	size := nums("Size")
+	rng := nums("Range")
+	bps, _ := dict["BitsPerSample"].(int)
+	if len(size) < 1 || size[0] < 1 || len(rng) < 2 || bps <= 0 || bps > 32 {
+		return nil
+	}
+	n := len(rng) / 2
+	samplesN := int(size[0])
+	encode := nums("Encode")
+	if len(encode) < 2 {
+		encode = []float64{0, size[0] - 1}
+	}
+	decode := nums("Decode")
+	if len(decode) < 2*n {
+		decode = rng
+	}
+	maxV := math.Pow(2, float64(bps)) - 1
+	sample := func(i, j int) float64 {
+		bit := (i*n + j) * bps
+		var v uint64
+		for b := 0; b < bps; b++ {
+			byteIdx := (bit + b) / 8
+			if byteIdx >= len(data) {
+				return 0
+			}
+			v = v<<1 | uint64(data[byteIdx]>>(7-uint((bit+b)%8))&1)
+		}
+		return decode[2*j] + float64(v)/maxV*(decode[2*j+1]-decode[2*j])
+	}
+	return func(t float64) []float64 {
+		t = math.Min(math.Max(t, domain[0]), domain[1])
+		e := encode[0]
+		if domain[1] > domain[0] {
+			e += (t - domain[0]) / (domain[1] - domain[0]) * (encode[1] - encode[0])
+		}
+		e = math.Min(math.Max(e, 0), float64(samplesN-1))
+		i := int(e)
+		f := e - float64(i)
+		out := make([]float64, n)
+		for j := range out {
+			a := sample(i, j)
+			b := a
+			if i+1 < samplesN {
+				b = sample(i+1, j)
+			}
+			out[j] = a + f*(b-a)
+		}
+		return clampOut(out)
+	}
+}
+
+// psOp is an operator or operand of a PostScript calculator function.
+// Procedures (the branches of if and ifelse) are nested.
+type psOp struct {
+	name string
+	num  float64
+	proc []psOp
+}
+
+func parsePostScript(data []byte) []psOp {
+	p := &parser{data: data}
+	p.skipSpace()
+	if p.eof() || p.data[p.pos] != '{' {
+		return nil
+	}
+	p.pos++
+	prog, ok := parsePSProc(p, 0)
+	if !ok {
+		return nil
+	}
+	return prog
+}
+
+func parsePSProc(p *parser, depth int) ([]psOp, bool) {
+	var ops []psOp
+	for {
+		p.skipSpace()
+		if p.eof() || depth > 32 {
+			return nil, false
+		}
+		switch c := p.data[p.pos]; {
+		case c == '{':
+			p.pos++
+			proc, ok := parsePSProc(p, depth+1)
+			if !ok {
+				return nil, false
+			}
+			ops = append(ops, psOp{proc: proc})
+		case c == '}':
+			p.pos++
+			return ops, true
+		default:
+			start := p.pos
+			for !p.eof() && !isWhite(p.data[p.pos]) && p.data[p.pos] != '{' && p.data[p.pos] != '}' {
+				p.pos++
+			}
+			tok := string(p.data[start:p.pos])
+			if v, err := strconv.ParseFloat(tok, 64); err == nil {
+				ops = append(ops, psOp{name: "", num: v})
+			} else {
+				ops = append(ops, psOp{name: tok})
+			}
+		}
+	}
+}
+
+// runPostScript executes prog on stack. Booleans are 1 and 0.
+func runPostScript(prog []psOp, stack *[]float64, depth int) {
+	if depth > 32 {
+		return
+	}
+	s := *stack
+	pop := func() float64 {
+		if len(s) == 0 {
+			return 0
+		}
+		v := s[len(s)-1]
+		s = s[:len(s)-1]
+		return v
+	}
+	push := func(v float64) { s = append(s, v) }
+	b2f := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	for i := 0; i < len(prog); i++ {
+		op := prog[i]
+		if op.proc != nil {
+			// Procedures are only operands of if and ifelse.
+			if i+1 < len(prog) && prog[i+1].name == "if" {
+				if pop() != 0 {
+					*stack = s
+					runPostScript(op.proc, stack, depth+1)
+					s = *stack
+				}
+				i++
+			} else if i+2 < len(prog) && prog[i+1].proc != nil && prog[i+2].name == "ifelse" {
+				branch := prog[i+1].proc
+				if pop() != 0 {
+					branch = op.proc
+				}
+				*stack = s
+				runPostScript(branch, stack, depth+1)
+				s = *stack
+				i += 2
+			}
+			continue
+		}
+		switch op.name {
+		case "":
+			push(op.num)
+		case "true":
+			push(1)
+		case "false":
+			push(0)
+		case "add":
+			b, a := pop(), pop()
+			push(a + b)
+		case "sub":
+			b, a := pop(), pop()
+			push(a - b)
+		case "mul":
+			b, a := pop(), pop()
+			push(a * b)
+		case "div":
+			b, a := pop(), pop()
+			if b == 0 {
+				push(0)
+			} else {
+				push(a / b)
+			}
+		case "idiv":
+			b, a := int(pop()), int(pop())
+			if b == 0 {
+				push(0)
+			} else {
+				push(float64(a / b))
+			}
+		case "mod":
+			b, a := int(pop()), int(pop())
+			if b == 0 {
+				push(0)
+			} else {
+				push(float64(a % b))
+			}
+		case "neg":
+			push(-pop())
+		case "abs":
+			push(math.Abs(pop()))
+		case "ceiling":
+			push(math.Ceil(pop()))
+		case "floor":
+			push(math.Floor(pop()))
+		case "round":
+			push(math.Floor(pop() + 0.5))
+		case "truncate", "cvi":
+			push(math.Trunc(pop()))
+		case "cvr":
+		case "sqrt":
+			push(math.Sqrt(math.Max(pop(), 0)))
+		case "sin":
+			push(math.Sin(pop() * math.Pi / 180))
+		case "cos":
+			push(math.Cos(pop() * math.Pi / 180))
+		case "atan":
+			den, num := pop(), pop()
+			a := math.Atan2(num, den) * 180 / math.Pi
+			if a < 0 {
+				a += 360
+			}
+			push(a)
+		case "exp":
+			e, b := pop(), pop()
+			push(math.Pow(b, e))
+		case "ln":
+			push(math.Log(pop()))
+		case "log":
+			push(math.Log10(pop()))
+		case "eq":
+			b, a := pop(), pop()
+			push(b2f(a == b))
+		case "ne":
+			b, a := pop(), pop()
+			push(b2f(a != b))
+		case "gt":
+			b, a := pop(), pop()
+			push(b2f(a > b))
+		case "ge":
+			b, a := pop(), pop()
+			push(b2f(a >= b))
+		case "lt":
+			b, a := pop(), pop()
+			push(b2f(a < b))
+		case "le":
+			b, a := pop(), pop()
+			push(b2f(a <= b))
+		case "and":
+			b, a := int(pop()), int(pop())
+			push(float64(a & b))
+		case "or":
+			b, a := int(pop()), int(pop())
+			push(float64(a | b))
+		case "xor":
+			b, a := int(pop()), int(pop())
+			push(float64(a ^ b))
+		case "not":
+			push(b2f(pop() == 0))
+		case "bitshift":
+			shift, a := int(pop()), int(pop())
+			if shift >= 0 {
+				push(float64(a << uint(shift)))
+			} else {
+				push(float64(a >> uint(-shift)))
+			}
+		case "dup":
+			v := pop()
+			push(v)
+			push(v)
+		case "exch":
+			b, a := pop(), pop()
+			push(b)
+			push(a)
+		case "pop":
+			pop()
+		case "copy":
+			n := int(pop())
+			if n > 0 && n <= len(s) {
+				s = append(s, s[len(s)-n:]...)
+			}
+		case "index":
+			n := int(pop())
+			if n >= 0 && n < len(s) {
+				push(s[len(s)-1-n])
+			}
+		case "roll":
+			j, n := int(pop()), int(pop())
+			if n > 0 && n <= len(s) {
+				top := s[len(s)-n:]
+				j = ((j % n) + n) % n
+				rolled := append(append([]float64{}, top[n-j:]...), top[:n-j]...)
+				copy(top, rolled)
+			}
+		}
+	}
+	*stack = s
+}
@@ -0,0 +1,113 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// SRGBProfile returns an ICC version 2 display profile for sRGB
+// (IEC 61966-2.1), suitable as a PDF/A output intent. The primaries are
+// adapted to the D50 profile connection space.
+func SRGBProfile() []byte {
+	type tag struct {
+		sig  string
+		data []byte
+	}
+	trc := srgbCurve(1024)
+	tags := []tag{
+		{"desc", textDescription("sRGB IEC61966-2.1")},
+		{"cprt", textTag("No copyright, use freely")},
+		{"wtpt", xyzTag(0.9642, 1.0, 0.8249)},
+		{"rXYZ", xyzTag(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyzTag(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyzTag(0.1431, 0.0606, 0.7141)},
+		{"rTRC", trc},
+		{"gTRC", trc},
+		{"bTRC", trc},
+	}
+
+	const headerLen = 128
+	tableLen := 4 + 12*len(tags)
+	var body bytes.Buffer
+	table := make([]byte, tableLen)
+	binary.BigEndian.PutUint32(table, uint32(len(tags)))
+	offsets := map[*byte]uint32{}
+	for i, t := range tags {
+		off, shared := offsets[&t.data[0]]
+		if !shared {
+			off = uint32(headerLen + tableLen + body.Len())
+			offsets[&t.data[0]] = off
+			body.Write(t.data)
+			for body.Len()%4 != 0 {
+				body.WriteByte(0)
+			}
+		}
+		e := table[4+12*i:]
+		copy(e, t.sig)
+		binary.BigEndian.PutUint32(e[4:], off)
+		binary.BigEndian.PutUint32(e[8:], uint32(len(t.data)))
+	}
+
+	size := headerLen + tableLen + body.Len()
+	h := make([]byte, headerLen)
+	binary.BigEndian.PutUint32(h[0:], uint32(size))
+	binary.BigEndian.PutUint32(h[8:], 0x02100000) // version 2.1
+	copy(h[12:], "mntr")
+	copy(h[16:], "RGB ")
+	copy(h[20:], "XYZ ")
+	binary.BigEndian.PutUint16(h[24:], 2000) // creation date: 2000-01-01
+	binary.BigEndian.PutUint16(h[26:], 1)
+	binary.BigEndian.PutUint16(h[28:], 1)
+	copy(h[36:], "acsp")
+	copy(h[68:], s15Fixed16(0.9642, 1.0, 0.8249)) // D50 illuminant
+
+	out := make([]byte, 0, size)
+	out = append(out, h...)
+	out = append(out, table...)
+	return append(out, body.Bytes()...)
+}
+
+func s15Fixed16(vals ...float64) []byte {
+	b := make([]byte, 4*len(vals))
+	for i, v := range vals {
+		binary.BigEndian.PutUint32(b[4*i:], uint32(int32(math.Round(v*65536))))
+	}
+	return b
+}
+
+func xyzTag(x, y, z float64) []byte {
+	b := append([]byte("XYZ \x00\x00\x00\x00"), s15Fixed16(x, y, z)...)
+	return b
+}
+
+func textTag(s string) []byte {
+	b := append([]byte("text\x00\x00\x00\x00"), s...)
+	return append(b, 0)
+}
+
+func textDescription(s string) []byte {
+	b := []byte("desc\x00\x00\x00\x00")
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)+1))
+	b = append(b, s...)
+	b = append(b, 0)
+	b = append(b, make([]byte, 4+4)...)    // Unicode language code and count
+	b = append(b, make([]byte, 2+1+67)...) // ScriptCode code, count and data
+	return b
+}
+
+// srgbCurve samples the sRGB transfer function into a curveType tag.
+func srgbCurve(n int) []byte {
+	b := []byte("curv\x00\x00\x00\x00")
+	b = binary.BigEndian.AppendUint32(b, uint32(n))
+	for i := 0; i < n; i++ {
+		v := float64(i) / float64(n-1)
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		b = binary.BigEndian.AppendUint16(b, uint16(math.Round(v*65535)))
+	}
+	return b
+}
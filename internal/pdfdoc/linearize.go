@@ -0,0 +1,439 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// linearization holds the objects of a linearized file by section, in the
+// order they are written (ISO 32000-1, Annex F).
+type linearization struct {
+	open   []int   // catalog and document-level objects (part 4)
+	first  []int   // objects used by the first page (part 6)
+	pages  [][]int // page object and private objects of each other page (part 7)
+	shared []int   // objects shared by other pages (part 8)
+	other  []int   // everything else (part 9)
+
+	// refs lists, per page, the identifiers of the shared objects the page
+	// uses: indexes into first, then into shared.
+	refs [][]int
+}
+
+// writeLinearized writes the document in linearized form: the objects
+// needed to display the first page come first, after a first-page
+// cross-reference section, and hint tables tell viewers where the other
+// pages are.
+func (d *Document) writeLinearized(w io.Writer) error {
+	pages := d.Pages()
+	if len(pages) == 0 {
+		return fmt.Errorf("pdf: cannot linearize a document without pages")
+	}
+	l := d.linearize(pages)
+
+	// Objects after the first page are numbered from 1 and listed in the
+	// main cross-reference section; the first-page section follows them.
+	renum := map[int]int{}
+	var rest []int
+	for _, p := range l.pages {
+		rest = append(rest, p...)
+	}
+	rest = append(rest, l.shared...)
+	rest = append(rest, l.other...)
+	for i, num := range rest {
+		renum[num] = i + 1
+	}
+	linNum := len(rest) + 1
+	next := linNum + 1
+	for _, num := range l.open {
+		renum[num] = next
+		next++
+	}
+	hintNum := next
+	next++
+	for _, num := range l.first {
+		renum[num] = next
+		next++
+	}
+	size := next
+
+	wr := &writer{doc: d, renum: renum}
+	blobs := map[int][]byte{}
+	var content bytes.Buffer
+	for _, part := range [][]int{l.open, l.first, rest} {
+		for _, num := range part {
+			wr.buf.Reset()
+			wr.writeIndirect(num)
+			blobs[num] = bytes.Clone(wr.buf.Bytes())
+			content.Write(blobs[num])
+		}
+	}
+
+	trailer := Dict{"Size": size}
+	for k, v := range d.Trailer {
+		switch k {
+		case "Size", "Prev", "XRefStm":
+			continue
+		}
+		trailer[k] = v
+	}
+	if _, ok := trailer["ID"]; !ok {
+		trailer["ID"] = fileID(content.Bytes())
+	}
+	wr.buf.Reset()
+	wr.writeObject(trailer)
+	trailerBody := bytes.Clone(wr.buf.Bytes()[2:]) // without "<<"
+
+	// The linearization dictionary and the first-page section have a fixed
+	// size, so that offsets can be computed before they are filled in.
+	hdr := header(d.Version)
+	linDict := func(length, hintOff, hintLen, end, mainEntries int) string {
+		return fmt.Sprintf("%d 0 obj\n<</Linearized 1/L %010d/H [%010d %010d]/O %d/E %010d/N %d/T %010d>>\nendobj\n",
+			linNum, length, hintOff, hintLen, renum[pages[0].Num], end, len(pages), mainEntries)
+	}
+	firstXref := func(offsets []int, prev int) string {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "xref\n%d %d\n", linNum, size-linNum)
+		for _, off := range offsets {
+			fmt.Fprintf(&b, "%010d 00000 n\r\n", off)
+		}
+		fmt.Fprintf(&b, "trailer\n<</Prev %010d", prev)
+		b.Write(trailerBody)
+		b.WriteString("\nstartxref\n0\n%%EOF\n")
+		return b.String()
+	}
+	start := len(hdr) + len(linDict(0, 0, 0, 0, 0)) + len(firstXref(make([]int, size-linNum), 0))
+
+	// layout returns the offset of every object for a hint stream of the
+	// given length, and the offsets of the hint stream, of the end of the
+	// first page and of the main cross-reference section.
+	layout := func(hintLen int) (offsets map[int]int, hintOff, end, main int) {
+		offsets = map[int]int{}
+		pos := start
+		for _, num := range l.open {
+			offsets[num] = pos
+			pos += len(blobs[num])
+		}
+		hintOff = pos
+		pos += hintLen
+		for _, num := range l.first {
+			offsets[num] = pos
+			pos += len(blobs[num])
+		}
+		end = pos
+		for _, num := range rest {
+			offsets[num] = pos
+			pos += len(blobs[num])
+		}
+		return offsets, hintOff, end, pos
+	}
+
+	// Offsets in hint tables are computed as if the hint stream were
+	// absent.
+	adjusted, _, _, _ := layout(0)
+	hint := l.hintStream(adjusted, blobs, renum, pages)
+	wr.buf.Reset()
+	wr.writeAs(hintNum, hint, true)
+	hintBlob := bytes.Clone(wr.buf.Bytes())
+
+	offsets, hintOff, end, main := layout(len(hintBlob))
+	firstOffsets := []int{len(hdr)}
+	for _, num := range l.open {
+		firstOffsets = append(firstOffsets, offsets[num])
+	}
+	firstOffsets = append(firstOffsets, hintOff)
+	for _, num := range l.first {
+		firstOffsets = append(firstOffsets, offsets[num])
+	}
+
+	var out bytes.Buffer
+	mainHead := fmt.Sprintf("xref\n0 %d", len(rest)+1)
+	out.WriteString(mainHead + "\n0000000000 65535 f\r\n")
+	for _, num := range rest {
+		fmt.Fprintf(&out, "%010d 00000 n\r\n", offsets[num])
+	}
+	fmt.Fprintf(&out, "trailer\n<</Size %d>>\nstartxref\n%d\n%%%%EOF\n", len(rest)+1, len(hdr)+len(linDict(0, 0, 0, 0, 0)))
+	mainXref := out.Bytes()
+	length := main + len(mainXref)
+
+	out = bytes.Buffer{}
+	out.WriteString(hdr)
+	// T is the offset of the white-space character preceding the first
+	// entry of the main cross-reference section.
+	out.WriteString(linDict(length, hintOff, len(hintBlob), end, main+len(mainHead)))
+	out.WriteString(firstXref(firstOffsets, main))
+	for _, num := range l.open {
+		out.Write(blobs[num])
+	}
+	out.Write(hintBlob)
+	for _, num := range l.first {
+		out.Write(blobs[num])
+	}
+	for _, num := range rest {
+		out.Write(blobs[num])
+	}
+	out.Write(mainXref)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// linearize sorts the reachable objects into the sections of a linearized
+// file.
+func (d *Document) linearize(pages []Ref) *linearization {
+	root, _ := d.Trailer["Root"].(Ref)
+	isPage := map[int]bool{}
+	for _, p := range pages {
+		isPage[p.Num] = true
+	}
+
+	// The objects of a page are the ones reachable from it without going
+	// through the page tree or other pages.
+	used := make([][]int, len(pages))
+	owners := map[int]int{}
+	for i, p := range pages {
+		roots := []Object{p}
+		if d.Dict(p)["Resources"] == nil {
+			roots = append(roots, d.PageAttr(p, "Resources"))
+		}
+		used[i] = d.walk(roots, func(num int) bool {
+			if num == p.Num {
+				return true
+			}
+			return !isPage[num] && num != root.Num && d.Dict(Ref{Num: num})["Type"] != Name("Pages")
+		})
+		for _, num := range used[i] {
+			owners[num]++
+		}
+	}
+
+	l := &linearization{pages: make([][]int, len(pages)-1), refs: make([][]int, len(pages))}
+	placed := map[int]bool{}
+	place := func(list *[]int, num int) {
+		if !placed[num] {
+			placed[num] = true
+			*list = append(*list, num)
+		}
+	}
+
+	// Document-level objects needed to open the file, unless a page uses
+	// them.
+	place(&l.open, root.Num)
+	cat := d.Catalog()
+	roots := []Object{cat["ViewerPreferences"], cat["Threads"], cat["OpenAction"], cat["AcroForm"], d.Trailer["Encrypt"]}
+	if cat["PageMode"] == Name("UseOutlines") {
+		roots = append(roots, cat["Outlines"])
+	}
+	for _, num := range d.walk(roots, func(num int) bool {
+		return !isPage[num] && owners[num] == 0 && num != root.Num
+	}) {
+		place(&l.open, num)
+	}
+
+	for _, num := range used[0] {
+		place(&l.first, num)
+	}
+	for i := 1; i < len(pages); i++ {
+		for _, num := range used[i] {
+			if owners[num] == 1 {
+				place(&l.pages[i-1], num)
+			}
+		}
+	}
+	for i := 1; i < len(pages); i++ {
+		for _, num := range used[i] {
+			if owners[num] > 1 {
+				place(&l.shared, num)
+			}
+		}
+	}
+	for _, num := range d.reachable() {
+		place(&l.other, num)
+	}
+
+	ids := map[int]int{}
+	for i, num := range l.first {
+		ids[num] = i
+	}
+	for i, num := range l.shared {
+		ids[num] = len(l.first) + i
+	}
+	for i := range pages {
+		for _, num := range used[i] {
+			if owners[num] > 1 {
+				l.refs[i] = append(l.refs[i], ids[num])
+			}
+		}
+	}
+	return l
+}
+
+// walk returns the objects reachable from roots in discovery order. It
+// does not follow /Parent entries, nor enter objects for which follow
+// returns false.
+func (d *Document) walk(roots []Object, follow func(num int) bool) []int {
+	var order []int
+	seen := map[int]bool{}
+	queue := roots
+	for len(queue) > 0 {
+		o := queue[0]
+		queue = queue[1:]
+		switch v := o.(type) {
+		case Ref:
+			target, ok := d.objects[v.Num]
+			if !ok || seen[v.Num] || !follow(v.Num) {
+				continue
+			}
+			seen[v.Num] = true
+			order = append(order, v.Num)
+			queue = append(queue, target)
+		case Dict:
+			for _, k := range sortedKeys(v) {
+				if k != "Parent" {
+					queue = append(queue, v[k])
+				}
+			}
+		case Array:
+			queue = append(queue, v...)
+		case *Stream:
+			queue = append(queue, v.Dict)
+		}
+	}
+	return order
+}
+
+// hintStream builds the primary hint stream: the page offset hint table
+// followed by the shared object hint table. Each shared object forms a
+// group of its own.
+func (l *linearization) hintStream(offsets map[int]int, blobs map[int][]byte, renum map[int]int, pages []Ref) *Stream {
+	span := func(objs []int) int {
+		last := objs[len(objs)-1]
+		return offsets[last] + len(blobs[last]) - offsets[objs[0]]
+	}
+	sections := append([][]int{l.first}, l.pages...)
+	counts := make([]int, len(sections))
+	lengths := make([]int, len(sections))
+	for i, objs := range sections {
+		counts[i] = len(objs)
+		lengths[i] = span(objs)
+	}
+	minCount, maxCount := bounds(counts)
+	minLen, maxLen := bounds(lengths)
+	maxRefs, maxID := 0, 0
+	for _, refs := range l.refs {
+		maxRefs = max(maxRefs, len(refs))
+		for _, id := range refs {
+			maxID = max(maxID, id)
+		}
+	}
+	countBits := bits.Len(uint(maxCount - minCount))
+	lenBits := bits.Len(uint(maxLen - minLen))
+	refBits := bits.Len(uint(maxRefs))
+	idBits := bits.Len(uint(maxID))
+
+	// Page offset hint table (Tables F.3 and F.4). Content streams are
+	// described as spanning the whole page.
+	var b bitWriter
+	b.write(minCount, 32)
+	b.write(offsets[pages[0].Num], 32)
+	b.write(countBits, 16)
+	b.write(minLen, 32)
+	b.write(lenBits, 16)
+	b.write(0, 32)
+	b.write(0, 16)
+	b.write(minLen, 32)
+	b.write(lenBits, 16)
+	b.write(refBits, 16)
+	b.write(idBits, 16)
+	b.write(0, 16)
+	b.write(1, 16)
+	for _, n := range counts {
+		b.write(n-minCount, countBits)
+	}
+	b.align()
+	for _, n := range lengths {
+		b.write(n-minLen, lenBits)
+	}
+	b.align()
+	for _, refs := range l.refs {
+		b.write(len(refs), refBits)
+	}
+	b.align()
+	for _, refs := range l.refs {
+		for _, id := range refs {
+			b.write(id, idBits)
+		}
+	}
+	b.align()
+	for _, n := range lengths {
+		b.write(n-minLen, lenBits)
+	}
+	b.align()
+	sharedOffset := b.buf.Len()
+
+	// Shared object hint table (Tables F.5 and F.6).
+	groups := append(append([]int{}, l.first...), l.shared...)
+	groupLens := make([]int, len(groups))
+	for i, num := range groups {
+		groupLens[i] = len(blobs[num])
+	}
+	minGroup, maxGroup := bounds(groupLens)
+	groupBits := bits.Len(uint(maxGroup - minGroup))
+	if len(l.shared) > 0 {
+		b.write(renum[l.shared[0]], 32)
+		b.write(offsets[l.shared[0]], 32)
+	} else {
+		b.write(0, 32)
+		b.write(0, 32)
+	}
+	b.write(len(l.first), 32)
+	b.write(len(groups), 32)
+	b.write(0, 16)
+	b.write(minGroup, 32)
+	b.write(groupBits, 16)
+	for _, n := range groupLens {
+		b.write(n-minGroup, groupBits)
+	}
+	b.align()
+	for range groups {
+		b.write(0, 1) // no MD5 signature
+	}
+	b.align()
+
+	return &Stream{Dict: Dict{"S": sharedOffset}, Data: b.buf.Bytes()}
+}
+
+func bounds(v []int) (lo, hi int) {
+	if len(v) == 0 {
+		return 0, 0
+	}
+	lo, hi = v[0], v[0]
+	for _, n := range v[1:] {
+		lo, hi = min(lo, n), max(hi, n)
+	}
+	return lo, hi
+}
+
+// bitWriter packs unsigned integers most significant bit first.
+type bitWriter struct {
+	buf bytes.Buffer
+	cur byte
+	n   int
+}
+
+func (b *bitWriter) write(v, width int) {
+	for i := width - 1; i >= 0; i-- {
+		b.cur = b.cur<<1 | byte(v>>i&1)
+		if b.n++; b.n == 8 {
+			b.buf.WriteByte(b.cur)
+			b.cur, b.n = 0, 0
+		}
+	}
+}
+
+// align pads the last byte with zero bits.
+func (b *bitWriter) align() {
+	if b.n > 0 {
+		b.write(0, 8-b.n)
+	}
+}
@@ -0,0 +1,115 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+func TestLinearize(t *testing.T) {
+	d, err := Parse(chromeLike(t, 3, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Linearize = true
+	out, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &parser{data: out, pos: bytes.Index(out, []byte("\n%\xE2")) + 6}
+	_, o, err := p.parseIndirect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lin, ok := o.(Dict)
+	if !ok || lin["Linearized"] != 1 {
+		t.Fatalf("first object is %v, want a linearization dictionary", o)
+	}
+	if lin["L"] != len(out) {
+		t.Errorf("/L %v, want %d", lin["L"], len(out))
+	}
+	if lin["N"] != 3 {
+		t.Errorf("/N %v, want 3", lin["N"])
+	}
+	if T := lin["T"].(int); !isWhite(out[T]) || !bytes.HasPrefix(out[T+1:], []byte("0000000000 65535 f")) {
+		t.Errorf("/T %d does not point before the main cross-reference entries", T)
+	}
+
+	parsed, err := Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := parsed.Pages()
+	if len(pages) != 3 {
+		t.Fatalf("got %d pages, want 3", len(pages))
+	}
+	if lin["O"] != pages[0].Num {
+		t.Errorf("/O %v, want %d", lin["O"], pages[0].Num)
+	}
+	offset := func(ref Object) int {
+		return bytes.Index(out, []byte(fmt.Sprintf("\n%d 0 obj\n", ref.(Ref).Num))) + 1
+	}
+	end := lin["E"].(int)
+	for i, p := range pages {
+		data, err := parsed.Resolve(parsed.Dict(p)["Contents"]).(*Stream).Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("(Page %d)", i+1); !bytes.Contains(data, []byte(want)) {
+			t.Errorf("page %d: unexpected content %q", i+1, data)
+		}
+		if first := offset(parsed.Dict(p)["Contents"]) < end; first != (i == 0) {
+			t.Errorf("page %d: content in first-page section = %v", i+1, first)
+		}
+	}
+
+	h := lin["H"].(Array)
+	hintOff, hintLen := h[0].(int), h[1].(int)
+	p = &parser{data: out, pos: hintOff}
+	_, o, err = p.parseIndirect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.pos > hintOff+hintLen {
+		t.Errorf("hint stream ends at %d, past /H", p.pos)
+	}
+	hint, err := o.(*Stream).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The hint tables locate objects as if the hint stream were absent.
+	if got, want := int(binary.BigEndian.Uint32(hint[4:])), offset(pages[0])-hintLen; got != want {
+		t.Errorf("first page object at %d in hint table, want %d", got, want)
+	}
+	if s := o.(*Stream).Dict["S"].(int); s <= 0 || s >= len(hint) {
+		t.Errorf("/S %d out of range", s)
+	}
+}
+
+func TestLinearizeEncrypted(t *testing.T) {
+	d, err := Parse(chromeLike(t, 2, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Encrypt(EncryptOptions{UserPassword: "user", KeyBits: 128}); err != nil {
+		t.Fatal(err)
+	}
+	d.Linearize = true
+	out, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseWithPassword(out, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parsed.Resolve(parsed.Dict(parsed.Pages()[1])["Contents"]).(*Stream).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("(Page 2)")) {
+		t.Errorf("unexpected content %q", data)
+	}
+}
@@ -0,0 +1,135 @@
+// Package pdfdoc implements a small PDF object model that can parse the
+// documents Chrome produces, modify them and write them back out.
+package pdfdoc
+
+import (
+	"fmt"
+	"unicode/utf16"
+)
+
+// Object is any PDF object: nil (null), bool, int, float64, Name, String,
+// HexString, Array, Dict, *Stream or Ref.
+type Object any
+
+// Name is a PDF name object such as /Type.
+type Name string
+
+// String is a PDF literal string, written as (...).
+type String []byte
+
+// HexString is a PDF string written in hexadecimal form, as <...>.
+type HexString []byte
+
+// Array is a PDF array.
+type Array []Object
+
+// Dict is a PDF dictionary.
+type Dict map[Name]Object
+
+// Ref is an indirect reference to an object in a Document.
+type Ref struct {
+	Num int
+	Gen int
+}
+
+func (r Ref) String() string {
+	return fmt.Sprintf("%d %d R", r.Num, r.Gen)
+}
+
+// Stream is a PDF stream. Data holds the encoded bytes as described by
+// the /Filter entry of Dict.
+type Stream struct {
+	Dict Dict
+	Data []byte
+}
+
+// TextString encodes s as a PDF text string, using UTF-16BE with a byte
+// order mark when s is not plain ASCII (e.g. Thai).
+func TextString(s string) String {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return String(s)
+	}
+
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2, 2+2*len(units))
+	b[0], b[1] = 0xFE, 0xFF
+	for _, u := range units {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return String(b)
+}
+
+// DecodeTextString decodes a PDF text string into a Go string.
+func DecodeTextString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		b = b[2:]
+		units := make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	if len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF {
+		return string(b[3:])
+	}
+	// PDFDocEncoding matches Latin-1 for the printable range.
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// StringBytes returns the bytes of a String or HexString.
+func StringBytes(o Object) ([]byte, bool) {
+	switch v := o.(type) {
+	case String:
+		return v, true
+	case HexString:
+		return v, true
+	}
+	return nil, false
+}
+
+// Number returns o as a float64 if it is an int or float64.
+func Number(o Object) (float64, bool) {
+	switch v := o.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// Clone returns a deep copy of o. Refs are copied as-is.
+func Clone(o Object) Object {
+	switch v := o.(type) {
+	case Dict:
+		c := make(Dict, len(v))
+		for k, e := range v {
+			c[k] = Clone(e)
+		}
+		return c
+	case Array:
+		c := make(Array, len(v))
+		for i, e := range v {
+			c[i] = Clone(e)
+		}
+		return c
+	case String:
+		return append(String(nil), v...)
+	case HexString:
+		return append(HexString(nil), v...)
+	case *Stream:
+		return &Stream{Dict: Clone(v.Dict).(Dict), Data: append([]byte(nil), v.Data...)}
+	}
+	return o
+}
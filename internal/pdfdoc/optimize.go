@@ -0,0 +1,441 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"sort"
+)
+
+// OptimizeOptions configures Optimize.
+type OptimizeOptions struct {
+	// MaxImageDPI downsamples images drawn at a higher resolution.
+	// Zero disables downsampling.
+	MaxImageDPI float64
+	// JPEGQuality is used to re-encode downsampled JPEG images.
+	// Default is 85.
+	JPEGQuality int
+}
+
+// OptimizeStats reports what Optimize changed.
+type OptimizeStats struct {
+	DuplicatesRemoved   int
+	ImagesDownsampled   int
+	StreamsRecompressed int
+}
+
+// Optimize reduces the size of the document: identical images, fonts and
+// other shared resources are stored once, images drawn above
+// MaxImageDPI are downsampled, and Flate streams are recompressed at the
+// best compression level.
+func (d *Document) Optimize(opt OptimizeOptions) OptimizeStats {
+	var stats OptimizeStats
+	// Duplicates are merged first, so that an image drawn at several sizes
+	// keeps the resolution needed by the largest one.
+	stats.DuplicatesRemoved = d.dedupe()
+	if opt.MaxImageDPI > 0 {
+		stats.ImagesDownsampled = d.downsampleImages(opt)
+	}
+	stats.StreamsRecompressed = d.recompressStreams()
+	return stats
+}
+
+// dedupe merges identical streams and resource dictionaries. Merging
+// objects can make the dictionaries that reference them identical (e.g.
+// font descriptors pointing at the same font file), so it repeats until
+// nothing changes.
+func (d *Document) dedupe() int {
+	removed := 0
+	for {
+		identity := make(map[int]int, len(d.objects))
+		for num := range d.objects {
+			identity[num] = num
+		}
+		w := &writer{doc: d, renum: identity}
+
+		nums := make([]int, 0, len(d.objects))
+		for num := range d.objects {
+			nums = append(nums, num)
+		}
+		sort.Ints(nums)
+
+		canonical := map[[32]byte]int{}
+		replace := map[int]int{}
+		for _, num := range nums {
+			o := d.objects[num]
+			if !dedupable(o) {
+				continue
+			}
+			w.buf.Reset()
+			w.writeObject(o)
+			key := sha256.Sum256(w.buf.Bytes())
+			if first, ok := canonical[key]; ok {
+				replace[num] = first
+				continue
+			}
+			canonical[key] = num
+		}
+		if len(replace) == 0 {
+			return removed
+		}
+		for num := range replace {
+			delete(d.objects, num)
+		}
+		for num, o := range d.objects {
+			d.objects[num] = replaceRefs(o, replace)
+		}
+		for k, v := range d.Trailer {
+			d.Trailer[k] = replaceRefs(v, replace)
+		}
+		removed += len(replace)
+	}
+}
+
+// dedupable reports whether o can be shared by several referrers. Pages,
+// annotations and other objects whose identity matters are excluded.
+func dedupable(o Object) bool {
+	switch v := o.(type) {
+	case *Stream:
+		return v.Dict["Type"] != Name("Metadata")
+	case Dict:
+		switch v["Type"] {
+		case Name("Font"), Name("FontDescriptor"), Name("ExtGState"):
+			return true
+		}
+	}
+	return false
+}
+
+func replaceRefs(o Object, m map[int]int) Object {
+	switch v := o.(type) {
+	case Ref:
+		if n, ok := m[v.Num]; ok {
+			return Ref{Num: n}
+		}
+	case Dict:
+		for k, e := range v {
+			v[k] = replaceRefs(e, m)
+		}
+	case Array:
+		for i, e := range v {
+			v[i] = replaceRefs(e, m)
+		}
+	case *Stream:
+		replaceRefs(v.Dict, m)
+	}
+	return o
+}
+
+// recompressStreams recompresses uncompressed and Flate streams at the
+// best compression level, keeping the result only if it is smaller.
+func (d *Document) recompressStreams() int {
+	count := 0
+	for _, o := range d.objects {
+		s, ok := o.(*Stream)
+		if !ok || s.Dict["Type"] == Name("Metadata") {
+			continue
+		}
+		filters := s.Filters()
+		if len(filters) > 1 || (len(filters) == 1 && filters[0] != "FlateDecode") {
+			continue
+		}
+		data, err := s.Decode()
+		if err != nil {
+			continue
+		}
+		packed := deflate(data, zlib.BestCompression)
+		if len(packed) >= len(s.Data) {
+			continue
+		}
+		s.Data = packed
+		s.Dict["Filter"] = Name("FlateDecode")
+		delete(s.Dict, "DecodeParms")
+		count++
+	}
+	return count
+}
+
+// downsampleImages reduces the resolution of images drawn above
+// opt.MaxImageDPI.
+func (d *Document) downsampleImages(opt OptimizeOptions) int {
+	// The lowest resolution each image is drawn at, in dots per inch.
+	dpi := map[int]float64{}
+	for _, p := range d.Pages() {
+		res := d.Dict(d.PageAttr(p, "Resources"))
+		for _, c := range d.contentStreams(p) {
+			d.imageResolutions(c, res, identity, dpi, 0)
+		}
+	}
+
+	count := 0
+	for num, lowest := range dpi {
+		// Small reductions are not worth the loss of quality.
+		if lowest <= opt.MaxImageDPI*1.1 {
+			continue
+		}
+		s, ok := d.objects[num].(*Stream)
+		if ok && d.downsample(s, opt.MaxImageDPI/lowest, opt.JPEGQuality) {
+			count++
+		}
+	}
+	return count
+}
+
+// contentStreams returns the decoded content streams of a page.
+func (d *Document) contentStreams(page Ref) [][]byte {
+	contents := d.Dict(page)["Contents"]
+	streams := Array{contents}
+	if arr := d.Array(contents); arr != nil {
+		streams = arr
+	}
+	var out [][]byte
+	for _, c := range streams {
+		if s, ok := d.Resolve(c).(*Stream); ok {
+			if data, err := s.Decode(); err == nil {
+				out = append(out, data)
+			}
+		}
+	}
+	return out
+}
+
+// imageResolutions records in dpi the resolution at which the content
+// draws each image, following form XObjects.
+func (d *Document) imageResolutions(content []byte, res Dict, ctm matrix, dpi map[int]float64, depth int) {
+	if depth > 8 {
+		return
+	}
+	var stack []matrix
+	scanContent(content, func(op string, args []Object) {
+		switch op {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if len(stack) > 0 {
+				ctm = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if m, ok := toMatrix(args); ok {
+				ctm = m.mul(ctm)
+			}
+		case "Do":
+			if len(args) != 1 {
+				return
+			}
+			name, _ := args[0].(Name)
+			ref, ok := d.Dict(res["XObject"])[name].(Ref)
+			if !ok {
+				return
+			}
+			xo, ok := d.Resolve(ref).(*Stream)
+			if !ok {
+				return
+			}
+			switch xo.Dict["Subtype"] {
+			case Name("Image"):
+				// The image fills the unit square of the current matrix.
+				wpt := math.Hypot(ctm[0], ctm[1])
+				hpt := math.Hypot(ctm[2], ctm[3])
+				if wpt == 0 || hpt == 0 {
+					return
+				}
+				w, _ := Number(d.Resolve(xo.Dict["Width"]))
+				h, _ := Number(d.Resolve(xo.Dict["Height"]))
+				r := math.Min(w/wpt, h/hpt) * 72
+				if cur, seen := dpi[ref.Num]; !seen || r < cur {
+					dpi[ref.Num] = r
+				}
+			case Name("Form"):
+				m := identity
+				if fm, ok := toMatrix(d.Array(xo.Dict["Matrix"])); ok {
+					m = fm
+				}
+				formRes := d.Dict(xo.Dict["Resources"])
+				if formRes == nil {
+					formRes = res
+				}
+				if data, err := xo.Decode(); err == nil {
+					d.imageResolutions(data, formRes, m.mul(ctm), dpi, depth+1)
+				}
+			}
+		}
+	})
+}
+
+// downsample scales an 8-bit Gray or RGB image (and its soft mask) by
+// factor, re-encoding it with the same kind of compression. It reports
+// whether the image was replaced.
+func (d *Document) downsample(s *Stream, factor float64, quality int) bool {
+	if s.Dict["ImageMask"] == true || intOr(d.Resolve(s.Dict["BitsPerComponent"]), 8) != 8 {
+		return false
+	}
+	if _, ok := d.Resolve(s.Dict["Mask"]).(*Stream); ok {
+		// An explicit mask has its own size; leave the pair alone.
+		return false
+	}
+	w := intOr(d.Resolve(s.Dict["Width"]), 0)
+	h := intOr(d.Resolve(s.Dict["Height"]), 0)
+	nw := int(math.Ceil(float64(w) * factor))
+	nh := int(math.Ceil(float64(h) * factor))
+	if w == 0 || h == 0 || nw < 1 || nh < 1 {
+		return false
+	}
+
+	comps := d.colorComponents(s.Dict["ColorSpace"])
+	if comps != 1 && comps != 3 {
+		return false
+	}
+	pixels, jpg, ok := imagePixels(s, w, h, comps)
+	if !ok {
+		return false
+	}
+	var mask *Stream
+	var maskPixels []byte
+	if m, isStream := d.Resolve(s.Dict["SMask"]).(*Stream); isStream {
+		if intOr(d.Resolve(m.Dict["Width"]), 0) != w || intOr(d.Resolve(m.Dict["Height"]), 0) != h {
+			return false
+		}
+		if maskPixels, _, ok = imagePixels(m, w, h, 1); !ok {
+			return false
+		}
+		mask = m
+	}
+
+	data, ok := encodeImage(scalePixels(pixels, w, h, comps, nw, nh), nw, nh, comps, jpg, quality)
+	if !ok || len(data) >= len(s.Data) {
+		return false
+	}
+	setImage(s, data, nw, nh, jpg)
+	if mask != nil {
+		scaled := scalePixels(maskPixels, w, h, 1, nw, nh)
+		setImage(mask, deflate(scaled, zlib.BestCompression), nw, nh, false)
+	}
+	return true
+}
+
+// colorComponents returns the number of components of a device or
+// ICC-based color space, or 0 for other color spaces.
+func (d *Document) colorComponents(cs Object) int {
+	cs = d.Resolve(cs)
+	if arr, ok := cs.(Array); ok && len(arr) == 2 && arr[0] == Name("ICCBased") {
+		if icc, ok := d.Resolve(arr[1]).(*Stream); ok {
+			return intOr(d.Resolve(icc.Dict["N"]), 0)
+		}
+	}
+	switch cs {
+	case Name("DeviceGray"):
+		return 1
+	case Name("DeviceRGB"):
+		return 3
+	}
+	return 0
+}
+
+// imagePixels returns the samples of a Flate or JPEG image, and whether
+// it was a JPEG.
+func imagePixels(s *Stream, w, h, comps int) ([]byte, bool, bool) {
+	filters := s.Filters()
+	if len(filters) == 1 && filters[0] == "DCTDecode" {
+		img, err := jpeg.Decode(bytes.NewReader(s.Data))
+		if err != nil || img.Bounds().Dx() != w || img.Bounds().Dy() != h {
+			return nil, false, false
+		}
+		out := make([]byte, 0, w*h*comps)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				c := img.At(x, y)
+				if comps == 1 {
+					out = append(out, color.GrayModel.Convert(c).(color.Gray).Y)
+					continue
+				}
+				r, g, b, _ := c.RGBA()
+				out = append(out, byte(r>>8), byte(g>>8), byte(b>>8))
+			}
+		}
+		return out, true, true
+	}
+	if !s.Decodable() {
+		return nil, false, false
+	}
+	data, err := s.Decode()
+	if err != nil || len(data) < w*h*comps {
+		return nil, false, false
+	}
+	return data[:w*h*comps], false, true
+}
+
+// scalePixels resizes 8-bit samples by averaging the source pixels that
+// each destination pixel covers.
+func scalePixels(src []byte, w, h, comps, nw, nh int) []byte {
+	dst := make([]byte, nw*nh*comps)
+	sum := make([]int, comps)
+	for y := 0; y < nh; y++ {
+		y0, y1 := y*h/nh, (y+1)*h/nh
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < nw; x++ {
+			x0, x1 := x*w/nw, (x+1)*w/nw
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			for i := range sum {
+				sum[i] = 0
+			}
+			for sy := y0; sy < y1; sy++ {
+				row := src[(sy*w)*comps:]
+				for sx := x0; sx < x1; sx++ {
+					for i := 0; i < comps; i++ {
+						sum[i] += int(row[sx*comps+i])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			for i := 0; i < comps; i++ {
+				dst[(y*nw+x)*comps+i] = byte((sum[i] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
+
+func encodeImage(pixels []byte, w, h, comps int, jpg bool, quality int) ([]byte, bool) {
+	if !jpg {
+		return deflate(pixels, zlib.BestCompression), true
+	}
+	if quality <= 0 {
+		quality = 85
+	}
+	var img image.Image
+	if comps == 1 {
+		img = &image.Gray{Pix: pixels, Stride: w, Rect: image.Rect(0, 0, w, h)}
+	} else {
+		rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+		for i := 0; i < w*h; i++ {
+			copy(rgba.Pix[i*4:], pixels[i*3:i*3+3])
+			rgba.Pix[i*4+3] = 0xff
+		}
+		img = rgba
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+func setImage(s *Stream, data []byte, w, h int, jpg bool) {
+	s.Data = data
+	s.Dict["Width"] = w
+	s.Dict["Height"] = h
+	s.Dict["Filter"] = Name("FlateDecode")
+	if jpg {
+		s.Dict["Filter"] = Name("DCTDecode")
+	}
+	delete(s.Dict, "DecodeParms")
+}
@@ -0,0 +1,136 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	const size = 600
+	pixels := make([]byte, size*size*3)
+	for i := range pixels {
+		pixels[i] = byte(i / 3 % size)
+	}
+	flateImage := func() *Stream {
+		return NewStream(Dict{
+			"Type":             Name("XObject"),
+			"Subtype":          Name("Image"),
+			"Width":            size,
+			"Height":           size,
+			"ColorSpace":       Name("DeviceRGB"),
+			"BitsPerComponent": 8,
+		}, pixels)
+	}
+	gray := image.NewGray(image.Rect(0, 0, size, size))
+	for i := range gray.Pix {
+		gray.Pix[i] = byte(i % 251)
+	}
+	gray.Set(0, 0, color.Gray{Y: 0})
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, gray, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := New()
+	page := d.Add(Dict{
+		"Type":     Name("Page"),
+		"MediaBox": Array{0, 0, 595, 842},
+		"Resources": Dict{"XObject": Dict{
+			"Im1": d.Add(flateImage()),
+			"Im2": d.Add(flateImage()),
+			"Im3": d.Add(&Stream{Dict: Dict{
+				"Type":             Name("XObject"),
+				"Subtype":          Name("Image"),
+				"Width":            size,
+				"Height":           size,
+				"ColorSpace":       Name("DeviceGray"),
+				"BitsPerComponent": 8,
+				"Filter":           Name("DCTDecode"),
+			}, Data: jpg.Bytes()}),
+		}},
+		// 100pt wide images of 600 pixels are drawn at 432 dpi; Im2, a
+		// copy of Im1, is drawn at 216 dpi.
+		"Contents": d.Add(&Stream{Dict: Dict{}, Data: []byte(
+			"q 100 0 0 100 0 0 cm /Im1 Do Q\n" +
+				"q 200 0 0 200 100 0 cm /Im2 Do Q\n" +
+				"q 100 0 0 100 0 300 cm /Im3 Do Q\n" +
+				"BI /W 1 /H 1 /CS /G /BPC 8 ID \x00 EI\n" +
+				"BT /F1 12 Tf 72 720 Td (Optimized page with some text to compress) Tj ET\n" +
+				"BT /F1 12 Tf 72 700 Td (Optimized page with some text to compress) Tj ET\n")}),
+	})
+	if err := d.SetPages([]Ref{page}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := d.Optimize(OptimizeOptions{MaxImageDPI: 144})
+	if stats.ImagesDownsampled != 2 {
+		t.Errorf("downsampled %d images, want 2", stats.ImagesDownsampled)
+	}
+	if stats.DuplicatesRemoved != 1 {
+		t.Errorf("removed %d duplicates, want 1", stats.DuplicatesRemoved)
+	}
+	if stats.StreamsRecompressed == 0 {
+		t.Error("no stream recompressed")
+	}
+
+	xo := d.Dict(d.Dict(page)["Resources"])["XObject"].(Dict)
+	if xo["Im1"] != xo["Im2"] {
+		t.Error("identical images not merged")
+	}
+	// The merged image keeps the resolution needed at its largest size.
+	wantWidth := map[Name]int{"Im1": 400, "Im3": 200}
+	for name, want := range wantWidth {
+		s := d.Resolve(xo[name]).(*Stream)
+		if got := s.Dict["Width"]; got != want {
+			t.Errorf("%s: width %v, want %d", name, got, want)
+		}
+	}
+	im3 := d.Resolve(xo["Im3"]).(*Stream)
+	if im3.Dict["Filter"] != Name("DCTDecode") {
+		t.Errorf("JPEG image re-encoded as %v", im3.Dict["Filter"])
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(im3.Data)); err != nil {
+		t.Errorf("invalid JPEG: %v", err)
+	}
+	data, err := d.Resolve(xo["Im1"]).(*Stream).Decode()
+	if err != nil || len(data) != 400*400*3 {
+		t.Errorf("Im1: %d bytes (%v), want %d", len(data), err, 400*400*3)
+	}
+}
+
+func TestOptimizeDedupe(t *testing.T) {
+	d := New()
+	font := func() Ref {
+		file := d.Add(NewStream(nil, bytes.Repeat([]byte("glyphs"), 100)))
+		desc := d.Add(Dict{"Type": Name("FontDescriptor"), "FontName": Name("Sarabun"), "FontFile2": file})
+		return d.Add(Dict{"Type": Name("Font"), "Subtype": Name("TrueType"), "BaseFont": Name("Sarabun"), "FontDescriptor": desc})
+	}
+	var pages []Ref
+	for i := 0; i < 2; i++ {
+		pages = append(pages, d.Add(Dict{
+			"Type":      Name("Page"),
+			"MediaBox":  Array{0, 0, 595, 842},
+			"Resources": Dict{"Font": Dict{"F1": font()}},
+			"Contents":  d.Add(NewStream(nil, []byte("BT /F1 12 Tf (x) Tj ET"))),
+		}))
+	}
+	if err := d.SetPages(pages); err != nil {
+		t.Fatal(err)
+	}
+
+	// Font file, descriptor, font and the content stream.
+	if got := d.Optimize(OptimizeOptions{}).DuplicatesRemoved; got != 4 {
+		t.Errorf("removed %d duplicates, want 4", got)
+	}
+	f1 := d.Dict(d.Dict(pages[0])["Resources"])["Font"].(Dict)["F1"]
+	f2 := d.Dict(d.Dict(pages[1])["Resources"])["Font"].(Dict)["F1"]
+	if f1 != f2 {
+		t.Errorf("pages use different fonts %v and %v", f1, f2)
+	}
+	if _, err := d.Bytes(); err != nil {
+		t.Fatal(err)
+	}
+}
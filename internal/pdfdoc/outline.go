@@ -0,0 +1,147 @@
+package pdfdoc
+
+// OutlineItem is an entry of the document outline (bookmarks).
+type OutlineItem struct {
+	Title string
+	// Level is the nesting level, starting at 1.
+	Level int
+	// Dest is where the bookmark points to.
+	Dest Destination
+}
+
+// HasOutline reports whether the document already has bookmarks.
+func (d *Document) HasOutline() bool {
+	cat := d.Catalog()
+	if cat == nil {
+		return false
+	}
+	outlines := d.Dict(cat["Outlines"])
+	return outlines != nil && outlines["First"] != nil
+}
+
+// SetOutline replaces the document outline with items. Items are nested
+// by level; a jump of more than one level is attached to the nearest
+// shallower item.
+func (d *Document) SetOutline(items []OutlineItem) {
+	cat := d.Catalog()
+	if cat == nil {
+		return
+	}
+	if len(items) == 0 {
+		delete(cat, "Outlines")
+		return
+	}
+	pages := d.Pages()
+
+	type node struct {
+		ref      Ref
+		dict     Dict
+		level    int
+		children []*node
+	}
+	rootDict := Dict{"Type": Name("Outlines")}
+	root := &node{ref: d.Add(rootDict), dict: rootDict}
+	stack := []*node{root}
+
+	for _, it := range items {
+		if it.Dest.Page < 0 || it.Dest.Page >= len(pages) {
+			continue
+		}
+		level := it.Level
+		if level < 1 {
+			level = 1
+		}
+		for len(stack) > 1 && stack[len(stack)-1].level >= level {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+		dict := Dict{
+			"Title":  TextString(it.Title),
+			"Parent": parent.ref,
+			"Dest":   Array{pages[it.Dest.Page], Name("XYZ"), nil, it.Dest.Y, nil},
+		}
+		n := &node{ref: d.Add(dict), dict: dict, level: level}
+		parent.children = append(parent.children, n)
+		stack = append(stack, n)
+	}
+
+	// Link siblings and compute the visible descendant counts.
+	var link func(n *node) int
+	link = func(n *node) int {
+		total := 0
+		for i, c := range n.children {
+			if i > 0 {
+				c.dict["Prev"] = n.children[i-1].ref
+			}
+			if i+1 < len(n.children) {
+				c.dict["Next"] = n.children[i+1].ref
+			}
+			total += 1 + link(c)
+		}
+		if len(n.children) > 0 {
+			n.dict["First"] = n.children[0].ref
+			n.dict["Last"] = n.children[len(n.children)-1].ref
+			n.dict["Count"] = total
+		}
+		return total
+	}
+	link(root)
+
+	cat["Outlines"] = root.ref
+	cat["PageMode"] = Name("UseOutlines")
+}
+
+// Outline returns the bookmarks of the document in document order.
+// Bookmarks whose target cannot be resolved are skipped.
+func (d *Document) Outline() []OutlineItem {
+	cat := d.Catalog()
+	if cat == nil {
+		return nil
+	}
+	index := d.pageIndex()
+	named := d.NamedDestinations()
+
+	var items []OutlineItem
+	seen := map[int]bool{}
+	var walk func(o Object, level int)
+	walk = func(o Object, level int) {
+		for o != nil && level <= 32 {
+			ref, ok := o.(Ref)
+			if !ok || seen[ref.Num] {
+				return
+			}
+			seen[ref.Num] = true
+			item := d.Dict(ref)
+			if item == nil {
+				return
+			}
+			target := d.Resolve(item["Dest"])
+			if target == nil {
+				if action := d.Dict(item["A"]); action != nil && action["S"] == Name("GoTo") {
+					target = d.Resolve(action["D"])
+				}
+			}
+			var dest Destination
+			found := false
+			switch t := target.(type) {
+			case Array:
+				dest, found = d.explicitDest(t, index)
+			case Name:
+				dest, found = named[string(t)]
+			case String, HexString:
+				b, _ := StringBytes(t)
+				dest, found = named[string(b)]
+			}
+			if found {
+				title, _ := StringBytes(d.Resolve(item["Title"]))
+				items = append(items, OutlineItem{Title: DecodeTextString(title), Level: level, Dest: dest})
+			}
+			walk(item["First"], level+1)
+			o = item["Next"]
+		}
+	}
+	if outlines := d.Dict(cat["Outlines"]); outlines != nil {
+		walk(outlines["First"], 1)
+	}
+	return items
+}
@@ -0,0 +1,164 @@
+package pdfdoc
+
+import "fmt"
+
+// KeepPages makes pages, in the given order, the only pages of the
+// document. Bookmarks, named destinations, links and form fields that
+// point to the other pages are removed. If pages are dropped, the logical
+// structure of a tagged document is removed as well, since it cannot be
+// split along page boundaries.
+func (d *Document) KeepPages(pages []Ref) error {
+	old := d.Pages()
+	outline := d.Outline()
+	hadOutline := d.HasOutline()
+	if err := d.SetPages(pages); err != nil {
+		return err
+	}
+
+	kept := make(map[int]int, len(pages))
+	for i, p := range pages {
+		kept[p.Num] = i
+	}
+	cat := d.Catalog()
+
+	if hadOutline {
+		var items []OutlineItem
+		for _, it := range outline {
+			if i, ok := kept[old[it.Dest.Page].Num]; ok {
+				it.Dest.Page = i
+				items = append(items, it)
+			}
+		}
+		d.SetOutline(items)
+		if len(items) == 0 {
+			delete(cat, "PageMode")
+		}
+	}
+
+	// An explicit destination is an array whose first element is the
+	// target page; named destinations are resolved through the catalog.
+	targetKept := func(v Object) bool {
+		v = d.Resolve(v)
+		if dict, ok := v.(Dict); ok {
+			v = d.Resolve(dict["D"])
+		}
+		arr, ok := v.(Array)
+		if !ok || len(arr) == 0 {
+			return true
+		}
+		ref, ok := arr[0].(Ref)
+		if !ok {
+			return true
+		}
+		_, ok = kept[ref.Num]
+		return ok
+	}
+
+	if dests := d.Dict(cat["Dests"]); dests != nil {
+		for k, v := range dests {
+			if !targetKept(v) {
+				delete(dests, k)
+			}
+		}
+	}
+	if names := d.Dict(cat["Names"]); names != nil {
+		d.filterNameTree(names["Dests"], func(_ []byte, v Object) bool {
+			return targetKept(v)
+		})
+	}
+	if !targetKept(cat["OpenAction"]) {
+		delete(cat, "OpenAction")
+	}
+
+	named := d.NamedDestinations()
+	for _, p := range pages {
+		page := d.Dict(p)
+		annots := d.Array(page["Annots"])
+		if annots == nil {
+			continue
+		}
+		out := Array{}
+		for _, a := range annots {
+			annot := d.Dict(a)
+			if annot != nil && annot["Subtype"] == Name("Link") {
+				target := d.Resolve(annot["Dest"])
+				if action := d.Dict(annot["A"]); target == nil && action != nil && action["S"] == Name("GoTo") {
+					target = d.Resolve(action["D"])
+				}
+				switch t := target.(type) {
+				case Array:
+					if !targetKept(t) {
+						continue
+					}
+				case Name:
+					if _, ok := named[string(t)]; !ok {
+						continue
+					}
+				case String, HexString:
+					key, _ := StringBytes(t)
+					if _, ok := named[string(key)]; !ok {
+						continue
+					}
+				}
+			}
+			out = append(out, a)
+		}
+		page["Annots"] = out
+	}
+
+	if form := d.Dict(cat["AcroForm"]); form != nil {
+		var fields Array
+		for _, f := range d.Array(form["Fields"]) {
+			if d.fieldOnPages(f, kept) {
+				fields = append(fields, f)
+			}
+		}
+		form["Fields"] = fields
+	}
+
+	if len(pages) < len(old) {
+		delete(cat, "StructTreeRoot")
+		delete(cat, "MarkInfo")
+		for _, p := range pages {
+			delete(d.Dict(p), "StructParents")
+		}
+	}
+	return nil
+}
+
+// fieldOnPages reports whether a form field has a widget on one of the
+// given pages. Fields whose widgets do not name their page are kept.
+func (d *Document) fieldOnPages(field Object, pages map[int]int) bool {
+	f := d.Dict(field)
+	if f == nil {
+		return false
+	}
+	if kids := d.Array(f["Kids"]); len(kids) > 0 {
+		for _, k := range kids {
+			if d.fieldOnPages(k, pages) {
+				return true
+			}
+		}
+		return false
+	}
+	page, ok := f["P"].(Ref)
+	if !ok {
+		return true
+	}
+	_, ok = pages[page.Num]
+	return ok
+}
+
+// RotatePage turns a page clockwise by degrees, a multiple of 90.
+func (d *Document) RotatePage(page Ref, degrees int) error {
+	if degrees%90 != 0 {
+		return fmt.Errorf("pdf: rotation must be a multiple of 90 degrees, got %d", degrees)
+	}
+	p := d.Dict(page)
+	if p == nil {
+		return fmt.Errorf("pdf: page object %d not found", page.Num)
+	}
+	current, _ := Number(d.Resolve(d.PageAttr(page, "Rotate")))
+	p["Rotate"] = ((int(current)+degrees)%360 + 360) % 360
+	return nil
+}
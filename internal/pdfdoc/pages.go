@@ -0,0 +1,166 @@
+package pdfdoc
+
+import "fmt"
+
+// inheritable lists the page attributes that may be inherited from
+// ancestor /Pages nodes.
+var inheritable = []Name{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// Pages returns the page objects in document order.
+func (d *Document) Pages() []Ref {
+	var pages []Ref
+	seen := map[int]bool{}
+	var walk func(o Object)
+	walk = func(o Object) {
+		ref, ok := o.(Ref)
+		if !ok || seen[ref.Num] {
+			return
+		}
+		seen[ref.Num] = true
+		node := d.Dict(ref)
+		if node == nil {
+			return
+		}
+		if node["Type"] == Name("Page") || (node["Kids"] == nil && node["Type"] != Name("Pages")) {
+			pages = append(pages, ref)
+			return
+		}
+		for _, kid := range d.Array(node["Kids"]) {
+			walk(kid)
+		}
+	}
+	if cat := d.Catalog(); cat != nil {
+		walk(cat["Pages"])
+	}
+	return pages
+}
+
+// PageAttr returns the attribute key of page, following inheritance
+// through the page tree.
+func (d *Document) PageAttr(page Ref, key Name) Object {
+	node := d.Dict(page)
+	for i := 0; node != nil && i < 64; i++ {
+		if v, ok := node[key]; ok {
+			return v
+		}
+		node = d.Dict(node["Parent"])
+	}
+	return nil
+}
+
+// PageBox returns the rectangle of the given page box (e.g. /MediaBox)
+// as llx, lly, urx, ury. It defaults to US Letter if the box is missing.
+func (d *Document) PageBox(page Ref, box Name) [4]float64 {
+	r := [4]float64{0, 0, 612, 792}
+	arr := d.Array(d.PageAttr(page, box))
+	if len(arr) != 4 && box != "MediaBox" {
+		arr = d.Array(d.PageAttr(page, "MediaBox"))
+	}
+	if len(arr) == 4 {
+		for i := range r {
+			r[i], _ = Number(d.Resolve(arr[i]))
+		}
+	}
+	return r
+}
+
+// SetPages replaces the page tree with a flat one holding pages in the
+// given order. Inherited attributes are copied onto each page first, so
+// pages keep their appearance. A page may not be listed twice.
+func (d *Document) SetPages(pages []Ref) error {
+	cat := d.Catalog()
+	if cat == nil {
+		return fmt.Errorf("pdf: document catalog not found")
+	}
+
+	seen := map[int]bool{}
+	kids := make(Array, 0, len(pages))
+	for _, p := range pages {
+		if seen[p.Num] {
+			return fmt.Errorf("pdf: page object %d listed twice", p.Num)
+		}
+		seen[p.Num] = true
+		page := d.Dict(p)
+		if page == nil {
+			return fmt.Errorf("pdf: page object %d not found", p.Num)
+		}
+		for _, k := range inheritable {
+			if _, ok := page[k]; ok {
+				continue
+			}
+			if v := d.PageAttr(p, k); v != nil {
+				page[k] = v
+			}
+		}
+		kids = append(kids, p)
+	}
+
+	root := d.Add(Dict{"Type": Name("Pages"), "Kids": kids, "Count": len(kids)})
+	for _, p := range pages {
+		d.Dict(p)["Parent"] = root
+	}
+	cat["Pages"] = root
+	return nil
+}
+
+// AppendContent adds a content stream to the end of a page's contents.
+func (d *Document) AppendContent(page Ref, content *Stream) {
+	d.addContent(page, content, false)
+}
+
+// PrependContent adds a content stream in front of a page's contents.
+func (d *Document) PrependContent(page Ref, content *Stream) {
+	d.addContent(page, content, true)
+}
+
+func (d *Document) addContent(page Ref, content *Stream, front bool) {
+	p := d.Dict(page)
+	ref := d.Add(content)
+	var existing Array
+	switch c := p["Contents"].(type) {
+	case Ref:
+		if arr, ok := d.Resolve(c).(Array); ok {
+			existing = arr
+		} else {
+			existing = Array{c}
+		}
+	case Array:
+		existing = c
+	}
+	if front {
+		p["Contents"] = append(Array{ref}, existing...)
+	} else {
+		p["Contents"] = append(append(Array{}, existing...), ref)
+	}
+}
+
+// PageResources returns the resource dictionary of a page, copying an
+// inherited or shared one onto the page so it can be modified safely.
+func (d *Document) PageResources(page Ref) Dict {
+	p := d.Dict(page)
+	res, _ := Clone(d.Resolve(d.PageAttr(page, "Resources"))).(Dict)
+	if res == nil {
+		res = Dict{}
+	}
+	p["Resources"] = res
+	return res
+}
+
+// AddResource registers o under a fresh name in the given resource
+// category (e.g. /XObject) and returns the name.
+func (d *Document) AddResource(res Dict, category Name, prefix string, o Object) Name {
+	cat := d.Dict(res[category])
+	if cat == nil {
+		cat = Dict{}
+	} else {
+		cat = Clone(cat).(Dict)
+	}
+	res[category] = cat
+	for i := 1; ; i++ {
+		name := Name(fmt.Sprintf("%s%d", prefix, i))
+		if _, taken := cat[name]; !taken {
+			cat[name] = o
+			return name
+		}
+	}
+}
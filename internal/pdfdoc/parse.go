@@ -0,0 +1,444 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// parser is a tokenizer and object parser over a PDF byte slice.
+type parser struct {
+	data []byte
+	pos  int
+
+	// resolveLength resolves an indirect /Length of a stream. It may be nil.
+	resolveLength func(Ref) (int, bool)
+}
+
+func isWhite(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isDelim(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("pdf: offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips whitespace and comments.
+func (p *parser) skipSpace() {
+	for !p.eof() {
+		c := p.data[p.pos]
+		if isWhite(c) {
+			p.pos++
+			continue
+		}
+		if c == '%' {
+			for !p.eof() && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+// keyword reads a run of regular characters.
+func (p *parser) keyword() string {
+	start := p.pos
+	for !p.eof() && !isWhite(p.data[p.pos]) && !isDelim(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+// peekKeyword reports whether the next token is kw, without consuming it.
+func (p *parser) peekKeyword(kw string) bool {
+	save := p.pos
+	p.skipSpace()
+	ok := p.keyword() == kw
+	p.pos = save
+	return ok
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	p.skipSpace()
+	if got := p.keyword(); got != kw {
+		return p.errorf("expected %q, got %q", kw, got)
+	}
+	return nil
+}
+
+// readInt reads an unsigned integer token.
+func (p *parser) readInt() (int, bool) {
+	p.skipSpace()
+	start := p.pos
+	for !p.eof() && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(p.data[start:p.pos]))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// parseObject parses the next direct object (or indirect reference).
+func (p *parser) parseObject() (Object, error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf("unexpected end of data")
+	}
+
+	c := p.data[p.pos]
+	switch {
+	case c == '/':
+		return p.parseName(), nil
+	case c == '(':
+		return p.parseLiteralString()
+	case c == '<':
+		if p.pos+1 < len(p.data) && p.data[p.pos+1] == '<' {
+			return p.parseDictOrStream()
+		}
+		return p.parseHexString()
+	case c == '[':
+		return p.parseArray()
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return p.parseNumberOrRef()
+	}
+
+	kw := p.keyword()
+	switch kw {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "":
+		p.pos++
+		return nil, p.errorf("unexpected character %q", c)
+	}
+	return nil, p.errorf("unexpected keyword %q", kw)
+}
+
+func (p *parser) parseName() Name {
+	p.pos++ // '/'
+	var b []byte
+	for !p.eof() {
+		c := p.data[p.pos]
+		if isWhite(c) || isDelim(c) {
+			break
+		}
+		if c == '#' && p.pos+2 < len(p.data) {
+			if v, err := strconv.ParseUint(string(p.data[p.pos+1:p.pos+3]), 16, 8); err == nil {
+				b = append(b, byte(v))
+				p.pos += 3
+				continue
+			}
+		}
+		b = append(b, c)
+		p.pos++
+	}
+	return Name(b)
+}
+
+func (p *parser) parseLiteralString() (Object, error) {
+	p.pos++ // '('
+	var b []byte
+	depth := 1
+	for !p.eof() {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return String(b), nil
+			}
+		case '\\':
+			if p.eof() {
+				continue
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				b = append(b, '\n')
+			case 'r':
+				b = append(b, '\r')
+			case 't':
+				b = append(b, '\t')
+			case 'b':
+				b = append(b, '\b')
+			case 'f':
+				b = append(b, '\f')
+			case '\r':
+				if !p.eof() && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+			case '\n':
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				v := int(e - '0')
+				for i := 0; i < 2 && !p.eof() && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+					v = v*8 + int(p.data[p.pos]-'0')
+					p.pos++
+				}
+				b = append(b, byte(v))
+			default:
+				b = append(b, e)
+			}
+			continue
+		}
+		b = append(b, c)
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+func (p *parser) parseHexString() (Object, error) {
+	p.pos++ // '<'
+	var b []byte
+	var hi byte
+	half := false
+	for !p.eof() {
+		c := p.data[p.pos]
+		p.pos++
+		if c == '>' {
+			if half {
+				b = append(b, hi<<4)
+			}
+			return HexString(b), nil
+		}
+		if isWhite(c) {
+			continue
+		}
+		v, ok := unhex(c)
+		if !ok {
+			return nil, p.errorf("invalid hex digit %q", c)
+		}
+		if half {
+			b = append(b, hi<<4|v)
+		} else {
+			hi = v
+		}
+		half = !half
+	}
+	return nil, p.errorf("unterminated hex string")
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+func (p *parser) parseArray() (Object, error) {
+	p.pos++ // '['
+	arr := Array{}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.data[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+		o, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, o)
+	}
+}
+
+func (p *parser) parseDict() (Dict, error) {
+	p.pos += 2 // '<<'
+	d := Dict{}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorf("unterminated dictionary")
+		}
+		if p.data[p.pos] == '>' {
+			if p.pos+1 < len(p.data) && p.data[p.pos+1] == '>' {
+				p.pos += 2
+				return d, nil
+			}
+			return nil, p.errorf("unexpected '>' in dictionary")
+		}
+		if p.data[p.pos] != '/' {
+			return nil, p.errorf("expected name key in dictionary")
+		}
+		key := p.parseName()
+		val, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		if val != nil {
+			d[key] = val
+		}
+	}
+}
+
+func (p *parser) parseDictOrStream() (Object, error) {
+	d, err := p.parseDict()
+	if err != nil {
+		return nil, err
+	}
+	if !p.peekKeyword("stream") {
+		return d, nil
+	}
+	p.skipSpace()
+	p.pos += len("stream")
+	// The keyword is followed by CRLF or LF (a lone CR is tolerated).
+	if !p.eof() && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if !p.eof() && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+
+	length := -1
+	switch v := d["Length"].(type) {
+	case int:
+		length = v
+	case Ref:
+		if p.resolveLength != nil {
+			if n, ok := p.resolveLength(v); ok {
+				length = n
+			}
+		}
+	}
+
+	end := -1
+	if length >= 0 && start+length <= len(p.data) {
+		q := &parser{data: p.data, pos: start + length}
+		if q.peekKeyword("endstream") {
+			end = start + length
+		}
+	}
+	if end < 0 {
+		// Recover from a missing or wrong /Length by searching for endstream.
+		i := bytes.Index(p.data[start:], []byte("endstream"))
+		if i < 0 {
+			return nil, p.errorf("stream without endstream")
+		}
+		end = start + i
+		for end > start && (p.data[end-1] == '\n' || p.data[end-1] == '\r') {
+			end--
+		}
+	}
+
+	p.pos = end
+	if err := p.expectKeyword("endstream"); err != nil {
+		return nil, err
+	}
+	data := make([]byte, end-start)
+	copy(data, p.data[start:end])
+	return &Stream{Dict: d, Data: data}, nil
+}
+
+func (p *parser) parseNumberOrRef() (Object, error) {
+	start := p.pos
+	if p.data[p.pos] == '+' || p.data[p.pos] == '-' {
+		p.pos++
+	}
+	isReal := false
+	for !p.eof() {
+		c := p.data[p.pos]
+		if c == '.' {
+			isReal = true
+		} else if c < '0' || c > '9' {
+			break
+		}
+		p.pos++
+	}
+	tok := string(p.data[start:p.pos])
+
+	if isReal {
+		f, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			if tok == "-" || tok == "." || tok == "-." {
+				return 0.0, nil
+			}
+			return nil, p.errorf("invalid number %q", tok)
+		}
+		return f, nil
+	}
+	n, err := strconv.Atoi(tok)
+	if err != nil {
+		if tok == "-" || tok == "+" {
+			return 0, nil
+		}
+		return nil, p.errorf("invalid number %q", tok)
+	}
+
+	// Look ahead for "gen R".
+	if n >= 0 && tok[0] != '+' && tok[0] != '-' {
+		save := p.pos
+		if gen, ok := p.readInt(); ok {
+			p.skipSpace()
+			if !p.eof() && p.data[p.pos] == 'R' && (p.pos+1 == len(p.data) || isWhite(p.data[p.pos+1]) || isDelim(p.data[p.pos+1])) {
+				p.pos++
+				return Ref{Num: n, Gen: gen}, nil
+			}
+		}
+		p.pos = save
+	}
+	return n, nil
+}
+
+// parseIndirect parses "num gen obj ... endobj" at the current position.
+func (p *parser) parseIndirect() (int, Object, error) {
+	num, ok := p.readInt()
+	if !ok {
+		return 0, nil, p.errorf("expected object number")
+	}
+	if _, ok := p.readInt(); !ok {
+		return 0, nil, p.errorf("expected generation number")
+	}
+	if err := p.expectKeyword("obj"); err != nil {
+		return 0, nil, err
+	}
+	o, err := p.parseObject()
+	if err != nil {
+		return 0, nil, err
+	}
+	// Tolerate a missing endobj.
+	if p.peekKeyword("endobj") {
+		p.skipSpace()
+		p.pos += len("endobj")
+	}
+	return num, o, nil
+}
+
+// ErrEncrypted is returned by Parse for encrypted input documents.
+var ErrEncrypted = errors.New("pdf: encrypted documents are not supported")
@@ -0,0 +1,156 @@
+package pdfdoc
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// ConvertPDFA prepares the document for PDF/A-<part><conformance>
+// (part 2 or 3, conformance "B") archival: it embeds an sRGB output intent
+// and XMP metadata carrying the PDF/A identification, and removes the
+// features PDF/A forbids where that does not change the appearance of the
+// document. It returns the violations that cannot be fixed automatically.
+func (d *Document) ConvertPDFA(part int, conformance string, extra ...XMPPart) []string {
+	var violations []string
+	report := func(format string, args ...any) {
+		violations = append(violations, fmt.Sprintf(format, args...))
+	}
+
+	if d.Trailer["Encrypt"] != nil {
+		report("document is encrypted")
+	}
+
+	cat := d.Catalog()
+	d.Version = "1.7"
+
+	// Active content is not allowed.
+	if names := d.Dict(cat["Names"]); names != nil {
+		delete(names, "JavaScript")
+		if names["EmbeddedFiles"] != nil && part < 3 {
+			report("embedded files require PDF/A-3")
+		}
+	}
+	if action := d.Dict(cat["OpenAction"]); action != nil && action["S"] != Name("GoTo") {
+		delete(cat, "OpenAction")
+	}
+	delete(cat, "AA")
+
+	for i, p := range d.Pages() {
+		page := d.Dict(p)
+		delete(page, "AA")
+		for _, a := range d.Array(page["Annots"]) {
+			annot := d.Dict(a)
+			if annot == nil {
+				continue
+			}
+			subtype, _ := annot["Subtype"].(Name)
+			if action := d.Dict(annot["A"]); action != nil {
+				switch action["S"] {
+				case Name("GoTo"), Name("GoToR"), Name("URI"), Name("Named"), Name("SubmitForm"), Name("Thread"):
+				default:
+					delete(annot, "A")
+				}
+			}
+			delete(annot, "AA")
+			if subtype == "Popup" {
+				continue
+			}
+			// Annotations must be printable and visible.
+			flags, _ := annot["F"].(int)
+			annot["F"] = (flags | 4) &^ (1 | 2 | 32)
+			if subtype != "Link" && annot["AP"] == nil {
+				report("page %d: %s annotation has no appearance stream", i+1, subtype)
+			}
+		}
+	}
+
+	d.checkPDFAResources(report)
+
+	cat["OutputIntents"] = Array{Dict{
+		"Type":                      Name("OutputIntent"),
+		"S":                         Name("GTS_PDFA1"),
+		"OutputConditionIdentifier": String("sRGB IEC61966-2.1"),
+		"Info":                      String("sRGB IEC61966-2.1"),
+		"DestOutputProfile":         d.Add(NewStream(Dict{"N": 3}, SRGBProfile())),
+	}}
+
+	id := XMPPart{
+		Namespace: "http://www.aiim.org/pdfa/ns/id/",
+		Prefix:    "pdfaid",
+		Raw:       fmt.Sprintf("<pdfaid:part>%d</pdfaid:part>\n<pdfaid:conformance>%s</pdfaid:conformance>", part, conformance),
+	}
+	d.SetMetadata(append([]XMPPart{id}, extra...)...)
+
+	sort.Strings(violations)
+	return slices.Compact(violations)
+}
+
+// checkPDFAResources verifies fonts are embedded and strips or reports
+// forbidden resource features.
+func (d *Document) checkPDFAResources(report func(string, ...any)) {
+	checkedFonts := map[string]bool{}
+	checkFont := func(font Dict) {
+		name, _ := font["BaseFont"].(Name)
+		subtype, _ := font["Subtype"].(Name)
+		if subtype == "Type3" {
+			return
+		}
+		desc := font
+		if subtype == "Type0" {
+			desc = d.Dict(d.Array(font["DescendantFonts"]).first())
+			if desc["Subtype"] == Name("CIDFontType2") && desc["CIDToGIDMap"] == nil {
+				report("font %s has no CIDToGIDMap", name)
+			}
+		}
+		fd := d.Dict(desc["FontDescriptor"])
+		if fd != nil && (fd["FontFile"] != nil || fd["FontFile2"] != nil || fd["FontFile3"] != nil) {
+			return
+		}
+		if !checkedFonts[string(name)] {
+			checkedFonts[string(name)] = true
+			report("font %s is not embedded", name)
+		}
+	}
+
+	d.ForEachResources(func(res Dict) {
+		for _, f := range d.Dict(res["Font"]) {
+			if font := d.Dict(f); font != nil {
+				checkFont(font)
+			}
+		}
+		for _, x := range d.Dict(res["XObject"]) {
+			xo, ok := d.Resolve(x).(*Stream)
+			if !ok {
+				continue
+			}
+			delete(xo.Dict, "OPI")
+			if xo.Dict["Subtype"] == Name("Image") {
+				// Interpolation is a rendering hint PDF/A disallows.
+				delete(xo.Dict, "Interpolate")
+				delete(xo.Dict, "Alternates")
+			}
+			for _, f := range xo.Filters() {
+				if f == "LZWDecode" || f == "LZW" {
+					report("XObject uses the LZWDecode filter")
+				}
+			}
+		}
+		for _, g := range d.Dict(res["ExtGState"]) {
+			gs := d.Dict(g)
+			if gs["TR"] != nil {
+				report("graphics state uses a transfer function")
+			}
+			if v, ok := gs["TR2"]; ok && v != Name("Default") {
+				report("graphics state uses a transfer function")
+			}
+		}
+	})
+}
+
+func (a Array) first() Object {
+	if len(a) == 0 {
+		return nil
+	}
+	return a[0]
+}
@@ -0,0 +1,288 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// chromeLike builds a document shaped like Chrome's output: A4 pages and
+// named destinations in the catalog /Dests dictionary.
+func chromeLike(t *testing.T, pages int, dests map[string]Destination) []byte {
+	t.Helper()
+	d := New()
+	var refs []Ref
+	for i := 0; i < pages; i++ {
+		content := NewStream(nil, []byte(fmt.Sprintf("BT /F1 12 Tf 72 720 Td (Page %d) Tj ET", i+1)))
+		refs = append(refs, d.Add(Dict{
+			"Type":     Name("Page"),
+			"MediaBox": Array{0, 0, 594.96, 841.92},
+			"Contents": d.Add(content),
+		}))
+	}
+	if err := d.SetPages(refs); err != nil {
+		t.Fatal(err)
+	}
+	if len(dests) > 0 {
+		dd := Dict{}
+		for name, dest := range dests {
+			dd[Name(name)] = Array{refs[dest.Page], Name("XYZ"), dest.X, dest.Y, 0}
+		}
+		d.Catalog()["Dests"] = d.Add(dd)
+	}
+	b, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := chromeLike(t, 3, nil)
+
+	d, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := len(d.Pages()); got != 3 {
+		t.Fatalf("expected 3 pages, got %d", got)
+	}
+
+	out, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := Parse(out)
+	if err != nil {
+		t.Fatalf("Parse of rewritten document failed: %v", err)
+	}
+	content := d2.Resolve(d2.Dict(d2.Pages()[1])["Contents"]).(*Stream)
+	data, err := content.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("(Page 2)")) {
+		t.Errorf("unexpected content stream %q", data)
+	}
+}
+
+func TestClone(t *testing.T) {
+	d, err := Parse(chromeLike(t, 3, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := d.Clone()
+	if err := c.KeepPages(c.Pages()[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	c.Info()["Title"] = String("Part")
+	if n := len(d.Pages()); n != 3 {
+		t.Errorf("original has %d pages after changing the clone, want 3", n)
+	}
+	if d.Dict(d.Trailer["Info"])["Title"] != nil {
+		t.Error("clone shares the info dictionary")
+	}
+	out, err := c.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(p.Pages()); n != 1 {
+		t.Errorf("clone written with %d pages, want 1", n)
+	}
+}
+
+func TestParseObjects(t *testing.T) {
+	p := &parser{data: []byte(`<< /Name /A#20B /Str (a\(b\)\101) /Hex <48 65 6c6> /Arr [1 -2.5 3 0 R true null] >>`)}
+	o, err := p.parseObject()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := o.(Dict)
+	if d["Name"] != Name("A B") {
+		t.Errorf("name: got %v", d["Name"])
+	}
+	if s := string(d["Str"].(String)); s != "a(b)A" {
+		t.Errorf("string: got %q", s)
+	}
+	if s := string(d["Hex"].(HexString)); s != "Hel`" {
+		t.Errorf("hex string: got %q", s)
+	}
+	arr := d["Arr"].(Array)
+	if arr[0] != 1 || arr[1] != -2.5 || arr[2] != (Ref{Num: 3}) || arr[3] != true || arr[4] != nil {
+		t.Errorf("array: got %#v", arr)
+	}
+}
+
+func TestParseXrefStream(t *testing.T) {
+	// Objects 1 and 2 live in an object stream, indexed by an xref stream.
+	objs := "1 0 2 47 "
+	body := "<</Type/Catalog/Pages 2 0 R>>" + "                  " + "<</Type/Pages/Kids[]/Count 0>>"
+	objStm := NewStream(Dict{"Type": Name("ObjStm"), "N": 2, "First": len(objs)}, []byte(objs+body))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+	stmOffset := buf.Len()
+	w := &writer{}
+	w.writeObject(objStm)
+	fmt.Fprintf(&buf, "3 0 obj\n%s\nendobj\n", w.buf.Bytes())
+
+	xrefOffset := buf.Len()
+	rows := []byte{
+		0, 0, 0,
+		2, 3, 0,
+		2, 3, 1,
+		1, byte(stmOffset), 0,
+		1, byte(xrefOffset), 0,
+	}
+	xref := NewStream(Dict{"Type": Name("XRef"), "W": Array{1, 1, 1}, "Size": 5, "Root": Ref{Num: 1}}, rows)
+	w = &writer{renum: map[int]int{1: 1}}
+	w.writeObject(xref)
+	fmt.Fprintf(&buf, "4 0 obj\n%s\nendobj\nstartxref\n%d\n%%%%EOF\n", w.buf.Bytes(), xrefOffset)
+
+	d, err := Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if d.Catalog()["Type"] != Name("Catalog") {
+		t.Errorf("catalog not loaded from object stream: %#v", d.Catalog())
+	}
+	if d.Dict(d.Catalog()["Pages"])["Type"] != Name("Pages") {
+		t.Error("page tree not loaded from object stream")
+	}
+}
+
+func TestParseBrokenXref(t *testing.T) {
+	src := chromeLike(t, 2, nil)
+	i := bytes.LastIndex(src, []byte("startxref"))
+	broken := append(append([]byte(nil), src[:i]...), []byte("startxref\n99999\n%%EOF\n")...)
+
+	d, err := Parse(broken)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := len(d.Pages()); got != 2 {
+		t.Fatalf("expected 2 pages after reconstruction, got %d", got)
+	}
+}
+
+func TestNamedDestinationsAndOutline(t *testing.T) {
+	src := chromeLike(t, 3, map[string]Destination{
+		"ejspdf-h-1": {Page: 0, X: 28, Y: 800},
+		"ejspdf-h-2": {Page: 2, X: 28, Y: 400},
+		"user-dest":  {Page: 1, X: 0, Y: 100},
+	})
+	d, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dests := d.NamedDestinations()
+	if got := dests["ejspdf-h-2"]; got.Page != 2 || got.Y != 400 {
+		t.Fatalf("unexpected destination %+v", got)
+	}
+
+	d.SetOutline([]OutlineItem{
+		{Title: "บทที่ 1", Level: 1, Dest: dests["ejspdf-h-1"]},
+		{Title: "Section", Level: 2, Dest: dests["ejspdf-h-2"]},
+	})
+	d.RemoveNamedDestinations("ejspdf-")
+
+	out, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err = Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.HasOutline() {
+		t.Fatal("outline missing after rewrite")
+	}
+	outlines := d.Dict(d.Catalog()["Outlines"])
+	if outlines["Count"] != 2 {
+		t.Errorf("expected outline count 2, got %v", outlines["Count"])
+	}
+	first := d.Dict(outlines["First"])
+	title, _ := StringBytes(first["Title"])
+	if DecodeTextString(title) != "บทที่ 1" {
+		t.Errorf("unexpected title %q", DecodeTextString(title))
+	}
+	if child := d.Dict(first["First"]); child == nil {
+		t.Error("level 2 item not nested under level 1 item")
+	}
+
+	remaining := d.NamedDestinations()
+	if _, ok := remaining["ejspdf-h-1"]; ok {
+		t.Error("marker destination was not removed")
+	}
+	if _, ok := remaining["user-dest"]; !ok {
+		t.Error("user destination was removed")
+	}
+}
+
+func TestSetAccessibility(t *testing.T) {
+	d, err := Parse(chromeLike(t, 1, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Catalog()["StructTreeRoot"] = d.Add(Dict{"Type": Name("StructTreeRoot")})
+	d.SetAccessibility("th-TH")
+
+	cat := d.Catalog()
+	if lang, _ := StringBytes(cat["Lang"]); string(lang) != "th-TH" {
+		t.Errorf("unexpected /Lang %q", lang)
+	}
+	if d.Dict(cat["MarkInfo"])["Marked"] != true {
+		t.Error("/MarkInfo /Marked not set on a tagged document")
+	}
+}
+
+func TestConvertPDFA(t *testing.T) {
+	d, err := Parse(chromeLike(t, 1, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Info()["Title"] = TextString("ใบรับรองแพทย์")
+	page := d.Pages()[0]
+	d.PageResources(page)["Font"] = Dict{
+		"F1": d.Add(Dict{"Type": Name("Font"), "Subtype": Name("Type1"), "BaseFont": Name("Helvetica")}),
+	}
+
+	violations := d.ConvertPDFA(2, "B")
+	if len(violations) != 1 || violations[0] != "font Helvetica is not embedded" {
+		t.Errorf("unexpected violations %q", violations)
+	}
+
+	out, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out, []byte("%PDF-1.7")) {
+		t.Error("PDF/A-2 output should be PDF 1.7")
+	}
+	d, err = Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Trailer["ID"] == nil {
+		t.Error("trailer /ID missing")
+	}
+	intent := d.Dict(d.Array(d.Catalog()["OutputIntents"]).first())
+	profile, err := d.Resolve(intent["DestOutputProfile"]).(*Stream).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := int(profile[0])<<24 | int(profile[1])<<16 | int(profile[2])<<8 | int(profile[3]); size != len(profile) {
+		t.Errorf("ICC header size %d does not match profile length %d", size, len(profile))
+	}
+	meta := d.Resolve(d.Catalog()["Metadata"]).(*Stream).Data
+	for _, want := range []string{"<pdfaid:part>2</pdfaid:part>", "ใบรับรองแพทย์"} {
+		if !bytes.Contains(meta, []byte(want)) {
+			t.Errorf("XMP metadata does not contain %q", want)
+		}
+	}
+}
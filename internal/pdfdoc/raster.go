@@ -0,0 +1,466 @@
+package pdfdoc
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// point is a position in device space (pixels, y down).
+type point struct{ x, y float64 }
+
+func (m matrix) apply(x, y float64) point {
+	return point{m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]}
+}
+
+// invert returns the inverse of m, and false if m is singular.
+func (m matrix) invert() (matrix, bool) {
+	det := m[0]*m[3] - m[1]*m[2]
+	if math.Abs(det) < 1e-12 {
+		return matrix{}, false
+	}
+	return matrix{
+		m[3] / det, -m[1] / det,
+		-m[2] / det, m[0] / det,
+		(m[2]*m[5] - m[3]*m[4]) / det, (m[1]*m[4] - m[0]*m[5]) / det,
+	}, true
+}
+
+// scale returns the average factor by which m scales lengths.
+func (m matrix) scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+// subpath is a flattened subpath in device space.
+type subpath struct {
+	pts    []point
+	closed bool
+}
+
+// samples is the number of sub-scanlines per pixel row used for
+// anti-aliasing.
+const samples = 4
+
+// mask is the coverage, from 0 to 1, of each pixel of a rectangle.
+type mask struct {
+	r image.Rectangle
+	a []float32
+}
+
+func (m *mask) at(x, y int) float32 {
+	if !(image.Point{x, y}).In(m.r) {
+		return 0
+	}
+	return m.a[(y-m.r.Min.Y)*m.r.Dx()+x-m.r.Min.X]
+}
+
+// fillMask computes the coverage of polygons within bounds, with the
+// even-odd or the nonzero winding rule. It returns nil if nothing is
+// covered.
+func fillMask(polys []subpath, evenOdd bool, bounds image.Rectangle) *mask {
+	type edge struct {
+		x0, y0, x1, y1 float64
+		dir            int
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	var edges []edge
+	for _, p := range polys {
+		n := len(p.pts)
+		for i := 0; i < n; i++ {
+			a, b := p.pts[i], p.pts[(i+1)%n]
+			minX, maxX = min(minX, a.x), max(maxX, a.x)
+			minY, maxY = min(minY, a.y), max(maxY, a.y)
+			if a.y == b.y {
+				continue
+			}
+			if a.y < b.y {
+				edges = append(edges, edge{a.x, a.y, b.x, b.y, 1})
+			} else {
+				edges = append(edges, edge{b.x, b.y, a.x, a.y, -1})
+			}
+		}
+	}
+	if len(edges) == 0 {
+		return nil
+	}
+	r := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX))+1, int(math.Ceil(maxY))+1).Intersect(bounds)
+	if r.Empty() {
+		return nil
+	}
+
+	// Bucket the edges by the rows they cross.
+	rows := make([][]int, r.Dy())
+	for i, e := range edges {
+		for y := max(int(math.Floor(e.y0)), r.Min.Y); y < r.Max.Y && float64(y) < e.y1; y++ {
+			rows[y-r.Min.Y] = append(rows[y-r.Min.Y], i)
+		}
+	}
+
+	m := &mask{r: r, a: make([]float32, r.Dx()*r.Dy())}
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	var xs []crossing
+	w := r.Dx()
+	for row, list := range rows {
+		acc := m.a[row*w : (row+1)*w]
+		for s := 0; s < samples; s++ {
+			sy := float64(r.Min.Y+row) + (float64(s)+0.5)/samples
+			xs = xs[:0]
+			for _, i := range list {
+				e := edges[i]
+				if sy < e.y0 || sy >= e.y1 {
+					continue
+				}
+				xs = append(xs, crossing{e.x0 + (sy-e.y0)*(e.x1-e.x0)/(e.y1-e.y0), e.dir})
+			}
+			sort.Slice(xs, func(i, j int) bool { return xs[i].x < xs[j].x })
+			winding := 0
+			var start float64
+			for _, c := range xs {
+				was := inside(winding, evenOdd)
+				winding += c.dir
+				if now := inside(winding, evenOdd); now && !was {
+					start = c.x
+				} else if !now && was {
+					addSpan(acc, start-float64(r.Min.X), c.x-float64(r.Min.X))
+				}
+			}
+		}
+	}
+	return m
+}
+
+func inside(winding int, evenOdd bool) bool {
+	if evenOdd {
+		return winding%2 != 0
+	}
+	return winding != 0
+}
+
+// addSpan adds the coverage of one sub-scanline between a and b.
+func addSpan(acc []float32, a, b float64) {
+	a, b = max(a, 0), min(b, float64(len(acc)))
+	if a >= b {
+		return
+	}
+	const k = 1.0 / samples
+	ia, ib := int(a), int(b)
+	if ia == ib {
+		acc[ia] += float32((b - a) * k)
+		return
+	}
+	acc[ia] += float32((float64(ia+1) - a) * k)
+	for i := ia + 1; i < ib; i++ {
+		acc[i] += k
+	}
+	if ib < len(acc) {
+		acc[ib] += float32((b - float64(ib)) * k)
+	}
+}
+
+// clipRegion is the clipping path: a rectangle, optionally narrowed by
+// a coverage mask. Clipping regions are never modified once built.
+type clipRegion struct {
+	r image.Rectangle
+	m *mask
+}
+
+func (c *clipRegion) at(x, y int) float32 {
+	if !(image.Point{x, y}).In(c.r) {
+		return 0
+	}
+	if c.m != nil {
+		return c.m.at(x, y)
+	}
+	return 1
+}
+
+// intersect returns the clipping region narrowed by a path.
+func (c *clipRegion) intersect(path []subpath, evenOdd bool) *clipRegion {
+	if r, ok := axisRect(path); ok {
+		out := &clipRegion{r: c.r.Intersect(r), m: c.m}
+		if out.m != nil {
+			out.r = out.r.Intersect(out.m.r)
+		}
+		return out
+	}
+	m := fillMask(path, evenOdd, c.r)
+	if m == nil {
+		return &clipRegion{}
+	}
+	for y := m.r.Min.Y; y < m.r.Max.Y; y++ {
+		for x := m.r.Min.X; x < m.r.Max.X; x++ {
+			i := (y-m.r.Min.Y)*m.r.Dx() + x - m.r.Min.X
+			m.a[i] = min(m.a[i], 1) * c.at(x, y)
+		}
+	}
+	return &clipRegion{r: m.r, m: m}
+}
+
+// axisRect reports whether path is a single axis-aligned rectangle, and
+// returns it rounded to whole pixels.
+func axisRect(path []subpath) (image.Rectangle, bool) {
+	if len(path) != 1 || len(path[0].pts) != 4 {
+		return image.Rectangle{}, false
+	}
+	p := path[0].pts
+	vertical := p[0].x == p[1].x && p[1].y == p[2].y && p[2].x == p[3].x && p[3].y == p[0].y
+	horizontal := p[0].y == p[1].y && p[1].x == p[2].x && p[2].y == p[3].y && p[3].x == p[0].x
+	if !vertical && !horizontal {
+		return image.Rectangle{}, false
+	}
+	x0, x1 := min(p[0].x, p[2].x), max(p[0].x, p[2].x)
+	y0, y1 := min(p[0].y, p[2].y), max(p[0].y, p[2].y)
+	return image.Rect(int(math.Round(x0)), int(math.Round(y0)), int(math.Round(x1)), int(math.Round(y1))), true
+}
+
+// paint composites a color over img where m covers it, within clip. A
+// nil mask covers the whole clipping region. color returns the straight
+// RGBA color, 0-1, of a pixel.
+func paint(img *image.RGBA, m *mask, clip *clipRegion, alpha float64, color func(x, y int) [4]float64) {
+	r := clip.r.Intersect(img.Rect)
+	if m != nil {
+		r = r.Intersect(m.r)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cov := float64(clip.at(x, y))
+			if m != nil {
+				cov *= float64(min(m.at(x, y), 1))
+			}
+			if cov <= 0 {
+				continue
+			}
+			c := color(x, y)
+			blend(img, x, y, c, cov*alpha*c[3])
+		}
+	}
+}
+
+// blend composites c with opacity a over the pixel at x, y.
+func blend(img *image.RGBA, x, y int, c [4]float64, a float64) {
+	if a <= 0 {
+		return
+	}
+	a = min(a, 1)
+	i := img.PixOffset(x, y)
+	p := img.Pix[i : i+4 : i+4]
+	for k := 0; k < 3; k++ {
+		p[k] = uint8(math.Round(c[k]*255*a + float64(p[k])*(1-a)))
+	}
+	p[3] = uint8(math.Round(255*a + float64(p[3])*(1-a)))
+}
+
+// strokeStyle describes how a path is stroked, in device space.
+type strokeStyle struct {
+	width      float64 // device pixels
+	cap, join  int
+	miterLimit float64
+	dash       []float64 // device pixels
+	phase      float64
+}
+
+// strokePolygons returns polygons covering the stroke of path. They all
+// wind the same way, so that they can be filled together with the
+// nonzero rule.
+func strokePolygons(path []subpath, st strokeStyle) []subpath {
+	hw := max(st.width, 1) / 2
+	var out []subpath
+	add := func(pts ...point) {
+		if signedArea(pts) < 0 {
+			for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+				pts[i], pts[j] = pts[j], pts[i]
+			}
+		}
+		out = append(out, subpath{pts: pts, closed: true})
+	}
+	for _, sp := range dashPath(path, st.dash, st.phase) {
+		pts := dedupPoints(sp.pts)
+		if sp.closed && len(pts) > 2 && pts[0] == pts[len(pts)-1] {
+			pts = pts[:len(pts)-1]
+		}
+		if len(pts) == 1 {
+			if st.cap == 1 {
+				add(circle(pts[0], hw)...)
+			} else if st.cap == 2 {
+				p := pts[0]
+				add(point{p.x - hw, p.y - hw}, point{p.x + hw, p.y - hw}, point{p.x + hw, p.y + hw}, point{p.x - hw, p.y + hw})
+			}
+			continue
+		}
+		n := len(pts)
+		segs := n - 1
+		if sp.closed {
+			segs = n
+		}
+		for i := 0; i < segs; i++ {
+			a, b := pts[i], pts[(i+1)%n]
+			nx, ny := normal(a, b, hw)
+			add(point{a.x + nx, a.y + ny}, point{b.x + nx, b.y + ny}, point{b.x - nx, b.y - ny}, point{a.x - nx, a.y - ny})
+		}
+		// Joins between consecutive segments.
+		first, last := 1, n-2
+		if sp.closed {
+			first, last = 0, n-1
+		}
+		for i := first; i <= last; i++ {
+			join(add, pts[(i-1+n)%n], pts[i], pts[(i+1)%n], hw, st)
+		}
+		if !sp.closed {
+			capEnd(add, pts[1], pts[0], hw, st.cap)
+			capEnd(add, pts[n-2], pts[n-1], hw, st.cap)
+		}
+	}
+	return out
+}
+
+// join adds the join at p between segments a-p and p-b.
+func join(add func(...point), a, p, b point, hw float64, st strokeStyle) {
+	n1x, n1y := normal(a, p, hw)
+	n2x, n2y := normal(p, b, hw)
+	if st.join == 1 {
+		add(circle(p, hw)...)
+		return
+	}
+	// Bevel on both sides; the inner one is hidden by the segments.
+	add(p, point{p.x + n1x, p.y + n1y}, point{p.x + n2x, p.y + n2y})
+	add(p, point{p.x - n1x, p.y - n1y}, point{p.x - n2x, p.y - n2y})
+	if st.join != 0 {
+		return
+	}
+	d1x, d1y := p.x-a.x, p.y-a.y
+	d2x, d2y := b.x-p.x, b.y-p.y
+	cross := d1x*d2y - d1y*d2x
+	if cross == 0 {
+		return
+	}
+	// The outer side is opposite to the direction of the turn.
+	s := 1.0
+	if cross > 0 {
+		s = -1
+	}
+	u1 := point{s * n1x / hw, s * n1y / hw}
+	u2 := point{s * n2x / hw, s * n2y / hw}
+	c := u1.x*u2.x + u1.y*u2.y
+	if c <= -1 || math.Sqrt(2/(1+c)) > max(st.miterLimit, 1) {
+		return
+	}
+	tip := point{p.x + (u1.x+u2.x)*hw/(1+c), p.y + (u1.y+u2.y)*hw/(1+c)}
+	add(p, point{p.x + u1.x*hw, p.y + u1.y*hw}, tip, point{p.x + u2.x*hw, p.y + u2.y*hw})
+}
+
+// capEnd adds the cap at the end b of segment a-b.
+func capEnd(add func(...point), a, b point, hw float64, style int) {
+	switch style {
+	case 1:
+		add(circle(b, hw)...)
+	case 2:
+		nx, ny := normal(a, b, hw)
+		dx, dy := -ny, nx // along the segment, hw long
+		add(point{b.x + nx, b.y + ny}, point{b.x + nx + dx, b.y + ny + dy}, point{b.x - nx + dx, b.y - ny + dy}, point{b.x - nx, b.y - ny})
+	}
+}
+
+// normal returns the left normal of segment a-b, of length hw.
+func normal(a, b point, hw float64) (float64, float64) {
+	dx, dy := b.x-a.x, b.y-a.y
+	l := math.Hypot(dx, dy)
+	if l == 0 {
+		return 0, 0
+	}
+	return -dy / l * hw, dx / l * hw
+}
+
+func circle(c point, r float64) []point {
+	n := int(math.Min(64, math.Max(8, r*2)))
+	pts := make([]point, n)
+	for i := range pts {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		pts[i] = point{c.x + r*math.Cos(a), c.y + r*math.Sin(a)}
+	}
+	return pts
+}
+
+func signedArea(pts []point) float64 {
+	var s float64
+	for i := range pts {
+		a, b := pts[i], pts[(i+1)%len(pts)]
+		s += a.x*b.y - b.x*a.y
+	}
+	return s
+}
+
+func dedupPoints(pts []point) []point {
+	out := pts[:0:0]
+	for _, p := range pts {
+		if len(out) == 0 || p != out[len(out)-1] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// dashPath cuts path into dashes. Closed subpaths become open dashes.
+func dashPath(path []subpath, dash []float64, phase float64) []subpath {
+	total := 0.0
+	for _, d := range dash {
+		total += d
+	}
+	if len(dash) == 0 || total <= 0 {
+		return path
+	}
+	if len(dash)%2 == 1 {
+		dash = append(dash, dash...)
+	}
+	var out []subpath
+	for _, sp := range path {
+		pts := sp.pts
+		if sp.closed && len(pts) > 1 {
+			pts = append(append([]point{}, pts...), pts[0])
+		}
+		i, left := 0, dash[0]
+		for p := math.Mod(phase, total); p > 0; {
+			if p < left {
+				left -= p
+				break
+			}
+			p -= left
+			i = (i + 1) % len(dash)
+			left = dash[i]
+		}
+		on := i%2 == 0
+		var cur []point
+		if on && len(pts) > 0 {
+			cur = []point{pts[0]}
+		}
+		for k := 1; k < len(pts); k++ {
+			a, b := pts[k-1], pts[k]
+			l := math.Hypot(b.x-a.x, b.y-a.y)
+			pos := 0.0
+			for l-pos > left {
+				pos += left
+				q := point{a.x + (b.x-a.x)*pos/l, a.y + (b.y-a.y)*pos/l}
+				if on {
+					out = append(out, subpath{pts: append(cur, q)})
+					cur = nil
+				} else {
+					cur = []point{q}
+				}
+				on = !on
+				i = (i + 1) % len(dash)
+				left = dash[i]
+			}
+			left -= l - pos
+			if on {
+				cur = append(cur, b)
+			}
+		}
+		if on && len(cur) > 1 {
+			out = append(out, subpath{pts: cur})
+		}
+	}
+	return out
+}
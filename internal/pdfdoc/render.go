@@ -0,0 +1,951 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+)
+
+// maxRenderPixels bounds the size of a rendered page.
+const maxRenderPixels = 100_000_000
+
+// RenderPage rasterizes a page at dpi pixels per inch onto a white
+// background, honoring its CropBox and Rotate entries.
+//
+// The renderer covers what browsers print: paths, clipping, embedded
+// TrueType and Type 3 text, images, axial and radial shadings, tiling
+// patterns, form XObjects, constant opacity and annotation appearances.
+// Blend modes and soft masks are ignored, and text in other font formats,
+// such as the CFF programs of OpenType fonts, only advances.
+func (d *Document) RenderPage(page Ref, dpi float64) (*image.RGBA, error) {
+	if dpi <= 0 || math.IsInf(dpi, 0) || math.IsNaN(dpi) {
+		return nil, fmt.Errorf("pdf: invalid resolution %g", dpi)
+	}
+	box := d.PageBox(page, "CropBox")
+	x0, y0 := math.Min(box[0], box[2]), math.Min(box[1], box[3])
+	w, h := math.Abs(box[2]-box[0]), math.Abs(box[3]-box[1])
+	s := dpi / 72
+
+	var base matrix
+	rotate := (intOr(d.Resolve(d.PageAttr(page, "Rotate")), 0)%360 + 360) % 360
+	switch rotate {
+	case 90:
+		base = matrix{0, s, s, 0, 0, 0}
+	case 180:
+		base = matrix{-s, 0, 0, s, w * s, 0}
+	case 270:
+		base = matrix{0, -s, -s, 0, h * s, w * s}
+	default:
+		rotate = 0
+		base = matrix{s, 0, 0, -s, 0, h * s}
+	}
+	base = matrix{1, 0, 0, 1, -x0, -y0}.mul(base)
+
+	pw, ph := w*s, h*s
+	if rotate == 90 || rotate == 270 {
+		pw, ph = ph, pw
+	}
+	iw, ih := int(math.Ceil(pw-1e-6)), int(math.Ceil(ph-1e-6))
+	if iw <= 0 || ih <= 0 || float64(iw)*float64(ih) > maxRenderPixels {
+		return nil, fmt.Errorf("pdf: cannot render a %gx%g pt page at %g dpi", w, h, dpi)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, iw, ih))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	r := &renderer{d: d, img: img, fonts: map[Ref]*renderFont{}, images: map[*Stream]*image.NRGBA{}}
+	gs := newGState(base, img.Rect)
+	res := d.Dict(d.PageAttr(page, "Resources"))
+	r.run(bytes.Join(d.contentStreams(page), []byte("\n")), res, gs, 0)
+
+	for _, a := range d.Array(d.Dict(page)["Annots"]) {
+		r.annotation(d.Dict(a), base)
+	}
+	return img, nil
+}
+
+// renderer draws content streams onto an image.
+type renderer struct {
+	d      *Document
+	img    *image.RGBA
+	fonts  map[Ref]*renderFont
+	images map[*Stream]*image.NRGBA
+}
+
+// gstate is the graphics state, in device space where it has a size.
+type gstate struct {
+	ctm          matrix
+	clip         *clipRegion
+	fill, stroke ink
+	fillAlpha    float64
+	strokeAlpha  float64
+
+	lineWidth  float64
+	lineCap    int
+	lineJoin   int
+	miterLimit float64
+	dash       []float64
+	dashPhase  float64
+
+	font       *renderFont
+	fontSize   float64
+	charSpace  float64
+	wordSpace  float64
+	hscale     float64
+	leading    float64
+	rise       float64
+	renderMode int
+}
+
+// ink is a fill or stroke color, or a pattern.
+type ink struct {
+	cs    *colorSpace
+	comps []float64
+	// pattern is the selected pattern, and base the matrix of the
+	// content stream that selected it.
+	pattern Object
+	base    matrix
+}
+
+func newGState(ctm matrix, bounds image.Rectangle) gstate {
+	black := ink{cs: deviceGray, comps: []float64{0}}
+	return gstate{
+		ctm:         ctm,
+		clip:        &clipRegion{r: bounds},
+		fill:        black,
+		stroke:      black,
+		fillAlpha:   1,
+		strokeAlpha: 1,
+		lineWidth:   1,
+		miterLimit:  10,
+		hscale:      1,
+	}
+}
+
+// run interprets a content stream. Unsupported operators are ignored.
+func (r *renderer) run(content []byte, res Dict, gs gstate, depth int) {
+	if depth > 16 {
+		return
+	}
+	d := r.d
+	base := gs.ctm
+	var stack []gstate
+	var path []subpath
+	var cur, start point
+	clipPending, clipEvenOdd := false, false
+	var tm, tlm matrix
+
+	num := func(args []Object, i int) float64 {
+		if i < len(args) {
+			v, _ := Number(args[i])
+			return v
+		}
+		return 0
+	}
+	nums := func(args []Object) []float64 {
+		out := make([]float64, 0, len(args))
+		for _, a := range args {
+			if v, ok := Number(a); ok {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	moveTo := func(p point) {
+		path = append(path, subpath{pts: []point{p}})
+		cur, start = p, p
+	}
+	lineTo := func(p point) {
+		if len(path) == 0 {
+			moveTo(p)
+			return
+		}
+		sp := &path[len(path)-1]
+		sp.pts = append(sp.pts, p)
+		cur = p
+	}
+	curveTo := func(c1, c2, p point) {
+		if len(path) == 0 {
+			moveTo(cur)
+		}
+		sp := &path[len(path)-1]
+		sp.pts = flattenCubic(sp.pts, cur, c1, c2, p)
+		cur = p
+	}
+	user := func(args []Object, i int) point {
+		return gs.ctm.apply(num(args, i), num(args, i+1))
+	}
+	endPath := func() {
+		if clipPending {
+			gs.clip = gs.clip.intersect(path, clipEvenOdd)
+		}
+		path, clipPending = nil, false
+	}
+	setColor := func(k *ink, args []Object) {
+		if k.cs.pattern && len(args) > 0 {
+			if n, ok := args[len(args)-1].(Name); ok {
+				k.pattern = d.Resolve(d.Dict(res["Pattern"])[n])
+				k.base = base
+				args = args[:len(args)-1]
+			}
+		}
+		k.comps = nums(args)
+	}
+	setSpace := func(k *ink, args []Object) {
+		if len(args) == 0 {
+			return
+		}
+		cs := d.colorSpace(args[0], res, 0)
+		*k = ink{cs: cs, comps: cs.initial}
+	}
+
+	scanContent(content, func(op string, args []Object) {
+		switch op {
+		case "q":
+			stack = append(stack, gs)
+		case "Q":
+			if n := len(stack); n > 0 {
+				gs, stack = stack[n-1], stack[:n-1]
+			}
+		case "cm":
+			if m, ok := toMatrix(args); ok {
+				gs.ctm = m.mul(gs.ctm)
+			}
+		case "w":
+			gs.lineWidth = num(args, 0)
+		case "J":
+			gs.lineCap = int(num(args, 0))
+		case "j":
+			gs.lineJoin = int(num(args, 0))
+		case "M":
+			gs.miterLimit = num(args, 0)
+		case "d":
+			if len(args) == 2 {
+				gs.dash = nums(d.Array(args[0]))
+				gs.dashPhase = num(args, 1)
+			}
+		case "gs":
+			if len(args) == 1 {
+				if n, ok := args[0].(Name); ok {
+					r.extGState(&gs, d.Dict(d.Dict(res["ExtGState"])[n]))
+				}
+			}
+
+		case "m":
+			moveTo(user(args, 0))
+		case "l":
+			lineTo(user(args, 0))
+		case "c":
+			curveTo(user(args, 0), user(args, 2), user(args, 4))
+		case "v":
+			curveTo(cur, user(args, 0), user(args, 2))
+		case "y":
+			p := user(args, 2)
+			curveTo(user(args, 0), p, p)
+		case "h":
+			if len(path) > 0 {
+				path[len(path)-1].closed = true
+				cur = start
+			}
+		case "re":
+			x, y, w, h := num(args, 0), num(args, 1), num(args, 2), num(args, 3)
+			moveTo(gs.ctm.apply(x, y))
+			lineTo(gs.ctm.apply(x+w, y))
+			lineTo(gs.ctm.apply(x+w, y+h))
+			lineTo(gs.ctm.apply(x, y+h))
+			path[len(path)-1].closed = true
+			cur, start = gs.ctm.apply(x, y), gs.ctm.apply(x, y)
+		case "f", "F", "f*":
+			r.fill(&gs, path, op == "f*")
+			endPath()
+		case "S", "s":
+			if op == "s" && len(path) > 0 {
+				path[len(path)-1].closed = true
+			}
+			r.strokePath(&gs, path)
+			endPath()
+		case "B", "B*", "b", "b*":
+			if (op == "b" || op == "b*") && len(path) > 0 {
+				path[len(path)-1].closed = true
+			}
+			r.fill(&gs, path, op == "B*" || op == "b*")
+			r.strokePath(&gs, path)
+			endPath()
+		case "n":
+			endPath()
+		case "W", "W*":
+			clipPending, clipEvenOdd = true, op == "W*"
+
+		case "CS":
+			setSpace(&gs.stroke, args)
+		case "cs":
+			setSpace(&gs.fill, args)
+		case "SC", "SCN":
+			setColor(&gs.stroke, args)
+		case "sc", "scn":
+			setColor(&gs.fill, args)
+		case "G", "g", "RG", "rg", "K", "k":
+			k := &gs.fill
+			if op == "G" || op == "RG" || op == "K" {
+				k = &gs.stroke
+			}
+			*k = ink{cs: deviceGray, comps: nums(args)}
+			switch op {
+			case "RG", "rg":
+				k.cs = deviceRGB
+			case "K", "k":
+				k.cs = deviceCMYK
+			}
+
+		case "sh":
+			if len(args) == 1 {
+				if n, ok := args[0].(Name); ok {
+					if c := d.shading(d.Dict(res["Shading"])[n], gs.ctm); c != nil {
+						paint(r.img, nil, gs.clip, gs.fillAlpha, c)
+					}
+				}
+			}
+		case "Do":
+			if len(args) == 1 {
+				if n, ok := args[0].(Name); ok {
+					r.xObject(gs, d.Dict(res["XObject"])[n], res, depth)
+				}
+			}
+
+		case "BT":
+			tm, tlm = identity, identity
+		case "Tc":
+			gs.charSpace = num(args, 0)
+		case "Tw":
+			gs.wordSpace = num(args, 0)
+		case "Tz":
+			gs.hscale = num(args, 0) / 100
+		case "TL":
+			gs.leading = num(args, 0)
+		case "Ts":
+			gs.rise = num(args, 0)
+		case "Tr":
+			gs.renderMode = int(num(args, 0))
+		case "Tf":
+			if len(args) == 2 {
+				name, _ := args[0].(Name)
+				gs.font = r.font(d.Dict(res["Font"])[name])
+				gs.fontSize = num(args, 1)
+			}
+		case "Td", "TD":
+			if op == "TD" {
+				gs.leading = -num(args, 1)
+			}
+			tlm = matrix{1, 0, 0, 1, num(args, 0), num(args, 1)}.mul(tlm)
+			tm = tlm
+		case "Tm":
+			if m, ok := toMatrix(args); ok {
+				tm, tlm = m, m
+			}
+		case "T*":
+			tlm = matrix{1, 0, 0, 1, 0, -gs.leading}.mul(tlm)
+			tm = tlm
+		case "Tj", "'", "\"":
+			if op == "\"" && len(args) == 3 {
+				gs.wordSpace, gs.charSpace = num(args, 0), num(args, 1)
+				args = args[2:]
+			}
+			if op != "Tj" {
+				tlm = matrix{1, 0, 0, 1, 0, -gs.leading}.mul(tlm)
+				tm = tlm
+			}
+			if len(args) == 1 {
+				s, _ := StringBytes(args[0])
+				r.showText(&gs, &tm, Array{String(s)}, res, depth)
+			}
+		case "TJ":
+			if len(args) == 1 {
+				r.showText(&gs, &tm, d.Array(args[0]), res, depth)
+			}
+		}
+	})
+}
+
+// font returns the font resource o, loading it once.
+func (r *renderer) font(o Object) *renderFont {
+	ref, isRef := o.(Ref)
+	if f, ok := r.fonts[ref]; ok && isRef {
+		return f
+	}
+	f := r.d.loadFont(o)
+	if isRef {
+		r.fonts[ref] = f
+	}
+	return f
+}
+
+// extGState applies the supported entries of a graphics state
+// parameter dictionary.
+func (r *renderer) extGState(gs *gstate, e Dict) {
+	d := r.d
+	for k, v := range e {
+		v = d.Resolve(v)
+		f, isNum := Number(v)
+		switch k {
+		case "LW":
+			if isNum {
+				gs.lineWidth = f
+			}
+		case "LC":
+			gs.lineCap = int(f)
+		case "LJ":
+			gs.lineJoin = int(f)
+		case "ML":
+			if isNum {
+				gs.miterLimit = f
+			}
+		case "CA":
+			if isNum {
+				gs.strokeAlpha = f
+			}
+		case "ca":
+			if isNum {
+				gs.fillAlpha = f
+			}
+		case "D":
+			if arr := d.Array(v); len(arr) == 2 {
+				for _, x := range d.Array(arr[0]) {
+					n, _ := Number(d.Resolve(x))
+					gs.dash = append(gs.dash[:len(gs.dash):len(gs.dash)], n)
+				}
+				gs.dashPhase, _ = Number(d.Resolve(arr[1]))
+			}
+		case "Font":
+			if arr := d.Array(v); len(arr) == 2 {
+				gs.font = r.font(arr[0])
+				gs.fontSize, _ = Number(d.Resolve(arr[1]))
+			}
+		}
+	}
+}
+
+// fill fills path with the fill color.
+func (r *renderer) fill(gs *gstate, path []subpath, evenOdd bool) {
+	m := fillMask(path, evenOdd, gs.clip.r)
+	if m == nil {
+		return
+	}
+	if c := r.color(gs.fill, gs.ctm); c != nil {
+		paint(r.img, m, gs.clip, gs.fillAlpha, c)
+	}
+}
+
+// strokePath strokes path with the stroke color.
+func (r *renderer) strokePath(gs *gstate, path []subpath) {
+	s := gs.ctm.scale()
+	st := strokeStyle{
+		width:      gs.lineWidth * s,
+		cap:        gs.lineCap,
+		join:       gs.lineJoin,
+		miterLimit: gs.miterLimit,
+		phase:      gs.dashPhase * s,
+	}
+	for _, v := range gs.dash {
+		st.dash = append(st.dash, v*s)
+	}
+	m := fillMask(strokePolygons(path, st), false, gs.clip.r)
+	if m == nil {
+		return
+	}
+	if c := r.color(gs.stroke, gs.ctm); c != nil {
+		paint(r.img, m, gs.clip, gs.strokeAlpha, c)
+	}
+}
+
+// color returns the color of each device pixel painted with k, or nil
+// if k cannot be rendered.
+func (r *renderer) color(k ink, ctm matrix) func(x, y int) [4]float64 {
+	if !k.cs.pattern {
+		c := k.cs.color(k.comps)
+		rgba := [4]float64{c[0], c[1], c[2], 1}
+		return func(int, int) [4]float64 { return rgba }
+	}
+	var dict Dict
+	switch p := k.pattern.(type) {
+	case Dict:
+		dict = p
+	case *Stream:
+		dict = p.Dict
+	default:
+		return nil
+	}
+	m, ok := toMatrix(r.d.Array(dict["Matrix"]))
+	if !ok {
+		m = identity
+	}
+	m = m.mul(k.base)
+	if intOr(r.d.Resolve(dict["PatternType"]), 0) == 2 {
+		return r.d.shading(dict["Shading"], m)
+	}
+	s, ok := k.pattern.(*Stream)
+	if !ok {
+		return nil
+	}
+	var uncolored *[3]float64
+	if intOr(r.d.Resolve(dict["PaintType"]), 1) == 2 && k.cs.base != nil {
+		c := k.cs.base.color(k.comps)
+		uncolored = &c
+	}
+	return r.tiling(s, m, uncolored)
+}
+
+// tiling renders one cell of a tiling pattern whose space maps to device
+// space by m, and returns the color of each device pixel it covers. An
+// uncolored pattern is painted in the given color.
+func (r *renderer) tiling(s *Stream, m matrix, uncolored *[3]float64) func(x, y int) [4]float64 {
+	d := r.d
+	inv, ok := m.invert()
+	if !ok {
+		return nil
+	}
+	var bbox [4]float64
+	for i, v := range d.Array(s.Dict["BBox"]) {
+		if i < 4 {
+			bbox[i], _ = Number(d.Resolve(v))
+		}
+	}
+	xs, _ := Number(d.Resolve(s.Dict["XStep"]))
+	ys, _ := Number(d.Resolve(s.Dict["YStep"]))
+	xs, ys = math.Abs(xs), math.Abs(ys)
+	if xs == 0 || ys == 0 {
+		return nil
+	}
+	x0, y0 := math.Min(bbox[0], bbox[2]), math.Min(bbox[1], bbox[3])
+
+	// Render the cell at device resolution, within limits.
+	scale := m.scale()
+	cw, ch := int(math.Ceil(xs*scale)), int(math.Ceil(ys*scale))
+	cw, ch = min(max(cw, 1), 2048), min(max(ch, 1), 2048)
+	sx, sy := float64(cw)/xs, float64(ch)/ys
+	cell := image.NewRGBA(image.Rect(0, 0, cw, ch))
+	cm := matrix{1, 0, 0, 1, -x0, -y0}.mul(matrix{sx, 0, 0, -sy, 0, float64(ch)})
+
+	sub := *r
+	sub.img = cell
+	gs := newGState(cm, cell.Rect)
+	box := []subpath{{pts: []point{cm.apply(bbox[0], bbox[1]), cm.apply(bbox[2], bbox[1]), cm.apply(bbox[2], bbox[3]), cm.apply(bbox[0], bbox[3])}, closed: true}}
+	gs.clip = gs.clip.intersect(box, false)
+	data, err := s.Decode()
+	if err != nil {
+		return nil
+	}
+	sub.run(data, d.Dict(s.Dict["Resources"]), gs, 1)
+
+	return func(x, y int) [4]float64 {
+		p := inv.apply(float64(x)+0.5, float64(y)+0.5)
+		u := math.Mod(math.Mod(p.x-x0, xs)+xs, xs)
+		v := math.Mod(math.Mod(p.y-y0, ys)+ys, ys)
+		px := min(int(u*sx), cw-1)
+		py := min(ch-1-int(v*sy), ch-1)
+		c := cell.RGBAAt(px, max(py, 0))
+		if c.A == 0 {
+			return [4]float64{}
+		}
+		a := float64(c.A) / 255
+		if uncolored != nil {
+			return [4]float64{uncolored[0], uncolored[1], uncolored[2], a}
+		}
+		return [4]float64{float64(c.R) / 255 / a, float64(c.G) / 255 / a, float64(c.B) / 255 / a, a}
+	}
+}
+
+// xObject draws an image or form XObject.
+func (r *renderer) xObject(gs gstate, o Object, res Dict, depth int) {
+	s, ok := r.d.Resolve(o).(*Stream)
+	if !ok {
+		return
+	}
+	switch s.Dict["Subtype"] {
+	case Name("Image"):
+		r.image(&gs, s, res)
+	case Name("Form"):
+		r.form(gs, s, res, depth)
+	}
+}
+
+// form draws a form XObject, with its matrix and bounding box.
+func (r *renderer) form(gs gstate, s *Stream, res Dict, depth int) {
+	d := r.d
+	if m, ok := toMatrix(d.Array(s.Dict["Matrix"])); ok {
+		gs.ctm = m.mul(gs.ctm)
+	}
+	if bbox := d.Array(s.Dict["BBox"]); len(bbox) == 4 {
+		var b [4]float64
+		for i := range b {
+			b[i], _ = Number(d.Resolve(bbox[i]))
+		}
+		c := gs.ctm
+		gs.clip = gs.clip.intersect([]subpath{{pts: []point{c.apply(b[0], b[1]), c.apply(b[2], b[1]), c.apply(b[2], b[3]), c.apply(b[0], b[3])}, closed: true}}, false)
+	}
+	if fres := d.Dict(s.Dict["Resources"]); fres != nil {
+		res = fres
+	}
+	data, err := s.Decode()
+	if err != nil {
+		return
+	}
+	r.run(data, res, gs, depth+1)
+}
+
+// annotation draws the normal appearance of a visible annotation.
+func (r *renderer) annotation(annot Dict, base matrix) {
+	d := r.d
+	if flags := intOr(d.Resolve(annot["F"]), 0); flags&(2|32) != 0 {
+		return
+	}
+	var ap *Stream
+	switch v := d.Resolve(d.Dict(annot["AP"])["N"]).(type) {
+	case *Stream:
+		ap = v
+	case Dict:
+		state, _ := d.Resolve(annot["AS"]).(Name)
+		ap, _ = d.Resolve(v[state]).(*Stream)
+	}
+	if ap == nil {
+		return
+	}
+	rect, bbox := d.Array(annot["Rect"]), d.Array(ap.Dict["BBox"])
+	if len(rect) != 4 || len(bbox) != 4 {
+		return
+	}
+	var rc, bb [4]float64
+	for i := range rc {
+		rc[i], _ = Number(d.Resolve(rect[i]))
+		bb[i], _ = Number(d.Resolve(bbox[i]))
+	}
+	// Map the transformed bounding box onto the annotation rectangle.
+	fm, ok := toMatrix(d.Array(ap.Dict["Matrix"]))
+	if !ok {
+		fm = identity
+	}
+	lo := point{math.Inf(1), math.Inf(1)}
+	hi := point{math.Inf(-1), math.Inf(-1)}
+	for _, p := range []point{fm.apply(bb[0], bb[1]), fm.apply(bb[2], bb[1]), fm.apply(bb[2], bb[3]), fm.apply(bb[0], bb[3])} {
+		lo = point{math.Min(lo.x, p.x), math.Min(lo.y, p.y)}
+		hi = point{math.Max(hi.x, p.x), math.Max(hi.y, p.y)}
+	}
+	if hi.x <= lo.x || hi.y <= lo.y {
+		return
+	}
+	rx0, ry0 := math.Min(rc[0], rc[2]), math.Min(rc[1], rc[3])
+	a := matrix{
+		math.Abs(rc[2]-rc[0]) / (hi.x - lo.x), 0,
+		0, math.Abs(rc[3]-rc[1]) / (hi.y - lo.y),
+		0, 0,
+	}
+	a = matrix{1, 0, 0, 1, -lo.x, -lo.y}.mul(a).mul(matrix{1, 0, 0, 1, rx0, ry0})
+	r.form(newGState(a.mul(base), r.img.Rect), ap, nil, 0)
+}
+
+// showText draws the strings of a TJ array, moving the text matrix.
+func (r *renderer) showText(gs *gstate, tm *matrix, items Array, res Dict, depth int) {
+	f := gs.font
+	if f == nil {
+		return
+	}
+	fs, th := gs.fontSize, gs.hscale
+	visible := gs.renderMode != 3 && gs.renderMode != 7
+	var path []subpath
+	for _, item := range items {
+		if adj, ok := Number(item); ok {
+			*tm = matrix{1, 0, 0, 1, -adj / 1000 * fs * th, 0}.mul(*tm)
+			continue
+		}
+		s, _ := StringBytes(item)
+		for _, code := range f.codes(s) {
+			trm := matrix{fs * th, 0, 0, fs, 0, gs.rise}.mul(*tm).mul(gs.ctm)
+			switch {
+			case !visible:
+			case f.procs != nil:
+				if proc := f.procs[code]; proc != nil {
+					if data, err := proc.Decode(); err == nil {
+						g := *gs
+						g.ctm = f.matrix.mul(trm)
+						pres := f.res
+						if pres == nil {
+							pres = res
+						}
+						r.run(data, pres, g, depth+1)
+					}
+				}
+			case f.glyphs != nil:
+				path = glyphPath(path, f.glyphs.outline(f.gid(code)), f.matrix.mul(trm))
+			}
+			adv := f.width(code)*fs + gs.charSpace
+			if code == 32 && !f.twoByte {
+				adv += gs.wordSpace
+			}
+			*tm = matrix{1, 0, 0, 1, adv * th, 0}.mul(*tm)
+		}
+	}
+	if len(path) == 0 {
+		return
+	}
+	switch gs.renderMode % 4 {
+	case 0:
+		r.fill(gs, path, false)
+	case 1:
+		r.strokePath(gs, path)
+	case 2:
+		r.fill(gs, path, false)
+		r.strokePath(gs, path)
+	}
+}
+
+// glyphPath appends a glyph outline, transformed by m, to path.
+func glyphPath(path []subpath, ops []glyphOp, m matrix) []subpath {
+	var cur point
+	for _, op := range ops {
+		to := m.apply(op.to.x, op.to.y)
+		switch op.op {
+		case 'M':
+			path = append(path, subpath{pts: []point{to}, closed: true})
+		case 'L':
+			if len(path) > 0 {
+				path[len(path)-1].pts = append(path[len(path)-1].pts, to)
+			}
+		case 'Q':
+			if len(path) > 0 {
+				c := m.apply(op.ctrl.x, op.ctrl.y)
+				// Elevate the quadratic curve to a cubic one.
+				c1 := point{cur.x + 2.0/3*(c.x-cur.x), cur.y + 2.0/3*(c.y-cur.y)}
+				c2 := point{to.x + 2.0/3*(c.x-to.x), to.y + 2.0/3*(c.y-to.y)}
+				path[len(path)-1].pts = flattenCubic(path[len(path)-1].pts, cur, c1, c2, to)
+			}
+		}
+		cur = to
+	}
+	return path
+}
+
+// flattenCubic appends the points of a Bézier curve from p0, excluded,
+// to p3, in segments a fraction of a pixel away from the curve.
+func flattenCubic(pts []point, p0, p1, p2, p3 point) []point {
+	l := math.Hypot(p1.x-p0.x, p1.y-p0.y) + math.Hypot(p2.x-p1.x, p2.y-p1.y) + math.Hypot(p3.x-p2.x, p3.y-p2.y)
+	n := min(max(int(math.Ceil(math.Sqrt(l))), 1), 100)
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		a, b, c, e := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		pts = append(pts, point{
+			a*p0.x + b*p1.x + c*p2.x + e*p3.x,
+			a*p0.y + b*p1.y + c*p2.y + e*p3.y,
+		})
+	}
+	return pts
+}
+
+// image draws an image XObject into the unit square of user space.
+func (r *renderer) image(gs *gstate, s *Stream, res Dict) {
+	inv, ok := gs.ctm.invert()
+	if !ok {
+		return
+	}
+	var pix *image.NRGBA
+	if mask, _ := r.d.Resolve(s.Dict["ImageMask"]).(bool); mask {
+		var c [3]float64
+		if !gs.fill.cs.pattern {
+			c = gs.fill.cs.color(gs.fill.comps)
+		}
+		pix = r.d.decodeImage(s, res, &c)
+	} else if pix = r.images[s]; pix == nil {
+		pix = r.d.decodeImage(s, res, nil)
+		r.images[s] = pix
+	}
+	if pix == nil {
+		return
+	}
+	c := gs.ctm
+	quad := []subpath{{pts: []point{c.apply(0, 0), c.apply(1, 0), c.apply(1, 1), c.apply(0, 1)}, closed: true}}
+	m := fillMask(quad, false, gs.clip.r)
+	if m == nil {
+		return
+	}
+	w, h := pix.Rect.Dx(), pix.Rect.Dy()
+	paint(r.img, m, gs.clip, gs.fillAlpha, func(x, y int) [4]float64 {
+		p := inv.apply(float64(x)+0.5, float64(y)+0.5)
+		px := min(max(int(p.x*float64(w)), 0), w-1)
+		py := min(max(int((1-p.y)*float64(h)), 0), h-1)
+		c := pix.NRGBAAt(px, py)
+		return [4]float64{float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255, float64(c.A) / 255}
+	})
+}
+
+// decodeImage decodes an image XObject, with its soft mask or mask. A
+// stencil mask is painted in color paint. It returns nil for images it
+// cannot decode.
+func (d *Document) decodeImage(s *Stream, res Dict, paint *[3]float64) *image.NRGBA {
+	w, h := intOr(d.Resolve(s.Dict["Width"]), 0), intOr(d.Resolve(s.Dict["Height"]), 0)
+	if w <= 0 || h <= 0 || float64(w)*float64(h) > maxRenderPixels {
+		return nil
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	decode := d.Array(s.Dict["Decode"])
+
+	if paint != nil {
+		bits, ok := imageSamples(s, w, h, 1, 1)
+		if !ok {
+			return nil
+		}
+		invert := len(decode) == 2 && intOr(d.Resolve(decode[0]), 0) == 1
+		c := color.NRGBA{uint8(paint[0] * 255), uint8(paint[1] * 255), uint8(paint[2] * 255), 0xff}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if (bits(x, y, 0) == 0) != invert {
+					out.SetNRGBA(x, y, c)
+				}
+			}
+		}
+		return out
+	}
+
+	if f := s.Filters(); len(f) == 1 && (f[0] == "DCTDecode" || f[0] == "DCT") {
+		img, err := jpeg.Decode(bytes.NewReader(s.Data))
+		if err != nil {
+			return nil
+		}
+		b := img.Bounds()
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				c := color.NRGBAModel.Convert(img.At(b.Min.X+x*b.Dx()/w, b.Min.Y+y*b.Dy()/h)).(color.NRGBA)
+				c.A = 0xff
+				out.SetNRGBA(x, y, c)
+			}
+		}
+	} else {
+		cs := d.colorSpace(s.Dict["ColorSpace"], res, 0)
+		bpc := intOr(d.Resolve(s.Dict["BitsPerComponent"]), 8)
+		samples, ok := imageSamples(s, w, h, cs.n, bpc)
+		if !ok {
+			return nil
+		}
+		maxV := float64(int(1)<<bpc - 1)
+		// Decode maps samples to component values, from 0 to 1 or to
+		// the palette index.
+		lo, hi := make([]float64, cs.n), make([]float64, cs.n)
+		for i := range lo {
+			lo[i], hi[i] = 0, 1
+			if cs.indexed {
+				hi[i] = maxV
+			}
+			if 2*i+1 < len(decode) {
+				lo[i], _ = Number(d.Resolve(decode[2*i]))
+				hi[i], _ = Number(d.Resolve(decode[2*i+1]))
+			}
+		}
+		key := d.Array(s.Dict["Mask"])
+		comps := make([]float64, cs.n)
+		raw := make([]int, cs.n)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				for k := range comps {
+					raw[k] = samples(x, y, k)
+					comps[k] = lo[k] + float64(raw[k])*(hi[k]-lo[k])/maxV
+				}
+				c := cs.color(comps)
+				a := uint8(0xff)
+				if len(key) >= 2*cs.n && colorKeyed(d, key, raw) {
+					a = 0
+				}
+				out.SetNRGBA(x, y, color.NRGBA{uint8(math.Round(c[0] * 255)), uint8(math.Round(c[1] * 255)), uint8(math.Round(c[2] * 255)), a})
+			}
+		}
+	}
+
+	// Apply the soft mask or the stencil mask, scaled to the image.
+	var alpha func(x, y int) uint8
+	var mw, mh int
+	if sm, ok := d.Resolve(s.Dict["SMask"]).(*Stream); ok {
+		bpc := intOr(d.Resolve(sm.Dict["BitsPerComponent"]), 8)
+		mw, mh = intOr(d.Resolve(sm.Dict["Width"]), 0), intOr(d.Resolve(sm.Dict["Height"]), 0)
+		if samples, ok := imageSamples(sm, mw, mh, 1, bpc); ok {
+			maxV := float64(int(1)<<bpc - 1)
+			alpha = func(x, y int) uint8 {
+				return uint8(math.Round(float64(samples(x, y, 0)) / maxV * 255))
+			}
+		}
+	} else if sm, ok := d.Resolve(s.Dict["Mask"]).(*Stream); ok {
+		mw, mh = intOr(d.Resolve(sm.Dict["Width"]), 0), intOr(d.Resolve(sm.Dict["Height"]), 0)
+		invert := false
+		if dec := d.Array(sm.Dict["Decode"]); len(dec) == 2 {
+			invert = intOr(d.Resolve(dec[0]), 0) == 1
+		}
+		if bits, ok := imageSamples(sm, mw, mh, 1, 1); ok {
+			alpha = func(x, y int) uint8 {
+				if (bits(x, y, 0) == 0) != invert {
+					return 0xff
+				}
+				return 0
+			}
+		}
+	}
+	if alpha != nil {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				i := out.PixOffset(x, y) + 3
+				a := alpha(x*mw/w, y*mh/h)
+				out.Pix[i] = uint8(uint(out.Pix[i]) * uint(a) / 255)
+			}
+		}
+	}
+	return out
+}
+
+// colorKeyed reports whether raw samples fall in the ranges of a color
+// key mask.
+func colorKeyed(d *Document, key Array, raw []int) bool {
+	for k, v := range raw {
+		lo := intOr(d.Resolve(key[2*k]), 0)
+		hi := intOr(d.Resolve(key[2*k+1]), 0)
+		if v < lo || v > hi {
+			return false
+		}
+	}
+	return true
+}
+
+// imageSamples decodes the samples of an image stream and returns a
+// function reading component k of pixel x, y.
+func imageSamples(s *Stream, w, h, n, bpc int) (func(x, y, k int) int, bool) {
+	if w <= 0 || h <= 0 || n <= 0 || (bpc != 1 && bpc != 2 && bpc != 4 && bpc != 8 && bpc != 16) {
+		return nil, false
+	}
+	data := s.Data
+	if len(s.Filters()) > 0 {
+		if !s.Decodable() {
+			return nil, false
+		}
+		var err error
+		if data, err = s.Decode(); err != nil {
+			return nil, false
+		}
+	}
+	stride := (w*n*bpc + 7) / 8
+	if len(data) < stride*h {
+		return nil, false
+	}
+	switch bpc {
+	case 8:
+		return func(x, y, k int) int { return int(data[y*stride+x*n+k]) }, true
+	case 16:
+		return func(x, y, k int) int {
+			i := y*stride + 2*(x*n+k)
+			return int(data[i])<<8 | int(data[i+1])
+		}, true
+	}
+	mask := 1<<bpc - 1
+	return func(x, y, k int) int {
+		bit := (x*n + k) * bpc
+		b := data[y*stride+bit/8]
+		return int(b>>(8-bpc-bit%8)) & mask
+	}, true
+}
@@ -0,0 +1,157 @@
+package pdfdoc
+
+import (
+	"encoding/binary"
+	"image/color"
+	"testing"
+)
+
+// squareFont returns a TrueType program whose glyph 1, mapped from "A",
+// is a square filling the em.
+func squareFont() []byte {
+	glyph := []byte{0, 1, 0, 0, 0, 0, 0x03, 0xe8, 0x03, 0xe8, 0, 3, 0, 0, 1, 1, 1, 1}
+	for _, v := range []int16{0, 1000, 0, -1000, 0, 0, 1000, 0} {
+		glyph = binary.BigEndian.AppendUint16(glyph, uint16(v))
+	}
+	loca := []byte{0, 0, 0, 0, 0, byte(len(glyph) / 2)}
+	head := make([]byte, 54)
+	binary.BigEndian.PutUint16(head[18:], 1000)
+	cmap := []byte{0, 0, 0, 1, 0, 1, 0, 0, 0, 0, 0, 12, 0, 0, 1, 6, 0, 0}
+	cmap = append(cmap, make([]byte, 256)...)
+	cmap[18+'A'] = 1
+
+	tables := []struct {
+		tag  string
+		data []byte
+	}{{"cmap", cmap}, {"glyf", glyph}, {"head", head}, {"loca", loca}}
+	out := []byte{0, 1, 0, 0, 0, byte(len(tables)), 0, 0, 0, 0, 0, 0}
+	off := 12 + 16*len(tables)
+	var body []byte
+	for _, t := range tables {
+		out = append(out, t.tag...)
+		out = binary.BigEndian.AppendUint32(out, 0)
+		out = binary.BigEndian.AppendUint32(out, uint32(off+len(body)))
+		out = binary.BigEndian.AppendUint32(out, uint32(len(t.data)))
+		body = append(body, t.data...)
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+	}
+	return append(out, body...)
+}
+
+func TestRenderPage(t *testing.T) {
+	d := New()
+	font := d.Add(Dict{
+		"Type": Name("Font"), "Subtype": Name("TrueType"), "BaseFont": Name("Square"),
+		"FirstChar": 65, "LastChar": 65, "Widths": Array{1000},
+		"FontDescriptor": d.Add(Dict{"Type": Name("FontDescriptor"), "FontFile2": d.Add(NewStream(nil, squareFont()))}),
+	})
+	img := NewStream(Dict{
+		"Type": Name("XObject"), "Subtype": Name("Image"), "Width": 2, "Height": 1,
+		"ColorSpace": Name("DeviceRGB"), "BitsPerComponent": 8, "Filter": Name("FlateDecode"),
+	}, []byte{0, 255, 0, 255, 255, 0})
+	shading := Dict{
+		"ShadingType": 2, "ColorSpace": Name("DeviceGray"), "Coords": Array{120, 0, 190, 0},
+		"Function": Dict{"FunctionType": 2, "Domain": Array{0, 1}, "C0": Array{0}, "C1": Array{1}, "N": 1},
+	}
+	content := "1 0 0 rg 10 10 50 50 re f\n" +
+		"0 0 1 RG 4 w 100 20 m 190 20 l S\n" +
+		"q 120 50 70 40 re W n /Sh0 sh Q\n" +
+		"q 20 0 0 10 70 70 cm /Im0 Do Q\n" +
+		"0 g BT /F1 20 Tf 100 30 Td (A) Tj ET\n"
+	page := d.Add(Dict{
+		"Type":     Name("Page"),
+		"MediaBox": Array{0, 0, 200, 100},
+		"Resources": Dict{
+			"Font":    Dict{"F1": font},
+			"XObject": Dict{"Im0": d.Add(img)},
+			"Shading": Dict{"Sh0": shading},
+		},
+		"Contents": d.Add(NewStream(nil, []byte(content))),
+	})
+	if err := d.SetPages([]Ref{page}); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := d.RenderPage(page, 72)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := out.Bounds(); b.Dx() != 200 || b.Dy() != 100 {
+		t.Fatalf("size: got %v", b)
+	}
+	near := func(c color.RGBA, r, g, b uint8) bool {
+		diff := func(a, b uint8) bool { return int(a) > int(b)+8 || int(b) > int(a)+8 }
+		return !diff(c.R, r) && !diff(c.G, g) && !diff(c.B, b)
+	}
+	for _, tc := range []struct {
+		name    string
+		x, y    int
+		r, g, b uint8
+	}{
+		{"background", 5, 5, 255, 255, 255},
+		{"fill", 30, 70, 255, 0, 0},
+		{"stroke", 150, 80, 0, 0, 255},
+		{"stroke edge", 150, 84, 255, 255, 255},
+		{"shading start", 121, 30, 4, 4, 4},
+		{"shading middle", 155, 30, 128, 128, 128},
+		{"clipped shading", 155, 5, 255, 255, 255},
+		{"image left", 75, 25, 0, 255, 0},
+		{"image right", 85, 25, 255, 255, 0},
+		{"text", 110, 60, 0, 0, 0},
+		{"after text", 125, 60, 255, 255, 255},
+	} {
+		if c := out.RGBAAt(tc.x, tc.y); !near(c, tc.r, tc.g, tc.b) {
+			t.Errorf("%s: pixel (%d,%d) is %v", tc.name, tc.x, tc.y, c)
+		}
+	}
+
+	// A rotated page is rendered upright, at twice the resolution.
+	d.Dict(page)["Rotate"] = 90
+	out, err = d.RenderPage(page, 144)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := out.Bounds(); b.Dx() != 200 || b.Dy() != 400 {
+		t.Fatalf("rotated size: got %v", b)
+	}
+	// The red square, near the bottom left corner, is now at the top left.
+	if c := out.RGBAAt(70, 60); !near(c, 255, 0, 0) {
+		t.Errorf("rotated fill: got %v", c)
+	}
+
+	if _, err := d.RenderPage(page, 0); err == nil {
+		t.Error("expected an error for a zero resolution")
+	}
+}
+
+func TestRenderPageUnsupportedFont(t *testing.T) {
+	d := New()
+	font := d.Add(Dict{
+		"Type": Name("Font"), "Subtype": Name("Type1"), "BaseFont": Name("Compact"),
+		"FirstChar": 65, "LastChar": 65, "Widths": Array{1000},
+		"FontDescriptor": d.Add(Dict{"Type": Name("FontDescriptor"), "FontFile3": d.Add(NewStream(Dict{"Subtype": Name("Type1C")}, []byte("not a CFF program")))}),
+	})
+	// The rectangle after the text shows that the page is still drawn.
+	page := d.Add(Dict{
+		"Type":      Name("Page"),
+		"MediaBox":  Array{0, 0, 200, 100},
+		"Resources": Dict{"Font": Dict{"F1": font}},
+		"Contents":  d.Add(NewStream(nil, []byte("0 g BT /F1 20 Tf 10 30 Td (AA) Tj ET\n1 0 0 rg 100 10 50 50 re f"))),
+	})
+	if err := d.SetPages([]Ref{page}); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := d.RenderPage(page, 72)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := out.RGBAAt(20, 60); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("text in an unsupported font drawn: %v", c)
+	}
+	if c := out.RGBAAt(120, 60); c.R < 240 || c.G > 16 {
+		t.Errorf("content after the text not drawn: %v", c)
+	}
+}
@@ -0,0 +1,74 @@
+package pdfdoc
+
+// ForEachResources calls fn for every resource dictionary used by the
+// document: those of the pages, of the form XObjects, patterns and Type 3
+// fonts they use, and of annotation appearance streams.
+func (d *Document) ForEachResources(fn func(res Dict)) {
+	// owners holds the indirect objects (resource dictionaries, forms,
+	// fonts, patterns) already visited.
+	owners := map[int]bool{}
+	once := func(o Object) bool {
+		r, ok := o.(Ref)
+		if !ok {
+			return true
+		}
+		if owners[r.Num] {
+			return false
+		}
+		owners[r.Num] = true
+		return true
+	}
+
+	var visit func(o Object, depth int)
+	visit = func(o Object, depth int) {
+		res := d.Dict(o)
+		if res == nil || depth > 32 || !once(o) {
+			return
+		}
+		fn(res)
+
+		for _, x := range d.Dict(res["XObject"]) {
+			if xo, ok := d.Resolve(x).(*Stream); ok && xo.Dict["Subtype"] == Name("Form") && once(x) {
+				visit(xo.Dict["Resources"], depth+1)
+			}
+		}
+		for _, f := range d.Dict(res["Font"]) {
+			if font := d.Dict(f); font["Subtype"] == Name("Type3") && once(f) {
+				visit(font["Resources"], depth+1)
+			}
+		}
+		for _, p := range d.Dict(res["Pattern"]) {
+			if ps, ok := d.Resolve(p).(*Stream); ok && once(p) {
+				visit(ps.Dict["Resources"], depth+1)
+			}
+		}
+	}
+
+	for _, p := range d.Pages() {
+		visit(d.PageAttr(p, "Resources"), 0)
+		for _, a := range d.Array(d.Dict(p)["Annots"]) {
+			for _, s := range d.AppearanceStreams(d.Dict(a)) {
+				visit(s.Dict["Resources"], 1)
+			}
+		}
+	}
+}
+
+// AppearanceStreams returns all appearance streams of an annotation,
+// including those of every appearance state.
+func (d *Document) AppearanceStreams(annot Dict) []*Stream {
+	var out []*Stream
+	for _, ap := range d.Dict(annot["AP"]) {
+		switch v := d.Resolve(ap).(type) {
+		case *Stream:
+			out = append(out, v)
+		case Dict:
+			for _, s := range v {
+				if st, ok := d.Resolve(s).(*Stream); ok {
+					out = append(out, st)
+				}
+			}
+		}
+	}
+	return out
+}
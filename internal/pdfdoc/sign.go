@@ -0,0 +1,181 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// SignOptions configures a digital signature.
+type SignOptions struct {
+	Signer crypto.Signer
+	// Certificates is the signing certificate followed by its chain.
+	Certificates []*x509.Certificate
+
+	Name        string
+	Reason      string
+	Location    string
+	ContactInfo string
+	// Time is the signing time. Default is now.
+	Time time.Time
+
+	// FieldName is the name of the signature form field. Default is
+	// "Signature1".
+	FieldName string
+	// Page and Rect (llx, lly, urx, ury) place a visible signature widget.
+	// The signature is invisible if Rect is empty.
+	Page int
+	Rect [4]float64
+}
+
+// byteRangePlaceholder reserves room for the final /ByteRange values.
+const byteRangePlaceholder = "[0 9999999999 9999999999 9999999999]"
+
+// Sign serializes the document with a detached PAdES signature
+// (ETSI.CAdES.detached) covering the whole file.
+func (d *Document) Sign(opt SignOptions) ([]byte, error) {
+	if opt.Signer == nil || len(opt.Certificates) == 0 {
+		return nil, fmt.Errorf("pdf: signer and certificate are required")
+	}
+	pages := d.Pages()
+	if opt.Page < 0 || opt.Page >= len(pages) {
+		return nil, fmt.Errorf("pdf: signature page %d out of range", opt.Page+1)
+	}
+	if opt.Time.IsZero() {
+		opt.Time = time.Now()
+	}
+	if opt.FieldName == "" {
+		opt.FieldName = "Signature1"
+	}
+
+	// Reserve space for the CMS structure: certificates, signed
+	// attributes and the signature value itself.
+	size := 8192
+	for _, c := range opt.Certificates {
+		size += len(c.Raw)
+	}
+
+	sig := Dict{
+		"Type":      Name("Sig"),
+		"Filter":    Name("Adobe.PPKLite"),
+		"SubFilter": Name("ETSI.CAdES.detached"),
+		"ByteRange": Array{0, 9999999999, 9999999999, 9999999999},
+		"Contents":  HexString(make([]byte, size)),
+		"M":         FormatDate(opt.Time),
+	}
+	for k, v := range map[Name]string{"Name": opt.Name, "Reason": opt.Reason, "Location": opt.Location, "ContactInfo": opt.ContactInfo} {
+		if v != "" {
+			sig[k] = TextString(v)
+		}
+	}
+
+	page := pages[opt.Page]
+	rect := opt.Rect
+	widget := Dict{
+		"Type":    Name("Annot"),
+		"Subtype": Name("Widget"),
+		"FT":      Name("Sig"),
+		"T":       TextString(opt.FieldName),
+		"V":       d.Add(sig),
+		"P":       page,
+		"Rect":    Array{rect[0], rect[1], rect[2], rect[3]},
+		"F":       4 | 128, // print, locked
+	}
+	if w, h := rect[2]-rect[0], rect[3]-rect[1]; w > 0 && h > 0 {
+		// The page content already shows the placeholder drawn by the
+		// template, so the appearance itself is empty.
+		widget["AP"] = Dict{"N": d.Add(appearance(w, h, nil, nil))}
+	} else {
+		widget["Rect"] = Array{0, 0, 0, 0}
+	}
+	ref := d.Add(widget)
+	d.AddAnnotation(page, ref)
+	d.AddFormField(ref)
+	d.AcroForm()["SigFlags"] = 3
+
+	data, err := d.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	br := bytes.Index(data, []byte("/ByteRange "+byteRangePlaceholder))
+	if br < 0 {
+		return nil, fmt.Errorf("pdf: signature dictionary not found in output")
+	}
+	br += len("/ByteRange ")
+	cs := bytes.Index(data[br:], []byte("/Contents <"))
+	if cs < 0 {
+		return nil, fmt.Errorf("pdf: signature contents not found in output")
+	}
+	cs += br + len("/Contents ")
+	ce := cs + 2 + 2*size
+
+	ranges := fmt.Sprintf("[0 %d %d %d]", cs, ce, len(data)-ce)
+	if len(ranges) > len(byteRangePlaceholder) {
+		return nil, fmt.Errorf("pdf: document too large to sign")
+	}
+	copy(data[br:], ranges+string(bytes.Repeat([]byte(" "), len(byteRangePlaceholder)-len(ranges))))
+
+	signed := make([]byte, 0, len(data)-(ce-cs))
+	signed = append(signed, data[:cs]...)
+	signed = append(signed, data[ce:]...)
+	cms, err := signCMS(signed, opt.Signer, opt.Certificates)
+	if err != nil {
+		return nil, err
+	}
+	if len(cms) > size {
+		return nil, fmt.Errorf("pdf: signature (%d bytes) exceeds reserved space (%d bytes)", len(cms), size)
+	}
+	hex.Encode(data[cs+1:], cms)
+	return data, nil
+}
+
+// AcroForm returns the interactive form dictionary, creating it if needed.
+func (d *Document) AcroForm() Dict {
+	cat := d.Catalog()
+	if form := d.Dict(cat["AcroForm"]); form != nil {
+		return form
+	}
+	form := Dict{"Fields": Array{}}
+	cat["AcroForm"] = d.Add(form)
+	return form
+}
+
+// AddFormField registers a terminal field in the interactive form.
+func (d *Document) AddFormField(field Ref) {
+	form := d.AcroForm()
+	fields := append(Array{}, d.Array(form["Fields"])...)
+	form["Fields"] = append(fields, field)
+}
+
+// AddAnnotation adds an annotation to a page.
+func (d *Document) AddAnnotation(page Ref, annot Ref) {
+	p := d.Dict(page)
+	annots := append(Array{}, d.Array(p["Annots"])...)
+	p["Annots"] = append(annots, annot)
+}
+
+// cryptObjectKeepSignature is cryptObject, except that the /Contents of a
+// signature dictionary is left alone: the signature value is never
+// encrypted.
+func cryptObjectKeepSignature(o Object, fn func([]byte) ([]byte, error)) (Object, error) {
+	sig, ok := o.(Dict)
+	if !ok || sig["Type"] != Name("Sig") || sig["Contents"] == nil {
+		return cryptObject(o, fn)
+	}
+	rest := make(Dict, len(sig))
+	for k, v := range sig {
+		if k != "Contents" {
+			rest[k] = v
+		}
+	}
+	out, err := cryptObject(rest, fn)
+	if err != nil {
+		return nil, err
+	}
+	out.(Dict)["Contents"] = sig["Contents"]
+	return out, nil
+}
@@ -0,0 +1,181 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func selfSigned(t *testing.T, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "ejspdf test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestSign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		key       crypto.Signer
+		rect      [4]float64
+		encrypt   bool
+		linearize bool
+	}{
+		{"rsa invisible", rsaKey, [4]float64{}, false, false},
+		{"ecdsa visible", ecKey, [4]float64{50, 50, 250, 110}, false, false},
+		{"rsa encrypted", rsaKey, [4]float64{}, true, false},
+		{"ecdsa linearized", ecKey, [4]float64{50, 50, 250, 110}, false, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cert := selfSigned(t, tc.key)
+			d, err := Parse(chromeLike(t, 2, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.encrypt {
+				if err := d.Encrypt(EncryptOptions{KeyBits: 128}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			d.Linearize = tc.linearize
+			out, err := d.Sign(SignOptions{
+				Signer:       tc.key,
+				Certificates: []*x509.Certificate{cert},
+				Reason:       "Approved",
+				Page:         1,
+				Rect:         tc.rect,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m := regexp.MustCompile(`/ByteRange \[0 (\d+) (\d+) (\d+) *\]`).FindSubmatch(out)
+			if m == nil {
+				t.Fatal("ByteRange not found")
+			}
+			var br [3]int
+			for i := range br {
+				br[i], _ = strconv.Atoi(string(m[i+1]))
+			}
+			if br[1]+br[2] != len(out) {
+				t.Fatalf("ByteRange %v does not cover the file (%d bytes)", br, len(out))
+			}
+			if out[br[0]] != '<' || out[br[1]-1] != '>' {
+				t.Fatalf("ByteRange gap is not the Contents string")
+			}
+			signed := append(append([]byte{}, out[:br[0]]...), out[br[1]:]...)
+			// The signature is zero-padded; asn1 ignores the trailing bytes.
+			der, err := hex.DecodeString(string(out[br[0]+1 : br[1]-1]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			verifyCMS(t, der, signed, cert)
+
+			var opened *Document
+			if tc.encrypt {
+				opened, err = ParseWithPassword(out, "")
+			} else {
+				opened, err = Parse(out)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			annots := opened.Array(opened.Dict(opened.Pages()[1])["Annots"])
+			if len(annots) != 1 {
+				t.Fatalf("page has %d annotations, want 1", len(annots))
+			}
+			widget := opened.Dict(annots[0])
+			if widget["FT"] != Name("Sig") || opened.Dict(widget["V"])["SubFilter"] != Name("ETSI.CAdES.detached") {
+				t.Errorf("unexpected widget %v", widget)
+			}
+			if visible := widget["AP"] != nil; visible != (tc.rect != [4]float64{}) {
+				t.Errorf("appearance present = %v", visible)
+			}
+			if opened.Dict(opened.Catalog()["AcroForm"])["SigFlags"] != 3 {
+				t.Error("SigFlags not set")
+			}
+		})
+	}
+}
+
+// verifyCMS checks a detached SignedData against content and cert.
+func verifyCMS(t *testing.T, der, content []byte, cert *x509.Certificate) {
+	t.Helper()
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		t.Fatal(err)
+	}
+	if ci.Content.Class != asn1.ClassContextSpecific || ci.Content.Tag != 0 {
+		t.Fatalf("content is not tagged [0]")
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		t.Fatal(err)
+	}
+	si := sd.SignerInfos[0]
+
+	var attrs []attribute
+	if _, err := asn1.UnmarshalWithParams(si.SignedAttrs.FullBytes, &attrs, "set,tag:0"); err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.Sum256(content)
+	found := false
+	for _, a := range attrs {
+		if a.Type.Equal(oidMessageDigest) {
+			var got []byte
+			if _, err := asn1.Unmarshal(a.Values.Bytes, &got); err != nil {
+				t.Fatal(err)
+			}
+			found = bytes.Equal(got, want[:])
+		}
+	}
+	if !found {
+		t.Fatal("messageDigest does not match the signed byte ranges")
+	}
+
+	// The signature is computed over the attributes encoded as a SET.
+	set := append([]byte{}, si.SignedAttrs.FullBytes...)
+	set[0] = 0x31
+	alg := x509.SHA256WithRSA
+	if _, ok := cert.PublicKey.(*ecdsa.PublicKey); ok {
+		alg = x509.ECDSAWithSHA256
+	}
+	if err := cert.CheckSignature(alg, set, si.Signature); err != nil {
+		t.Fatalf("signature does not verify: %v", err)
+	}
+}
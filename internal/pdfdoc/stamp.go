@@ -0,0 +1,129 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Import copies o from src into d, along with every object it references,
+// and returns the copy.
+func (d *Document) Import(src *Document, o Object) Object {
+	return d.importObject(src, o, map[int]Ref{})
+}
+
+func (d *Document) importObject(src *Document, o Object, copied map[int]Ref) Object {
+	switch v := o.(type) {
+	case Ref:
+		if r, ok := copied[v.Num]; ok {
+			return r
+		}
+		target, ok := src.objects[v.Num]
+		if !ok {
+			return nil
+		}
+		r := d.Add(nil)
+		copied[v.Num] = r
+		d.Set(r, d.importObject(src, target, copied))
+		return r
+	case Dict:
+		c := make(Dict, len(v))
+		for k, e := range v {
+			c[k] = d.importObject(src, e, copied)
+		}
+		return c
+	case Array:
+		c := make(Array, len(v))
+		for i, e := range v {
+			c[i] = d.importObject(src, e, copied)
+		}
+		return c
+	case *Stream:
+		return &Stream{Dict: d.importObject(src, v.Dict, copied).(Dict), Data: append([]byte(nil), v.Data...)}
+	}
+	return Clone(o)
+}
+
+// ImportPage copies a page of src into d as a form XObject, which can then
+// be drawn on pages of d with Stamp.
+func (d *Document) ImportPage(src *Document, page Ref) (Ref, error) {
+	p := src.Dict(page)
+	if p == nil {
+		return Ref{}, fmt.Errorf("pdf: page object %d not found", page.Num)
+	}
+	var content []byte
+	streams := Array{p["Contents"]}
+	if arr := src.Array(p["Contents"]); arr != nil {
+		streams = arr
+	}
+	for _, c := range streams {
+		s, ok := src.Resolve(c).(*Stream)
+		if !ok {
+			continue
+		}
+		data, err := s.Decode()
+		if err != nil {
+			return Ref{}, fmt.Errorf("pdf: decode page content: %w", err)
+		}
+		content = append(append(content, data...), '\n')
+	}
+
+	box := src.PageBox(page, "CropBox")
+	form := Dict{
+		"Type":      Name("XObject"),
+		"Subtype":   Name("Form"),
+		"BBox":      Array{box[0], box[1], box[2], box[3]},
+		"Resources": d.Import(src, src.PageAttr(page, "Resources")),
+	}
+	if group := p["Group"]; group != nil {
+		form["Group"] = d.Import(src, group)
+	}
+	return d.Add(NewStream(form, content)), nil
+}
+
+// Stamp draws a form XObject on a page, scaled to fit the page's crop box.
+// The form is drawn over the page content, or under it if behind is set.
+// It is marked as a watermark artifact so it is ignored by assistive
+// technology.
+func (d *Document) Stamp(page Ref, form Ref, behind bool) error {
+	fs, ok := d.Resolve(form).(*Stream)
+	if !ok {
+		return fmt.Errorf("pdf: form XObject %d not found", form.Num)
+	}
+	var bbox [4]float64
+	arr := d.Array(fs.Dict["BBox"])
+	if len(arr) != 4 {
+		return fmt.Errorf("pdf: form XObject %d has no BBox", form.Num)
+	}
+	for i := range bbox {
+		bbox[i], _ = Number(d.Resolve(arr[i]))
+	}
+	if bbox[2] == bbox[0] || bbox[3] == bbox[1] {
+		return fmt.Errorf("pdf: form XObject %d is empty", form.Num)
+	}
+
+	box := d.PageBox(page, "CropBox")
+	sx := (box[2] - box[0]) / (bbox[2] - bbox[0])
+	sy := (box[3] - box[1]) / (bbox[3] - bbox[1])
+	tx := box[0] - bbox[0]*sx
+	ty := box[1] - bbox[1]*sy
+
+	name := d.AddResource(d.PageResources(page), "XObject", "Wm", form)
+	var buf bytes.Buffer
+	buf.WriteString("/Artifact <</Type /Pagination /Subtype /Watermark>> BDC\nq ")
+	for _, v := range []float64{sx, 0, 0, sy, tx, ty} {
+		buf.WriteString(formatReal(v) + " ")
+	}
+	buf.WriteString("cm ")
+	writeName(&buf, name)
+	buf.WriteString(" Do Q\nEMC\n")
+
+	if behind {
+		d.PrependContent(page, NewStream(nil, buf.Bytes()))
+		return nil
+	}
+	// Isolate the page content so it cannot leave the graphics state
+	// altered for the stamp.
+	d.PrependContent(page, NewStream(nil, []byte("q\n")))
+	d.AppendContent(page, NewStream(nil, append([]byte("Q\n"), buf.Bytes()...)))
+	return nil
+}
@@ -0,0 +1,66 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestImportPageAndStamp(t *testing.T) {
+	d, err := Parse(chromeLike(t, 2, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay, err := Parse(chromeLike(t, 1, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	form, err := d.ImportPage(overlay, overlay.Pages()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Stamp(d.Pages()[0], form, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Stamp(d.Pages()[1], form, true); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err = Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range d.Pages() {
+		var content []byte
+		for _, c := range d.Array(d.Dict(p)["Contents"]) {
+			data, err := d.Resolve(c).(*Stream).Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			content = append(content, data...)
+		}
+		do := bytes.Index(content, []byte("/Wm1 Do"))
+		own := bytes.Index(content, []byte("Tj"))
+		if do < 0 || own < 0 {
+			t.Fatalf("page %d: unexpected content %q", i+1, content)
+		}
+		if behind := do < own; behind != (i == 1) {
+			t.Errorf("page %d: stamp drawn behind = %v", i+1, behind)
+		}
+		xo := d.Dict(d.Dict(d.Dict(p)["Resources"])["XObject"])
+		fs, ok := d.Resolve(xo["Wm1"]).(*Stream)
+		if !ok || fs.Dict["Subtype"] != Name("Form") {
+			t.Fatalf("page %d: watermark form not in resources", i+1)
+		}
+		data, err := fs.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(data, []byte("(Page 1)")) {
+			t.Errorf("page %d: unexpected form content %q", i+1, data)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package pdfdoc
+
+// IsTagged reports whether the document has a logical structure tree.
+func (d *Document) IsTagged() bool {
+	cat := d.Catalog()
+	return cat != nil && cat["StructTreeRoot"] != nil
+}
+
+// SetAccessibility sets the catalog entries expected of an accessible
+// document: the natural language, the marked-content flag for tagged
+// documents, and showing the title instead of the file name.
+func (d *Document) SetAccessibility(lang string) {
+	cat := d.Catalog()
+	if cat == nil {
+		return
+	}
+	if lang != "" {
+		cat["Lang"] = TextString(lang)
+	}
+	if d.IsTagged() {
+		mark := d.Dict(cat["MarkInfo"])
+		if mark == nil {
+			mark = Dict{}
+			cat["MarkInfo"] = mark
+		}
+		mark["Marked"] = true
+	}
+	prefs := d.Dict(cat["ViewerPreferences"])
+	if prefs == nil {
+		prefs = Dict{}
+		cat["ViewerPreferences"] = prefs
+	}
+	prefs["DisplayDocTitle"] = true
+}
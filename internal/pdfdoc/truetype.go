@@ -0,0 +1,340 @@
+package pdfdoc
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// trueType reads glyph outlines from an embedded TrueType font program.
+type trueType struct {
+	unitsPerEm float64
+	loca       []int
+	glyf       []byte
+	cmap       map[int]int // character code to glyph, for simple fonts
+	cache      map[int][]glyphOp
+}
+
+// glyphOp is an outline segment in font units: a move, a line or a
+// quadratic curve through ctrl to to.
+type glyphOp struct {
+	op       byte // 'M', 'L' or 'Q'
+	ctrl, to point
+}
+
+func parseTrueType(data []byte) (*trueType, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("pdf: truncated font program")
+	}
+	tables := map[string][]byte{}
+	n := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < n; i++ {
+		e := 12 + 16*i
+		if e+16 > len(data) {
+			break
+		}
+		off := int(binary.BigEndian.Uint32(data[e+8:]))
+		length := int(binary.BigEndian.Uint32(data[e+12:]))
+		if off < 0 || length < 0 || off+length > len(data) {
+			continue
+		}
+		tables[string(data[e:e+4])] = data[off : off+length]
+	}
+	head, loca, glyf := tables["head"], tables["loca"], tables["glyf"]
+	if len(head) < 54 || loca == nil || glyf == nil {
+		return nil, fmt.Errorf("pdf: font program has no glyph outlines")
+	}
+	t := &trueType{
+		unitsPerEm: float64(binary.BigEndian.Uint16(head[18:])),
+		glyf:       glyf,
+		cache:      map[int][]glyphOp{},
+	}
+	if t.unitsPerEm == 0 {
+		t.unitsPerEm = 1000
+	}
+	if binary.BigEndian.Uint16(head[50:]) == 0 {
+		for i := 0; i+2 <= len(loca); i += 2 {
+			t.loca = append(t.loca, 2*int(binary.BigEndian.Uint16(loca[i:])))
+		}
+	} else {
+		for i := 0; i+4 <= len(loca); i += 4 {
+			t.loca = append(t.loca, int(binary.BigEndian.Uint32(loca[i:])))
+		}
+	}
+	t.cmap = parseCmap(tables["cmap"])
+	return t, nil
+}
+
+// parseCmap reads the (3,0), (3,1) or (1,0) subtable of a cmap table.
+// Symbolic (3,0) codes in the F000 range are also mapped from their low
+// byte, as PDF simple fonts address them.
+func parseCmap(data []byte) map[int]int {
+	if len(data) < 4 {
+		return nil
+	}
+	var best []byte
+	rank := 0
+	n := int(binary.BigEndian.Uint16(data[2:]))
+	for i := 0; i < n && 4+8*i+8 <= len(data); i++ {
+		e := data[4+8*i:]
+		platform, encoding := binary.BigEndian.Uint16(e), binary.BigEndian.Uint16(e[2:])
+		off := int(binary.BigEndian.Uint32(e[4:]))
+		if off >= len(data) {
+			continue
+		}
+		r := 0
+		switch {
+		case platform == 3 && encoding == 0:
+			r = 3
+		case platform == 3 && encoding == 1:
+			r = 2
+		case platform == 1 && encoding == 0:
+			r = 1
+		}
+		if r > rank {
+			best, rank = data[off:], r
+		}
+	}
+	if len(best) < 6 {
+		return nil
+	}
+	m := map[int]int{}
+	switch binary.BigEndian.Uint16(best) {
+	case 0:
+		for c := 0; c < 256 && 6+c < len(best); c++ {
+			m[c] = int(best[6+c])
+		}
+	case 4:
+		segs := int(binary.BigEndian.Uint16(best[6:])) / 2
+		if 16+8*segs > len(best) {
+			return nil
+		}
+		ends, starts := best[14:], best[16+2*segs:]
+		deltas, offsets := best[16+4*segs:], best[16+6*segs:]
+		for s := 0; s < segs; s++ {
+			end := int(binary.BigEndian.Uint16(ends[2*s:]))
+			start := int(binary.BigEndian.Uint16(starts[2*s:]))
+			delta := int(binary.BigEndian.Uint16(deltas[2*s:]))
+			ro := int(binary.BigEndian.Uint16(offsets[2*s:]))
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				g := 0
+				if ro == 0 {
+					g = (c + delta) & 0xFFFF
+				} else {
+					i := 16 + 6*segs + 2*s + ro + 2*(c-start)
+					if i+2 > len(best) {
+						continue
+					}
+					if g = int(binary.BigEndian.Uint16(best[i:])); g != 0 {
+						g = (g + delta) & 0xFFFF
+					}
+				}
+				m[c] = g
+				if rank == 3 && c >= 0xF000 && c <= 0xF0FF {
+					m[c&0xFF] = g
+				}
+			}
+		}
+	}
+	return m
+}
+
+// outline returns the outline of glyph gid in font units.
+func (t *trueType) outline(gid int) []glyphOp {
+	if ops, ok := t.cache[gid]; ok {
+		return ops
+	}
+	ops := t.glyph(gid, 0)
+	t.cache[gid] = ops
+	return ops
+}
+
+func (t *trueType) glyph(gid, depth int) []glyphOp {
+	if gid < 0 || gid+1 >= len(t.loca) || depth > 8 {
+		return nil
+	}
+	start, end := t.loca[gid], t.loca[gid+1]
+	if start >= end || end > len(t.glyf) || end-start < 10 {
+		return nil
+	}
+	g := t.glyf[start:end]
+	contours := int(int16(binary.BigEndian.Uint16(g)))
+	if contours < 0 {
+		return t.composite(g[10:], depth)
+	}
+	return simpleGlyph(g[10:], contours)
+}
+
+// simpleGlyph decodes the contours of a simple glyph.
+func simpleGlyph(g []byte, contours int) []glyphOp {
+	if len(g) < 2*contours+2 {
+		return nil
+	}
+	ends := make([]int, contours)
+	for i := range ends {
+		ends[i] = int(binary.BigEndian.Uint16(g[2*i:]))
+	}
+	if contours == 0 {
+		return nil
+	}
+	n := ends[contours-1] + 1
+	p := 2*contours + 2 + int(binary.BigEndian.Uint16(g[2*contours:]))
+	flags := make([]byte, 0, n)
+	for len(flags) < n && p < len(g) {
+		f := g[p]
+		p++
+		flags = append(flags, f)
+		if f&8 != 0 && p < len(g) {
+			for r := g[p]; r > 0 && len(flags) < n; r-- {
+				flags = append(flags, f)
+			}
+			p++
+		}
+	}
+	if len(flags) < n {
+		return nil
+	}
+	coords := func(short, same byte) []float64 {
+		out := make([]float64, n)
+		v := 0
+		for i, f := range flags {
+			switch {
+			case f&short != 0:
+				if p >= len(g) {
+					return nil
+				}
+				d := int(g[p])
+				p++
+				if f&same == 0 {
+					d = -d
+				}
+				v += d
+			case f&same == 0:
+				if p+2 > len(g) {
+					return nil
+				}
+				v += int(int16(binary.BigEndian.Uint16(g[p:])))
+				p += 2
+			}
+			out[i] = float64(v)
+		}
+		return out
+	}
+	xs := coords(2, 16)
+	ys := coords(4, 32)
+	if xs == nil || ys == nil {
+		return nil
+	}
+
+	var ops []glyphOp
+	first := 0
+	for _, last := range ends {
+		if last < first || last >= n {
+			break
+		}
+		ops = append(ops, contourOps(xs[first:last+1], ys[first:last+1], flags[first:last+1])...)
+		first = last + 1
+	}
+	return ops
+}
+
+// contourOps converts a contour of on- and off-curve points to segments.
+// Two consecutive off-curve points imply an on-curve point between them.
+func contourOps(xs, ys []float64, flags []byte) []glyphOp {
+	n := len(xs)
+	on := func(i int) bool { return flags[i%n]&1 != 0 }
+	pt := func(i int) point { return point{xs[i%n], ys[i%n]} }
+	mid := func(a, b point) point { return point{(a.x + b.x) / 2, (a.y + b.y) / 2} }
+
+	// Start on an on-curve point, or between two off-curve ones, and
+	// visit the other points in order.
+	start := pt(0)
+	from, to := 1, n
+	switch {
+	case on(0):
+	case on(n - 1):
+		start, from, to = pt(n-1), 0, n-1
+	default:
+		start, from = mid(pt(n-1), pt(0)), 0
+	}
+	ops := []glyphOp{{op: 'M', to: start}}
+	var ctrl *point
+	for i := from; i < to; i++ {
+		p := pt(i)
+		if on(i) {
+			if ctrl != nil {
+				ops = append(ops, glyphOp{op: 'Q', ctrl: *ctrl, to: p})
+				ctrl = nil
+			} else {
+				ops = append(ops, glyphOp{op: 'L', to: p})
+			}
+			continue
+		}
+		if ctrl != nil {
+			ops = append(ops, glyphOp{op: 'Q', ctrl: *ctrl, to: mid(*ctrl, p)})
+		}
+		ctrl = &p
+	}
+	if ctrl != nil {
+		ops = append(ops, glyphOp{op: 'Q', ctrl: *ctrl, to: start})
+	} else {
+		ops = append(ops, glyphOp{op: 'L', to: start})
+	}
+	return ops
+}
+
+// composite assembles a composite glyph from its components.
+func (t *trueType) composite(g []byte, depth int) []glyphOp {
+	var ops []glyphOp
+	p := 0
+	for p+4 <= len(g) {
+		flags := binary.BigEndian.Uint16(g[p:])
+		gid := int(binary.BigEndian.Uint16(g[p+2:]))
+		p += 4
+		var dx, dy float64
+		if flags&1 != 0 {
+			if p+4 > len(g) {
+				break
+			}
+			dx, dy = float64(int16(binary.BigEndian.Uint16(g[p:]))), float64(int16(binary.BigEndian.Uint16(g[p+2:])))
+			p += 4
+		} else {
+			if p+2 > len(g) {
+				break
+			}
+			dx, dy = float64(int8(g[p])), float64(int8(g[p+1]))
+			p += 2
+		}
+		if flags&2 == 0 {
+			// Point matching is not supported.
+			dx, dy = 0, 0
+		}
+		f2dot14 := func() float64 {
+			v := float64(int16(binary.BigEndian.Uint16(g[p:]))) / 16384
+			p += 2
+			return v
+		}
+		m := matrix{1, 0, 0, 1, dx, dy}
+		switch {
+		case flags&8 != 0 && p+2 <= len(g):
+			s := f2dot14()
+			m[0], m[3] = s, s
+		case flags&0x40 != 0 && p+4 <= len(g):
+			m[0] = f2dot14()
+			m[3] = f2dot14()
+		case flags&0x80 != 0 && p+8 <= len(g):
+			m[0] = f2dot14()
+			m[1] = f2dot14()
+			m[2] = f2dot14()
+			m[3] = f2dot14()
+		}
+		for _, op := range t.glyph(gid, depth+1) {
+			op.ctrl = m.apply(op.ctrl.x, op.ctrl.y)
+			op.to = m.apply(op.to.x, op.to.y)
+			ops = append(ops, op)
+		}
+		if flags&0x20 == 0 {
+			break
+		}
+	}
+	return ops
+}
@@ -0,0 +1,267 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Bytes serializes the document.
+func (d *Document) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := d.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Write serializes the document to w. Only objects reachable from the
+// trailer are written, and they are renumbered sequentially, so objects
+// that were detached from the document are dropped.
+func (d *Document) Write(w io.Writer) error {
+	if d.Linearize {
+		return d.writeLinearized(w)
+	}
+	order := d.reachable()
+	wr := &writer{doc: d, renum: make(map[int]int, len(order))}
+	for i, num := range order {
+		wr.renum[num] = i + 1
+	}
+
+	wr.buf.WriteString(header(d.Version))
+	offsets := make([]int, len(order)+1)
+	for i, num := range order {
+		offsets[i+1] = wr.buf.Len()
+		wr.writeIndirect(num)
+	}
+
+	xref := wr.buf.Len()
+	fmt.Fprintf(&wr.buf, "xref\n0 %d\n0000000000 65535 f\r\n", len(order)+1)
+	for _, off := range offsets[1:] {
+		fmt.Fprintf(&wr.buf, "%010d 00000 n\r\n", off)
+	}
+
+	trailer := Dict{"Size": len(order) + 1}
+	for k, v := range d.Trailer {
+		switch k {
+		case "Size", "Prev", "XRefStm":
+			continue
+		}
+		trailer[k] = v
+	}
+	if _, ok := trailer["ID"]; !ok {
+		trailer["ID"] = fileID(wr.buf.Bytes())
+	}
+	wr.buf.WriteString("trailer\n")
+	wr.writeObject(trailer)
+	fmt.Fprintf(&wr.buf, "\nstartxref\n%d\n%%%%EOF\n", xref)
+
+	_, err := w.Write(wr.buf.Bytes())
+	return err
+}
+
+// header returns the file header. The comment line of binary characters
+// tells transfer programs that the file is binary.
+func header(version string) string {
+	return "%PDF-" + version + "\n%\xE2\xE3\xCF\xD3\n"
+}
+
+// fileID derives a file identifier from the content, as required by
+// PDF/A.
+func fileID(content []byte) Array {
+	sum := md5.Sum(content)
+	return Array{HexString(sum[:]), HexString(sum[:])}
+}
+
+// reachable returns the numbers of all objects reachable from the
+// trailer, in discovery order.
+func (d *Document) reachable() []int {
+	var order []int
+	seen := map[int]bool{}
+	var queue []Object
+	for _, k := range sortedKeys(d.Trailer) {
+		queue = append(queue, d.Trailer[k])
+	}
+	for len(queue) > 0 {
+		o := queue[0]
+		queue = queue[1:]
+		switch v := o.(type) {
+		case Ref:
+			if seen[v.Num] {
+				continue
+			}
+			target, ok := d.objects[v.Num]
+			if !ok {
+				continue
+			}
+			seen[v.Num] = true
+			order = append(order, v.Num)
+			queue = append(queue, target)
+		case Dict:
+			for _, k := range sortedKeys(v) {
+				queue = append(queue, v[k])
+			}
+		case Array:
+			queue = append(queue, v...)
+		case *Stream:
+			queue = append(queue, v.Dict)
+		}
+	}
+	return order
+}
+
+type writer struct {
+	doc   *Document
+	buf   bytes.Buffer
+	renum map[int]int
+}
+
+// writeIndirect writes object num under its new number.
+func (w *writer) writeIndirect(num int) {
+	enc, _ := w.doc.Trailer["Encrypt"].(Ref)
+	w.writeAs(w.renum[num], w.doc.objects[num], num != enc.Num)
+}
+
+// writeAs writes o as indirect object n. It is encrypted if the document
+// is and encrypt is set.
+func (w *writer) writeAs(n int, o Object, encrypt bool) {
+	w.buf.WriteString(strconv.Itoa(n))
+	w.buf.WriteString(" 0 obj\n")
+	if w.doc.security != nil && encrypt {
+		// Strings and streams are encrypted with a key derived from the
+		// number of the object as written.
+		o, _ = cryptObjectKeepSignature(o, func(b []byte) ([]byte, error) {
+			return w.doc.security.encrypt(n, 0, b), nil
+		})
+	}
+	w.writeObject(o)
+	w.buf.WriteString("\nendobj\n")
+}
+
+func (w *writer) writeObject(o Object) {
+	switch v := o.(type) {
+	case nil:
+		w.buf.WriteString("null")
+	case bool:
+		if v {
+			w.buf.WriteString("true")
+		} else {
+			w.buf.WriteString("false")
+		}
+	case int:
+		w.buf.WriteString(strconv.Itoa(v))
+	case float64:
+		w.buf.WriteString(formatReal(v))
+	case Name:
+		writeName(&w.buf, v)
+	case String:
+		writeLiteral(&w.buf, v)
+	case HexString:
+		writeHex(&w.buf, v)
+	case Ref:
+		if n, ok := w.renum[v.Num]; ok {
+			fmt.Fprintf(&w.buf, "%d 0 R", n)
+		} else {
+			w.buf.WriteString("null")
+		}
+	case Array:
+		w.buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				w.buf.WriteByte(' ')
+			}
+			w.writeObject(e)
+		}
+		w.buf.WriteByte(']')
+	case Dict:
+		w.buf.WriteString("<<")
+		for _, k := range sortedKeys(v) {
+			writeName(&w.buf, k)
+			w.buf.WriteByte(' ')
+			w.writeObject(v[k])
+		}
+		w.buf.WriteString(">>")
+	case *Stream:
+		v.Dict["Length"] = len(v.Data)
+		w.writeObject(v.Dict)
+		w.buf.WriteString("\nstream\n")
+		w.buf.Write(v.Data)
+		w.buf.WriteString("\nendstream")
+	default:
+		w.buf.WriteString("null")
+	}
+}
+
+func formatReal(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 5, 64)
+	s = trimZeros(s)
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+func trimZeros(s string) string {
+	if !bytes.ContainsRune([]byte(s), '.') {
+		return s
+	}
+	for s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	if s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+func writeName(buf *bytes.Buffer, n Name) {
+	buf.WriteByte('/')
+	for i := 0; i < len(n); i++ {
+		c := n[i]
+		if c < 0x21 || c > 0x7e || c == '#' || isDelim(c) {
+			fmt.Fprintf(buf, "#%02X", c)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+}
+
+func writeLiteral(buf *bytes.Buffer, s []byte) {
+	buf.WriteByte('(')
+	for _, c := range s {
+		switch c {
+		case '(', ')', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\n':
+			buf.WriteString(`\n`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte(')')
+}
+
+func writeHex(buf *bytes.Buffer, s []byte) {
+	const digits = "0123456789ABCDEF"
+	buf.WriteByte('<')
+	for _, c := range s {
+		buf.WriteByte(digits[c>>4])
+		buf.WriteByte(digits[c&0x0f])
+	}
+	buf.WriteByte('>')
+}
+
+func sortedKeys(d Dict) []Name {
+	keys := make([]Name, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,152 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatDate formats t as a PDF date string.
+func FormatDate(t time.Time) String {
+	_, offset := t.Zone()
+	sign := byte('+')
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	if offset == 0 {
+		return String(t.Format("D:20060102150405") + "Z")
+	}
+	return String(fmt.Sprintf("%s%c%02d'%02d'", t.Format("D:20060102150405"), sign, offset/3600, offset/60%60))
+}
+
+// ParseDate parses a PDF date string such as "D:20240131120000+07'00'".
+func ParseDate(s string) (time.Time, bool) {
+	s = strings.TrimPrefix(s, "D:")
+	if len(s) < 4 {
+		return time.Time{}, false
+	}
+	field := func(i, j, def int) int {
+		if len(s) < j {
+			return def
+		}
+		v, err := strconv.Atoi(s[i:j])
+		if err != nil {
+			return def
+		}
+		return v
+	}
+	t := time.Date(field(0, 4, 0), time.Month(field(4, 6, 1)), field(6, 8, 1),
+		field(8, 10, 0), field(10, 12, 0), field(12, 14, 0), 0, time.UTC)
+
+	if len(s) > 14 && (s[14] == '+' || s[14] == '-') {
+		tz := strings.ReplaceAll(s[15:], "'", "")
+		hh, _ := strconv.Atoi(tz[:min(2, len(tz))])
+		mm := 0
+		if len(tz) >= 4 {
+			mm, _ = strconv.Atoi(tz[2:4])
+		}
+		offset := hh*3600 + mm*60
+		if s[14] == '-' {
+			offset = -offset
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.FixedZone("", offset))
+	}
+	return t, true
+}
+
+// XMPPart is an additional XMP property group, e.g. the PDF/A
+// identification or a Factur-X extension schema.
+type XMPPart struct {
+	// Namespace and Prefix identify the schema.
+	Namespace string
+	Prefix    string
+	// Raw is inserted as-is inside the rdf:Description element.
+	Raw string
+}
+
+// SetMetadata writes an XMP metadata stream to the catalog that mirrors
+// the document information dictionary, plus the given extra parts.
+func (d *Document) SetMetadata(parts ...XMPPart) {
+	cat := d.Catalog()
+	if cat == nil {
+		return
+	}
+	info := d.Info()
+	text := func(key Name) string {
+		b, _ := StringBytes(d.Resolve(info[key]))
+		return DecodeTextString(b)
+	}
+	date := func(key Name) string {
+		t, ok := ParseDate(text(key))
+		if !ok {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	esc := func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	var b strings.Builder
+	b.WriteString("<?xpacket begin=\"\xEF\xBB\xBF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.WriteString("<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("<rdf:Description rdf:about=\"\"")
+	b.WriteString(" xmlns:dc=\"http://purl.org/dc/elements/1.1/\"")
+	b.WriteString(" xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\"")
+	b.WriteString(" xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\"")
+	for _, p := range parts {
+		fmt.Fprintf(&b, " xmlns:%s=\"%s\"", p.Prefix, p.Namespace)
+	}
+	b.WriteString(">\n")
+
+	b.WriteString("<dc:format>application/pdf</dc:format>\n")
+	if v := text("Title"); v != "" {
+		fmt.Fprintf(&b, "<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", esc(v))
+	}
+	if v := text("Author"); v != "" {
+		fmt.Fprintf(&b, "<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", esc(v))
+	}
+	if v := text("Subject"); v != "" {
+		fmt.Fprintf(&b, "<dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n", esc(v))
+	}
+	if v := text("Keywords"); v != "" {
+		fmt.Fprintf(&b, "<pdf:Keywords>%s</pdf:Keywords>\n", esc(v))
+	}
+	if v := text("Producer"); v != "" {
+		fmt.Fprintf(&b, "<pdf:Producer>%s</pdf:Producer>\n", esc(v))
+	}
+	if v := text("Creator"); v != "" {
+		fmt.Fprintf(&b, "<xmp:CreatorTool>%s</xmp:CreatorTool>\n", esc(v))
+	}
+	if v := date("CreationDate"); v != "" {
+		fmt.Fprintf(&b, "<xmp:CreateDate>%s</xmp:CreateDate>\n", v)
+	}
+	if v := date("ModDate"); v != "" {
+		fmt.Fprintf(&b, "<xmp:ModifyDate>%s</xmp:ModifyDate>\n", v)
+	}
+	for _, p := range parts {
+		b.WriteString(p.Raw)
+		b.WriteString("\n")
+	}
+
+	b.WriteString("</rdf:Description>\n</rdf:RDF>\n</x:xmpmeta>\n")
+	// Padding allows in-place edits by other tools.
+	b.WriteString(strings.Repeat(strings.Repeat(" ", 99)+"\n", 20))
+	b.WriteString("<?xpacket end=\"w\"?>")
+
+	// Metadata streams are left unfiltered so they stay readable by
+	// tools that do not parse PDF.
+	data := []byte(b.String())
+	cat["Metadata"] = d.Add(&Stream{
+		Dict: Dict{"Type": Name("Metadata"), "Subtype": Name("XML"), "Length": len(data)},
+		Data: data,
+	})
+}
@@ -2,6 +2,7 @@ package renderer
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -77,7 +78,7 @@ func (r *Runtime) setupNodePolyfills() {
 	// Use explicit variable for function to ensure it's not GC'd or lost (though not likely issue)
 	readFileFunc := func(call goja.FunctionCall) goja.Value {
 		pathVar := call.Argument(0).String()
-		b, err := os.ReadFile(pathVar)
+		b, err := r.readFile(pathVar)
 		if err != nil {
 			panic(r.vm.ToValue(fmt.Sprintf("fs.readFileSync failed: %v", err)))
 		}
@@ -87,7 +88,7 @@ func (r *Runtime) setupNodePolyfills() {
 
 	existsFunc := func(call goja.FunctionCall) goja.Value {
 		pathVar := call.Argument(0).String()
-		_, err := os.Stat(pathVar)
+		_, err := r.stat(pathVar)
 		exists := err == nil || !os.IsNotExist(err)
 		return r.vm.ToValue(exists)
 	}
@@ -132,3 +133,19 @@ func (r *Runtime) setupNodePolyfills() {
 		}
 	})
 }
+
+// readFile reads the file at name from r.FS, or from the OS file system.
+func (r *Runtime) readFile(name string) ([]byte, error) {
+	if r.FS != nil {
+		return fs.ReadFile(r.FS, filepath.ToSlash(name))
+	}
+	return os.ReadFile(name)
+}
+
+// stat returns the file info of name in r.FS, or in the OS file system.
+func (r *Runtime) stat(name string) (fs.FileInfo, error) {
+	if r.FS != nil {
+		return fs.Stat(r.FS, filepath.ToSlash(name))
+	}
+	return os.Stat(name)
+}
@@ -1,9 +1,16 @@
 package renderer
 
-import "github.com/dop251/goja"
+import (
+	"io/fs"
+
+	"github.com/dop251/goja"
+)
 
 type Runtime struct {
 	vm *goja.Runtime
+
+	// FS, if set, is read by includes instead of the OS file system.
+	FS fs.FS
 }
 
 func New() *Runtime {
@@ -0,0 +1,225 @@
+// Package pdfutil provides page-level operations on PDF files, such as the
+// ones produced by ejspdf: counting, extracting, reordering, rotating,
+// splitting and rasterizing pages. It is written in pure Go and does not
+// need Chrome.
+//
+// Pages are numbered from 1. Encrypted documents are not supported.
+package pdfutil
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yodsakorn-so/ejspdf/internal/pdfdoc"
+)
+
+// PageCount returns the number of pages of a PDF.
+func PageCount(pdf []byte) (int, error) {
+	doc, err := parse(pdf)
+	if err != nil {
+		return 0, err
+	}
+	return len(doc.Pages()), nil
+}
+
+// Extract returns a PDF holding the given pages, in the given order.
+// A page may not be listed twice.
+func Extract(pdf []byte, pages ...int) ([]byte, error) {
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("pdfutil: no pages to extract")
+	}
+	doc, err := parse(pdf)
+	if err != nil {
+		return nil, err
+	}
+	all := doc.Pages()
+	refs := make([]pdfdoc.Ref, len(pages))
+	for i, p := range pages {
+		if p < 1 || p > len(all) {
+			return nil, fmt.Errorf("pdfutil: page %d out of range (document has %d pages)", p, len(all))
+		}
+		refs[i] = all[p-1]
+	}
+	if err := doc.KeepPages(refs); err != nil {
+		return nil, fmt.Errorf("pdfutil: %w", err)
+	}
+	return doc.Bytes()
+}
+
+// Reorder returns the PDF with its pages in the given order, which must
+// list every page exactly once.
+func Reorder(pdf []byte, order ...int) ([]byte, error) {
+	n, err := PageCount(pdf)
+	if err != nil {
+		return nil, err
+	}
+	if len(order) != n {
+		return nil, fmt.Errorf("pdfutil: order lists %d pages, document has %d", len(order), n)
+	}
+	return Extract(pdf, order...)
+}
+
+// Rotate turns the given pages clockwise by degrees, a multiple of 90.
+// All pages are rotated if none are given.
+func Rotate(pdf []byte, degrees int, pages ...int) ([]byte, error) {
+	doc, err := parse(pdf)
+	if err != nil {
+		return nil, err
+	}
+	all := doc.Pages()
+	if len(pages) == 0 {
+		pages = allPages(len(all))
+	}
+	for _, p := range pages {
+		if p < 1 || p > len(all) {
+			return nil, fmt.Errorf("pdfutil: page %d out of range (document has %d pages)", p, len(all))
+		}
+		if err := doc.RotatePage(all[p-1], degrees); err != nil {
+			return nil, fmt.Errorf("pdfutil: %w", err)
+		}
+	}
+	return doc.Bytes()
+}
+
+// Split cuts a PDF into parts. starts are the first pages of the parts
+// after the first one, e.g. Split(pdf, 3, 6) on a 7-page document returns
+// pages 1-2, 3-5 and 6-7.
+func Split(pdf []byte, starts ...int) ([][]byte, error) {
+	doc, err := parse(pdf)
+	if err != nil {
+		return nil, err
+	}
+	all := doc.Pages()
+	n := len(all)
+	starts = append([]int{1}, starts...)
+	if !sort.IntsAreSorted(starts) {
+		return nil, fmt.Errorf("pdfutil: split points must be in ascending order")
+	}
+	var parts [][]byte
+	for i, first := range starts {
+		last := n
+		if i+1 < len(starts) {
+			last = starts[i+1] - 1
+		}
+		if first > last || first > n {
+			return nil, fmt.Errorf("pdfutil: invalid split point %d", first)
+		}
+		part := doc.Clone()
+		if err := part.KeepPages(all[first-1 : last]); err != nil {
+			return nil, fmt.Errorf("pdfutil: %w", err)
+		}
+		b, err := part.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, b)
+	}
+	return parts, nil
+}
+
+// SplitEvery cuts a PDF into parts of n pages; the last part may be
+// shorter.
+func SplitEvery(pdf []byte, n int) ([][]byte, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("pdfutil: invalid part size %d", n)
+	}
+	count, err := PageCount(pdf)
+	if err != nil {
+		return nil, err
+	}
+	var starts []int
+	for p := 1 + n; p <= count; p += n {
+		starts = append(starts, p)
+	}
+	return Split(pdf, starts...)
+}
+
+// RenderPNG rasterizes the given pages, or all pages if none are given,
+// at dpi pixels per inch and returns them as PNG images. Text is only
+// drawn in embedded TrueType and Type 3 fonts; text in other fonts, such
+// as the CFF fonts Chrome embeds for OpenType web fonts, is left out.
+func RenderPNG(pdf []byte, dpi float64, pages ...int) ([][]byte, error) {
+	doc, err := parse(pdf)
+	if err != nil {
+		return nil, err
+	}
+	all := doc.Pages()
+	if len(pages) == 0 {
+		pages = allPages(len(all))
+	}
+	images := make([][]byte, 0, len(pages))
+	for _, p := range pages {
+		if p < 1 || p > len(all) {
+			return nil, fmt.Errorf("pdfutil: page %d out of range (document has %d pages)", p, len(all))
+		}
+		img, err := doc.RenderPage(all[p-1], dpi)
+		if err != nil {
+			return nil, fmt.Errorf("pdfutil: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("pdfutil: %w", err)
+		}
+		images = append(images, buf.Bytes())
+	}
+	return images, nil
+}
+
+// ParseRanges parses page ranges such as "1-5, 8, 11-" for a document
+// with count pages and returns the page numbers. Pages beyond the end of
+// the document are ignored and repeated pages are listed once. An empty
+// string selects all pages.
+func ParseRanges(s string, count int) ([]int, error) {
+	if strings.TrimSpace(s) == "" {
+		return allPages(count), nil
+	}
+	var pages []int
+	seen := map[int]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil || first < 1 {
+			return nil, fmt.Errorf("pdfutil: invalid page range %q", part)
+		}
+		last := first
+		if isRange {
+			if to = strings.TrimSpace(to); to == "" {
+				last = count
+			} else if last, err = strconv.Atoi(to); err != nil || last < first {
+				return nil, fmt.Errorf("pdfutil: invalid page range %q", part)
+			}
+		}
+		for p := first; p <= last && p <= count; p++ {
+			if !seen[p] {
+				seen[p] = true
+				pages = append(pages, p)
+			}
+		}
+	}
+	return pages, nil
+}
+
+func parse(pdf []byte) (*pdfdoc.Document, error) {
+	doc, err := pdfdoc.Parse(pdf)
+	if err != nil {
+		return nil, fmt.Errorf("pdfutil: %w", err)
+	}
+	return doc, nil
+}
+
+func allPages(n int) []int {
+	return pageRange(1, n)
+}
+
+func pageRange(first, last int) []int {
+	pages := make([]int, 0, last-first+1)
+	for p := first; p <= last; p++ {
+		pages = append(pages, p)
+	}
+	return pages
+}
@@ -0,0 +1,250 @@
+package pdfutil
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"reflect"
+	"testing"
+
+	"github.com/yodsakorn-so/ejspdf/internal/pdfdoc"
+)
+
+// samplePDF returns a document whose page i shows "(Page i)", with a
+// bookmark and a named destination per page and, on the first page, a
+// link to the last one.
+func samplePDF(t *testing.T, n int) []byte {
+	t.Helper()
+	d := pdfdoc.New()
+	var pages []pdfdoc.Ref
+	for i := 1; i <= n; i++ {
+		content := pdfdoc.NewStream(nil, []byte(fmt.Sprintf("BT /F1 12 Tf 72 720 Td (Page %d) Tj ET", i)))
+		pages = append(pages, d.Add(pdfdoc.Dict{
+			"Type":     pdfdoc.Name("Page"),
+			"MediaBox": pdfdoc.Array{0, 0, 595, 842},
+			"Contents": d.Add(content),
+		}))
+	}
+	if err := d.SetPages(pages); err != nil {
+		t.Fatal(err)
+	}
+	var items []pdfdoc.OutlineItem
+	dests := pdfdoc.Dict{}
+	for i, p := range pages {
+		items = append(items, pdfdoc.OutlineItem{Title: fmt.Sprintf("Page %d", i+1), Level: 1, Dest: pdfdoc.Destination{Page: i, Y: 800}})
+		dests[pdfdoc.Name(fmt.Sprintf("p%d", i+1))] = pdfdoc.Array{p, pdfdoc.Name("XYZ"), 0, 800, 0}
+	}
+	d.SetOutline(items)
+	d.Catalog()["Dests"] = d.Add(dests)
+	d.Dict(pages[0])["Annots"] = pdfdoc.Array{d.Add(pdfdoc.Dict{
+		"Type":    pdfdoc.Name("Annot"),
+		"Subtype": pdfdoc.Name("Link"),
+		"Rect":    pdfdoc.Array{0, 0, 10, 10},
+		"Dest":    pdfdoc.Name(fmt.Sprintf("p%d", n)),
+	})}
+	b, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// pageLabels returns the "Page i" text of every page.
+func pageLabels(t *testing.T, pdf []byte) []string {
+	t.Helper()
+	d, err := pdfdoc.Parse(pdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, p := range d.Pages() {
+		data, err := d.Resolve(d.Dict(p)["Contents"]).(*pdfdoc.Stream).Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := bytes.IndexByte(data, '(')
+		end := bytes.IndexByte(data, ')')
+		labels = append(labels, string(data[start+1:end]))
+	}
+	return labels
+}
+
+func TestExtract(t *testing.T) {
+	src := samplePDF(t, 5)
+	out, err := Extract(src, 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pageLabels(t, out), []string{"Page 4", "Page 2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pages %v, want %v", got, want)
+	}
+
+	d, err := pdfdoc.Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, it := range d.Outline() {
+		titles = append(titles, fmt.Sprintf("%s@%d", it.Title, it.Dest.Page))
+	}
+	if want := []string{"Page 2@1", "Page 4@0"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("outline %v, want %v", titles, want)
+	}
+	if dests := d.NamedDestinations(); len(dests) != 2 {
+		t.Errorf("got %d named destinations, want 2", len(dests))
+	}
+
+	if _, err := Extract(src, 6); err == nil {
+		t.Error("expected an error for a page out of range")
+	}
+	if _, err := Extract(src, 1, 1); err == nil {
+		t.Error("expected an error for a repeated page")
+	}
+}
+
+func TestExtractDropsDanglingLinks(t *testing.T) {
+	src := samplePDF(t, 3)
+	for _, tc := range []struct {
+		pages []int
+		links int
+	}{
+		{[]int{1, 2}, 0}, // link target (page 3) dropped
+		{[]int{1, 3}, 1},
+	} {
+		out, err := Extract(src, tc.pages...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := pdfdoc.Parse(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(d.Array(d.Dict(d.Pages()[0])["Annots"])); got != tc.links {
+			t.Errorf("pages %v: got %d links, want %d", tc.pages, got, tc.links)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	src := samplePDF(t, 7)
+	parts, err := Split(src, 3, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Page 1", "Page 2"},
+		{"Page 3", "Page 4", "Page 5"},
+		{"Page 6", "Page 7"},
+	}
+	if len(parts) != len(want) {
+		t.Fatalf("got %d parts, want %d", len(parts), len(want))
+	}
+	for i, part := range parts {
+		if got := pageLabels(t, part); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("part %d: pages %v, want %v", i+1, got, want[i])
+		}
+	}
+
+	parts, err = SplitEvery(src, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 {
+		t.Errorf("SplitEvery: got %d parts, want 3", len(parts))
+	}
+
+	if _, err := Split(src, 5, 3); err == nil {
+		t.Error("expected an error for unsorted split points")
+	}
+}
+
+func TestReorderAndRotate(t *testing.T) {
+	src := samplePDF(t, 3)
+	out, err := Reorder(src, 3, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pageLabels(t, out), []string{"Page 3", "Page 1", "Page 2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pages %v, want %v", got, want)
+	}
+	if _, err := Reorder(src, 1, 2); err == nil {
+		t.Error("expected an error for an incomplete order")
+	}
+
+	out, err = Rotate(out, -90, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = Rotate(out, 180)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := pdfdoc.Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []any
+	for _, p := range d.Pages() {
+		got = append(got, d.Dict(p)["Rotate"])
+	}
+	if want := []any{180, 90, 180}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rotation %v, want %v", got, want)
+	}
+	if _, err := Rotate(src, 45); err == nil {
+		t.Error("expected an error for a rotation that is not a multiple of 90")
+	}
+}
+
+func TestRenderPNG(t *testing.T) {
+	src := samplePDF(t, 3)
+	src, err := Rotate(src, 90, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	images, err := RenderPNG(src, 36, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("got %d images, want 2", len(images))
+	}
+	for i, want := range [][2]int{{298, 421}, {421, 298}} {
+		img, err := png.Decode(bytes.NewReader(images[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != want[0] || b.Dy() != want[1] {
+			t.Errorf("image %d is %dx%d, want %dx%d", i, b.Dx(), b.Dy(), want[0], want[1])
+		}
+	}
+	if _, err := RenderPNG(src, 36, 4); err == nil {
+		t.Error("expected an error for a page out of range")
+	}
+}
+
+func TestParseRanges(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []int
+	}{
+		{"", []int{1, 2, 3, 4, 5}},
+		{"2", []int{2}},
+		{"1-2, 4", []int{1, 2, 4}},
+		{"4-", []int{4, 5}},
+		{"3, 1-3, 9", []int{3, 1, 2}},
+	} {
+		got, err := ParseRanges(tc.in, 5)
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %v, want %v", tc.in, got, tc.want)
+		}
+	}
+	for _, bad := range []string{"0", "a", "3-1", "1,,2"} {
+		if _, err := ParseRanges(bad, 5); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package ejspdf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yodsakorn-so/ejspdf/internal/pdf"
+	"github.com/yodsakorn-so/ejspdf/internal/pdfdoc"
+	"github.com/yodsakorn-so/ejspdf/pdfutil"
+)
+
+// postProcess applies the PDF-level options to the output of Chrome and
+// stores the final file in out.PDF. The bytes are kept untouched when no
+// option requires rewriting them.
+func postProcess(out *Document, res *pdf.Result, opt Options) error {
+	if !needsPostProcess(opt) {
+		out.PDF = res.PDF
+		return nil
+	}
+
+	doc, err := pdfdoc.Parse(res.PDF)
+	if err != nil {
+		return err
+	}
+
+	if opt.GenerateOutline && !doc.HasOutline() {
+		doc.SetOutline(outlineItems(res.Headings, doc.NamedDestinations()))
+	}
+
+	if opt.Tagged {
+		if !doc.IsTagged() {
+			out.AccessibilityIssues = append(out.AccessibilityIssues, AccessibilityIssue{
+				Rule:    "untagged",
+				Message: "the browser did not produce a tagged PDF",
+			})
+		}
+		doc.SetAccessibility(res.Lang)
+	}
+
+	// Placeholders are located before their markers are removed.
+	var sign pdfdoc.SignOptions
+	if opt.Sign != nil {
+		sign = signOptions(opt, res.Signature, doc.NamedDestinations())
+	}
+	if opt.Sections || opt.SplitSections {
+		out.Sections = sections(res.Sections, doc.NamedDestinations())
+	}
+	fields := formFields(opt, res.Fields, doc.NamedDestinations())
+
+	// Drop the marker destinations (and their links) injected for inspection.
+	doc.RemoveNamedDestinations(pdf.AnchorPrefix)
+
+	for _, f := range fields {
+		if _, err := doc.AddField(f); err != nil {
+			return err
+		}
+	}
+
+	if opt.Watermark != nil {
+		if err := stampWatermark(doc, res.Overlay, opt.Watermark); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range opt.Attachments {
+		doc.AttachFile(pdfdoc.EmbeddedFile{
+			Name:         a.Name,
+			MIMEType:     a.MIMEType,
+			Data:         a.Data,
+			Description:  a.Description,
+			Relationship: a.Relationship,
+		})
+	}
+
+	// PDF/A conversion comes last as it validates the final content.
+	if opt.PDFA != "" {
+		part := 2
+		if opt.PDFA == PDFA3B {
+			part = 3
+		}
+		if v := doc.ConvertPDFA(part, "B"); len(v) > 0 {
+			return &ConformanceError{Level: opt.PDFA, Violations: v}
+		}
+	}
+
+	// The optimization is measured on its own, before encryption, signing
+	// and linearization.
+	if opt.Optimize != nil {
+		before, err := doc.Bytes()
+		if err != nil {
+			return err
+		}
+		stats := doc.Optimize(pdfdoc.OptimizeOptions{
+			MaxImageDPI: opt.Optimize.MaxImageDPI,
+			JPEGQuality: opt.Optimize.JPEGQuality,
+		})
+		after, err := doc.Bytes()
+		if err != nil {
+			return err
+		}
+		out.Optimization = &OptimizationReport{
+			OriginalSize:        len(before),
+			Size:                len(after),
+			BytesSaved:          len(before) - len(after),
+			DuplicatesRemoved:   stats.DuplicatesRemoved,
+			ImagesDownsampled:   stats.ImagesDownsampled,
+			StreamsRecompressed: stats.StreamsRecompressed,
+		}
+	}
+
+	// Sections are split off before the document is encrypted and signed,
+	// so that each part can be encrypted and signed on its own.
+	var base *pdfdoc.Document
+	if opt.SplitSections && len(out.Sections) > 0 {
+		base = doc.Clone()
+	}
+
+	if out.PDF, err = finish(doc, opt, sign); err != nil {
+		return err
+	}
+	if base == nil {
+		return nil
+	}
+	for i, s := range out.Sections {
+		if out.Sections[i].PDF, err = splitSection(base, s, opt, sign); err != nil {
+			return fmt.Errorf("split section %q: %w", s.ID, err)
+		}
+	}
+	return nil
+}
+
+// finish encrypts and signs the document as requested and serializes it.
+func finish(doc *pdfdoc.Document, opt Options, sign pdfdoc.SignOptions) ([]byte, error) {
+	doc.Linearize = opt.Linearize
+	if opt.Encryption != nil {
+		if err := doc.Encrypt(encryptOptions(opt.Encryption)); err != nil {
+			return nil, err
+		}
+	}
+	// Signing comes last as the signature covers the final bytes.
+	if opt.Sign != nil {
+		return doc.Sign(sign)
+	}
+	return doc.Bytes()
+}
+
+// splitSection returns the pages of section s of the document base,
+// which is left unchanged. The signature widget is kept only if it lies
+// within the section.
+func splitSection(base *pdfdoc.Document, s Section, opt Options, sign pdfdoc.SignOptions) ([]byte, error) {
+	part := base.Clone()
+	if err := part.KeepPages(part.Pages()[s.FirstPage-1 : s.LastPage]); err != nil {
+		return nil, err
+	}
+	// Give each part its own file identifier.
+	delete(part.Trailer, "ID")
+
+	if sign.Page >= s.FirstPage-1 && sign.Page < s.LastPage {
+		sign.Page -= s.FirstPage - 1
+	} else {
+		sign.Page, sign.Rect = 0, [4]float64{}
+	}
+	return finish(part, opt, sign)
+}
+
+// sections resolves the marked section elements to the pages Chrome
+// printed them on. Elements sharing an id are merged into one section;
+// elements that were not printed are skipped.
+func sections(marked []pdf.Section, dests map[string]pdfdoc.Destination) []Section {
+	var out []Section
+	index := map[string]int{}
+	for _, m := range marked {
+		start, ok := dests[m.Start]
+		if !ok {
+			continue
+		}
+		end, ok := dests[m.End]
+		if !ok || end.Page < start.Page {
+			end = start
+		}
+		first, last := start.Page+1, end.Page+1
+		if i, ok := index[m.ID]; ok {
+			out[i].FirstPage = min(out[i].FirstPage, first)
+			out[i].LastPage = max(out[i].LastPage, last)
+			continue
+		}
+		index[m.ID] = len(out)
+		out = append(out, Section{ID: m.ID, FirstPage: first, LastPage: last})
+	}
+	return out
+}
+
+// needsPostProcess reports whether any option requires rewriting the PDF.
+func needsPostProcess(opt Options) bool {
+	return opt.GenerateOutline || opt.Tagged || opt.PDFA != "" || opt.Encryption != nil ||
+		opt.Sign != nil || opt.Watermark != nil || len(opt.Attachments) > 0 ||
+		opt.Sections || opt.SplitSections || opt.Optimize != nil || opt.Linearize || opt.Fields
+}
+
+// outlineItems resolves the headings marked in the page to the positions
+// Chrome printed them at. Headings that were not printed (e.g. hidden
+// ones, or outside PageRanges) are skipped.
+func outlineItems(headings []pdf.Heading, dests map[string]pdfdoc.Destination) []pdfdoc.OutlineItem {
+	items := make([]pdfdoc.OutlineItem, 0, len(headings))
+	for _, h := range headings {
+		dest, ok := dests[h.Anchor]
+		if !ok {
+			continue
+		}
+		items = append(items, pdfdoc.OutlineItem{
+			Title: h.Title,
+			Level: h.Level,
+			Dest:  dest,
+		})
+	}
+	return items
+}
+
+// stampWatermark draws the printed watermark page onto the selected pages.
+func stampWatermark(doc *pdfdoc.Document, overlay []byte, w *Watermark) error {
+	src, err := pdfdoc.Parse(overlay)
+	if err != nil {
+		return fmt.Errorf("parse watermark: %w", err)
+	}
+	if len(src.Pages()) == 0 {
+		return fmt.Errorf("watermark page is empty")
+	}
+	form, err := doc.ImportPage(src, src.Pages()[0])
+	if err != nil {
+		return err
+	}
+	pages := doc.Pages()
+	selected, err := pdfutil.ParseRanges(w.Pages, len(pages))
+	if err != nil {
+		return err
+	}
+	for _, p := range selected {
+		if err := doc.Stamp(pages[p-1], form, w.Behind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signOptions places the signature widget over the printed placeholder
+// element. The signature is invisible if there is none.
+func signOptions(opt Options, ph *pdf.Placeholder, dests map[string]pdfdoc.Destination) pdfdoc.SignOptions {
+	s := opt.Sign
+	so := pdfdoc.SignOptions{
+		Signer:       s.Signer,
+		Certificates: s.Certificates,
+		Name:         s.Name,
+		Reason:       s.Reason,
+		Location:     s.Location,
+		ContactInfo:  s.ContactInfo,
+	}
+	if ph == nil {
+		return so
+	}
+	so.FieldName = ph.Name
+	so.Page, so.Rect, _ = placeholderRect(opt, *ph, dests)
+	return so
+}
+
+// formFields places a form field over each printed form element. Field
+// names are made unique, as PDF merges fields of the same name.
+func formFields(opt Options, found []pdf.Field, dests map[string]pdfdoc.Destination) []pdfdoc.FormField {
+	types := map[string]pdfdoc.FieldType{
+		"text":      pdfdoc.FieldText,
+		"checkbox":  pdfdoc.FieldCheckbox,
+		"signature": pdfdoc.FieldSignature,
+	}
+	var fields []pdfdoc.FormField
+	used := map[string]bool{}
+	for _, f := range found {
+		page, rect, ok := placeholderRect(opt, f.Placeholder, dests)
+		if !ok {
+			continue
+		}
+		// Periods separate the parts of qualified field names.
+		name := strings.ReplaceAll(f.Name, ".", "_")
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s_%d", strings.ReplaceAll(f.Name, ".", "_"), i)
+		}
+		used[name] = true
+		fields = append(fields, pdfdoc.FormField{
+			Name:      name,
+			Type:      types[f.Type],
+			Page:      page,
+			Rect:      rect,
+			Value:     f.Value,
+			Checked:   f.Checked,
+			FontSize:  f.FontSize * pxToPt(opt),
+			MaxLength: f.MaxLength,
+			Multiline: f.Multiline,
+			Password:  f.Password,
+			ReadOnly:  f.ReadOnly,
+			Required:  f.Required,
+		})
+	}
+	return fields
+}
+
+// placeholderRect returns the page and rectangle, in points, where the
+// placeholder element was printed. ok is false if it was not printed.
+func placeholderRect(opt Options, ph pdf.Placeholder, dests map[string]pdfdoc.Destination) (page int, rect [4]float64, ok bool) {
+	dest, ok := dests[ph.Anchor]
+	if !ok {
+		return 0, rect, false
+	}
+	k := pxToPt(opt)
+	left := dest.X - ph.OffsetX*k
+	top := dest.Y + ph.OffsetY*k
+	return dest.Page, [4]float64{left, top - ph.Height*k, left + ph.Width*k, top}, true
+}
+
+// pxToPt returns the size of a CSS pixel in points: 3/4 of a point,
+// before Chrome applies the print scale.
+func pxToPt(opt Options) float64 {
+	if opt.Scale > 0 {
+		return 0.75 * opt.Scale
+	}
+	return 0.75
+}
+
+func encryptOptions(e *Encryption) pdfdoc.EncryptOptions {
+	// Text extraction for accessibility tools is always allowed.
+	perms := pdfdoc.PermExtract
+	if e.AllowPrint {
+		perms |= pdfdoc.PermPrint | pdfdoc.PermPrintHighQuality
+	}
+	if e.AllowCopy {
+		perms |= pdfdoc.PermCopy
+	}
+	if e.AllowModify {
+		perms |= pdfdoc.PermModify | pdfdoc.PermAnnotate | pdfdoc.PermFillForms | pdfdoc.PermAssemble
+	}
+	return pdfdoc.EncryptOptions{
+		UserPassword:  e.UserPassword,
+		OwnerPassword: e.OwnerPassword,
+		KeyBits:       defaultInt(e.KeyLength, 256),
+		Permissions:   perms,
+	}
+}
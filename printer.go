@@ -0,0 +1,267 @@
+package ejspdf
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/yodsakorn-so/ejspdf/browser"
+	"github.com/yodsakorn-so/ejspdf/internal/pdf"
+)
+
+// Printer converts an HTML document into a PDF. The default printer,
+// ChromePrinter, drives a local Chrome; other implementations can call a
+// remote print service, use another engine or record the HTML in tests.
+type Printer interface {
+	Print(ctx context.Context, html string, opt PrintOptions) ([]byte, error)
+}
+
+// PrintOptions are the print settings passed to a Printer, resolved from
+// Options. Lengths are in inches.
+type PrintOptions struct {
+	// PaperWidth and PaperHeight are the paper size in portrait
+	// orientation; Landscape turns the paper.
+	PaperWidth  float64
+	PaperHeight float64
+	Landscape   bool
+
+	MarginTop    float64
+	MarginBottom float64
+	MarginLeft   float64
+	MarginRight  float64
+
+	DisplayHeaderFooter bool
+	HeaderTemplate      string
+	FooterTemplate      string
+
+	// WaitSelector is the CSS selector to wait for before printing, and
+	// WaitDelay an additional delay.
+	WaitSelector string
+	WaitDelay    time.Duration
+
+	// Scale is the scale of the page rendering; zero means 1.
+	Scale float64
+	// PageRanges selects the pages to print, e.g. "1-5, 8". Empty means
+	// all pages.
+	PageRanges       string
+	IgnoreBackground bool
+
+	// GenerateOutline asks for bookmarks and Tagged for a tagged PDF,
+	// when the printer supports them.
+	GenerateOutline bool
+	Tagged          bool
+}
+
+// ChromePrinter prints with Chrome through the DevTools protocol. It is
+// the Printer used when Options.Printer is nil. If the context passed to
+// Print already contains a chromedp session, it is reused.
+//
+// Only ChromePrinter can inspect the printed page, which the Sections,
+// Fields and visible Sign options need.
+type ChromePrinter struct {
+	// ChromePath is the Chrome executable. If empty, Chrome is found or
+	// downloaded automatically.
+	ChromePath string
+	// RemoteURL is the DevTools address of a running Chrome to use
+	// instead, see Options.RemoteURL.
+	RemoteURL string
+
+	// ChromeFlags, ChromeEnv and Sandbox configure the started Chrome,
+	// see Options.
+	ChromeFlags []string
+	ChromeEnv   []string
+	Sandbox     bool
+	// Browser configures the Chromium downloaded when ChromePath is empty,
+	// see Options.Browser.
+	Browser browser.Config
+	// Logger receives the browser messages, see Options.Logger.
+	Logger *slog.Logger
+	// StrictBrowserVersion fails a print when the browser is too old for
+	// a requested option, see Options.StrictBrowserVersion.
+	StrictBrowserVersion bool
+}
+
+// Check reports whether the printer's browser is usable: the browser of
+// the session in ctx, if any (see Start), or a remote browser must answer,
+// and ChromePath, if set, must exist. It suits health and readiness
+// checks.
+func (p *ChromePrinter) Check(ctx context.Context) error {
+	if pdf.HasSession(ctx) {
+		if err := pdf.Ping(ctx); err != nil {
+			return fmt.Errorf("ejspdf: %w", err)
+		}
+		return nil
+	}
+	if p.RemoteURL != "" {
+		if _, err := pdf.CheckRemote(ctx, p.RemoteURL); err != nil {
+			return fmt.Errorf("ejspdf: %w", err)
+		}
+		return nil
+	}
+	if p.ChromePath != "" {
+		if _, err := os.Stat(p.ChromePath); err != nil {
+			return fmt.Errorf("ejspdf: chrome not found: %w", err)
+		}
+	}
+	return nil
+}
+
+// Start starts the printer's local browser and returns a context
+// holding its session. Prints with a context derived from it open a tab
+// in that browser instead of starting one each, which suits servers.
+// The browser outlives ctx, which only bounds finding and starting it;
+// cancel closes it. RemoteURL must be empty.
+func (p *ChromePrinter) Start(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	if p.RemoteURL != "" {
+		return nil, nil, fmt.Errorf("ejspdf: cannot start a remote browser")
+	}
+	browserCtx, cancel, err := pdf.New(p.options(PrintOptions{})).Start(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ejspdf: %w", err)
+	}
+	return browserCtx, cancel, nil
+}
+
+// Version returns the version of the printer's browser, e.g.
+// "HeadlessChrome/120.0.6099.109". A local browser is started to ask it.
+func (p *ChromePrinter) Version(ctx context.Context) (string, error) {
+	if p.RemoteURL != "" {
+		v, err := pdf.CheckRemote(ctx, p.RemoteURL)
+		if err != nil {
+			return "", fmt.Errorf("ejspdf: %w", err)
+		}
+		return v.Browser, nil
+	}
+	v, err := pdf.New(p.options(PrintOptions{})).Version(ctx)
+	if err != nil {
+		return "", fmt.Errorf("ejspdf: %w", err)
+	}
+	return v, nil
+}
+
+// Print implements Printer.
+func (p *ChromePrinter) Print(ctx context.Context, html string, opt PrintOptions) ([]byte, error) {
+	return pdf.New(p.options(opt)).FromHTML(ctx, html)
+}
+
+// options converts opt to the settings of the Chrome renderer.
+func (p *ChromePrinter) options(opt PrintOptions) pdf.Options {
+	inches := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64) + "in"
+	}
+	return pdf.Options{
+		ChromePath:          p.ChromePath,
+		RemoteURL:           p.RemoteURL,
+		Flags:               p.ChromeFlags,
+		Env:                 p.ChromeEnv,
+		Sandbox:             p.Sandbox,
+		Browser:             p.Browser,
+		Logger:              p.Logger,
+		StrictVersion:       p.StrictBrowserVersion,
+		Landscape:           opt.Landscape,
+		PaperWidth:          inches(opt.PaperWidth),
+		PaperHeight:         inches(opt.PaperHeight),
+		MarginTop:           inches(opt.MarginTop),
+		MarginBottom:        inches(opt.MarginBottom),
+		MarginLeft:          inches(opt.MarginLeft),
+		MarginRight:         inches(opt.MarginRight),
+		DisplayHeaderFooter: opt.DisplayHeaderFooter,
+		HeaderTemplate:      opt.HeaderTemplate,
+		FooterTemplate:      opt.FooterTemplate,
+		WaitSelector:        opt.WaitSelector,
+		WaitDelay:           opt.WaitDelay,
+		Scale:               opt.Scale,
+		PageRanges:          opt.PageRanges,
+		IgnoreBackground:    opt.IgnoreBackground,
+		GenerateOutline:     opt.GenerateOutline,
+		Tagged:              opt.Tagged,
+	}
+}
+
+// printOptions resolves the print settings of opt.
+func printOptions(opt Options) (PrintOptions, error) {
+	chrome := pdf.New(chromeOptions(opt))
+	w, h, err := chrome.PaperSize()
+	if err != nil {
+		return PrintOptions{}, err
+	}
+	mt, mb, ml, mr, err := chrome.Margins()
+	if err != nil {
+		return PrintOptions{}, err
+	}
+	return PrintOptions{
+		PaperWidth:          w,
+		PaperHeight:         h,
+		Landscape:           opt.Landscape,
+		MarginTop:           mt,
+		MarginBottom:        mb,
+		MarginLeft:          ml,
+		MarginRight:         mr,
+		DisplayHeaderFooter: opt.DisplayHeaderFooter,
+		HeaderTemplate:      opt.HeaderTemplate,
+		FooterTemplate:      opt.FooterTemplate,
+		WaitSelector:        opt.WaitSelector,
+		WaitDelay:           opt.WaitDelay,
+		Scale:               opt.Scale,
+		PageRanges:          opt.PageRanges,
+		IgnoreBackground:    opt.IgnoreBackground,
+		GenerateOutline:     opt.GenerateOutline,
+		Tagged:              opt.Tagged,
+	}, nil
+}
+
+// printHTML prints html with the printer of opt. ChromePrinter also
+// inspects the page; other printers only return the PDF, and the
+// watermark overlay is printed as a separate document.
+func printHTML(ctx context.Context, html string, opt Options) (*pdf.Result, error) {
+	popt, err := printOptions(opt)
+	if err != nil {
+		return nil, err
+	}
+	printer := opt.Printer
+	if printer == nil {
+		printer = &ChromePrinter{
+			ChromePath:  opt.ChromePath,
+			RemoteURL:   opt.RemoteURL,
+			ChromeFlags: opt.ChromeFlags,
+			ChromeEnv:   opt.ChromeEnv,
+			Sandbox:     opt.Sandbox,
+			Browser:     opt.Browser,
+			Logger:      opt.Logger,
+
+			StrictBrowserVersion: opt.StrictBrowserVersion,
+		}
+	}
+
+	if cp, ok := printer.(*ChromePrinter); ok {
+		copt := cp.options(popt)
+		copt.Signature = opt.Sign != nil
+		copt.Sections = opt.Sections || opt.SplitSections
+		copt.Fields = opt.Fields
+		if opt.Watermark != nil {
+			copt.Overlay = opt.Watermark.html()
+		}
+		return pdf.New(copt).Print(ctx, html)
+	}
+
+	res := &pdf.Result{}
+	if res.PDF, err = printer.Print(ctx, html, popt); err != nil {
+		return nil, err
+	}
+	if opt.Watermark != nil {
+		overlay := PrintOptions{
+			PaperWidth:       popt.PaperWidth,
+			PaperHeight:      popt.PaperHeight,
+			Landscape:        popt.Landscape,
+			PageRanges:       "1",
+			IgnoreBackground: true,
+		}
+		if res.Overlay, err = printer.Print(ctx, opt.Watermark.html(), overlay); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,208 @@
+package ejspdf_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/yodsakorn-so/ejspdf"
+	"github.com/yodsakorn-so/ejspdf/browser"
+	"github.com/yodsakorn-so/ejspdf/internal/pdfdoc"
+	"github.com/yodsakorn-so/ejspdf/pdfutil"
+)
+
+// fakePrinter records the documents it is asked to print and returns a
+// blank page for each.
+type fakePrinter struct {
+	html []string
+	opts []ejspdf.PrintOptions
+	err  error
+}
+
+func (p *fakePrinter) Print(ctx context.Context, html string, opt ejspdf.PrintOptions) ([]byte, error) {
+	p.html = append(p.html, html)
+	p.opts = append(p.opts, opt)
+	if p.err != nil {
+		return nil, p.err
+	}
+	d := pdfdoc.New()
+	page := d.Add(pdfdoc.Dict{
+		"Type":     pdfdoc.Name("Page"),
+		"MediaBox": pdfdoc.Array{0, 0, opt.PaperWidth * 72, opt.PaperHeight * 72},
+		"Contents": d.Add(pdfdoc.NewStream(nil, []byte("0 0 m 10 10 l S"))),
+	})
+	if err := d.SetPages([]pdfdoc.Ref{page}); err != nil {
+		return nil, err
+	}
+	return d.Bytes()
+}
+
+func TestCustomPrinter(t *testing.T) {
+	p := &fakePrinter{}
+	out, err := ejspdf.Render(context.Background(), ejspdf.Options{
+		Template:   `<h1><%= title %></h1>`,
+		Data:       map[string]any{"title": "Invoice"},
+		Printer:    p,
+		PageSize:   "Letter",
+		MarginLeft: "1in",
+		Landscape:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := pdfutil.PageCount(out); err != nil || n != 1 {
+		t.Fatalf("PageCount = %d, %v", n, err)
+	}
+	if len(p.html) != 1 || !strings.Contains(p.html[0], "<h1>Invoice</h1>") {
+		t.Fatalf("printed HTML: %q", p.html)
+	}
+	want := ejspdf.PrintOptions{
+		PaperWidth: 8.5, PaperHeight: 11, Landscape: true,
+		MarginTop: 10 / 25.4, MarginBottom: 10 / 25.4, MarginLeft: 1, MarginRight: 10 / 25.4,
+	}
+	if p.opts[0] != want {
+		t.Errorf("print options %+v, want %+v", p.opts[0], want)
+	}
+}
+
+func TestCustomPrinterWatermark(t *testing.T) {
+	p := &fakePrinter{}
+	_, err := ejspdf.Render(context.Background(), ejspdf.Options{
+		Template:  `<p>Body</p>`,
+		Printer:   p,
+		Watermark: &ejspdf.Watermark{Text: "DRAFT"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.html) != 2 || !strings.Contains(p.html[1], "DRAFT") {
+		t.Fatalf("expected the watermark to be printed separately, got %q", p.html)
+	}
+	if o := p.opts[1]; o.PageRanges != "1" || o.MarginTop != 0 || !o.IgnoreBackground {
+		t.Errorf("overlay print options %+v", o)
+	}
+}
+
+func TestCustomPrinterErrors(t *testing.T) {
+	p := &fakePrinter{err: errors.New("service unavailable")}
+	_, err := ejspdf.Render(context.Background(), ejspdf.Options{Template: `<p>x</p>`, Printer: p})
+	if err == nil || !strings.Contains(err.Error(), "service unavailable") {
+		t.Errorf("expected the printer error, got %v", err)
+	}
+
+	_, err = ejspdf.Render(context.Background(), ejspdf.Options{Template: `<p>x</p>`, Printer: &fakePrinter{}, Fields: true})
+	if err == nil {
+		t.Error("expected an error for form fields without ChromePrinter")
+	}
+
+	_, err = ejspdf.RenderImage(context.Background(), ejspdf.Options{Template: `<p>x</p>`, Printer: &fakePrinter{}}, ejspdf.ImageOptions{})
+	if err == nil || !strings.Contains(err.Error(), "ChromePrinter") {
+		t.Errorf("expected an error for images without ChromePrinter, got %v", err)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	_, err := ejspdf.Render(context.Background(), ejspdf.Options{
+		Template: `<p>x</p>`,
+		Printer:  &fakePrinter{},
+		Logger:   logger,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{`"msg":"rendered template"`, `"msg":"printed pdf"`, `"msg":"post-processed pdf"`} {
+		if !strings.Contains(buf.String(), msg) {
+			t.Errorf("log has no %s:\n%s", msg, buf.String())
+		}
+	}
+}
+
+func TestTemplateFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"invoices/main.ejs":   {Data: []byte(`<%- include('../partials/header.ejs', {title}) %><p>Body</p>`)},
+		"partials/header.ejs": {Data: []byte(`<h1><%= title %></h1>`)},
+	}
+	p := &fakePrinter{}
+	_, err := ejspdf.Render(context.Background(), ejspdf.Options{
+		Template:     string(fsys["invoices/main.ejs"].Data),
+		TemplatePath: "invoices/main.ejs",
+		TemplateFS:   fsys,
+		Data:         map[string]any{"title": "Invoice"},
+		Printer:      p,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(p.html[0], "<h1>Invoice</h1><p>Body</p>") {
+		t.Errorf("printed HTML: %q", p.html[0])
+	}
+
+	// Includes cannot leave the file system.
+	_, err = ejspdf.Render(context.Background(), ejspdf.Options{
+		Template:     `<%- include('../../etc/passwd') %>`,
+		TemplatePath: "invoices/main.ejs",
+		TemplateFS:   fsys,
+		Printer:      &fakePrinter{},
+	})
+	if err == nil {
+		t.Error("include outside the file system succeeded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, valid := range []ejspdf.Options{
+		{Template: "<p></p>", PageSize: "Letter", MarginTop: "1in"},
+		{Template: "<p></p>", Printer: &ejspdf.ChromePrinter{ChromeFlags: []string{"--lang=th"}}, Logger: slog.Default()},
+	} {
+		if err := valid.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v", valid, err)
+		}
+	}
+	for _, opt := range []ejspdf.Options{
+		{},
+		{Template: "x", PDFA: "PDF/A-1a"},
+		{Template: "x", MarginLeft: "ten"},
+		{Template: "x", PaperWidth: "80mm", PaperHeight: "tall"},
+		{Template: "x", Encryption: &ejspdf.Encryption{KeyLength: 40}},
+		{Template: "x", Watermark: &ejspdf.Watermark{Text: "x", Color: "red; background: url(http://example.com/)"}},
+		{Template: "x", Watermark: &ejspdf.Watermark{Text: "x", FontFamily: "x}</style><img src=x>"}},
+		{Template: "x", Printer: &ejspdf.ChromePrinter{}, ChromeFlags: []string{"--lang=th"}},
+		{Template: "x", Printer: &ejspdf.ChromePrinter{}, Browser: browser.Config{Revision: "1"}},
+	} {
+		if err := opt.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", opt)
+		}
+	}
+}
+
+func TestOptimizationReport(t *testing.T) {
+	doc, err := ejspdf.RenderDocument(context.Background(), ejspdf.Options{
+		Template:   `<p>Body</p>`,
+		Printer:    &fakePrinter{},
+		Optimize:   &ejspdf.Optimization{},
+		Encryption: &ejspdf.Encryption{UserPassword: "secret"},
+		Attachments: []ejspdf.Attachment{
+			{Name: "data.txt", Data: bytes.Repeat([]byte("ejspdf "), 1000)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := doc.Optimization
+	if r == nil {
+		t.Fatal("no optimization report")
+	}
+	// Encryption and the attachment do not count as savings or costs.
+	if r.BytesSaved != r.OriginalSize-r.Size || r.BytesSaved < 0 || r.StreamsRecompressed == 0 {
+		t.Errorf("report %+v", r)
+	}
+	if r.Size == len(doc.PDF) {
+		t.Errorf("report size %d includes the encryption", r.Size)
+	}
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yodsakorn-so/ejspdf"
+)
+
+// RenderRequest is the JSON body of POST /render, e.g.
+//
+//	{
+//		"template": "invoices/standard.ejs",
+//		"data": {"customer": "ACME", "total": 1070},
+//		"options": {"pageSize": "A4", "pdfa": "PDF/A-3b"}
+//	}
+//
+// The response is the PDF, or with options.splitSections a
+// multipart/mixed body with one PDF part per section. Errors are JSON
+// objects with an "error" message: 400 for an invalid request, 404 for
+// an unknown template, 422 for PDF/A violations (listed in
+// "violations"), 503 when no render slot freed up in time and 504 when
+// the render timed out.
+type RenderRequest struct {
+	// Template is the path of the template in Server.Templates.
+	Template string `json:"template"`
+	// Data is passed to the template.
+	Data any `json:"data,omitempty"`
+	// Filename is the file name given in the Content-Disposition of the
+	// response. Default is the template name with a .pdf extension.
+	Filename string `json:"filename,omitempty"`
+	// Options override the Server.Options of this render.
+	Options RequestOptions `json:"options"`
+}
+
+// RequestOptions are the render options a request may set, see
+// ejspdf.Options. Zero values keep the server's options. Options that
+// reach the server itself, such as the browser settings or the signing
+// key, can only be set in Server.Options.
+type RequestOptions struct {
+	PageSize     string `json:"pageSize,omitempty"`
+	Landscape    bool   `json:"landscape,omitempty"`
+	PaperWidth   string `json:"paperWidth,omitempty"`
+	PaperHeight  string `json:"paperHeight,omitempty"`
+	MarginTop    string `json:"marginTop,omitempty"`
+	MarginBottom string `json:"marginBottom,omitempty"`
+	MarginLeft   string `json:"marginLeft,omitempty"`
+	MarginRight  string `json:"marginRight,omitempty"`
+
+	DisplayHeaderFooter bool   `json:"displayHeaderFooter,omitempty"`
+	HeaderTemplate      string `json:"headerTemplate,omitempty"`
+	FooterTemplate      string `json:"footerTemplate,omitempty"`
+
+	WaitSelector string `json:"waitSelector,omitempty"`
+	// WaitDelay is a duration such as "500ms".
+	WaitDelay        string  `json:"waitDelay,omitempty"`
+	Scale            float64 `json:"scale,omitempty"`
+	PageRanges       string  `json:"pageRanges,omitempty"`
+	IgnoreBackground bool    `json:"ignoreBackground,omitempty"`
+
+	GenerateOutline bool   `json:"generateOutline,omitempty"`
+	Tagged          bool   `json:"tagged,omitempty"`
+	PDFA            string `json:"pdfa,omitempty"`
+	Fields          bool   `json:"fields,omitempty"`
+	SplitSections   bool   `json:"splitSections,omitempty"`
+	Linearize       bool   `json:"linearize,omitempty"`
+
+	Encryption *Encryption `json:"encryption,omitempty"`
+	Watermark  *Watermark  `json:"watermark,omitempty"`
+	Optimize   *Optimize   `json:"optimize,omitempty"`
+	// Attachments are embedded in the PDF, after those of the server.
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Encryption is the JSON form of ejspdf.Encryption.
+type Encryption struct {
+	UserPassword  string `json:"userPassword,omitempty"`
+	OwnerPassword string `json:"ownerPassword,omitempty"`
+	KeyLength     int    `json:"keyLength,omitempty"`
+	AllowPrint    bool   `json:"allowPrint,omitempty"`
+	AllowCopy     bool   `json:"allowCopy,omitempty"`
+	AllowModify   bool   `json:"allowModify,omitempty"`
+}
+
+// Watermark is the JSON form of ejspdf.Watermark. Image is base64
+// encoded.
+type Watermark struct {
+	Text       string  `json:"text,omitempty"`
+	Image      []byte  `json:"image,omitempty"`
+	FontSize   string  `json:"fontSize,omitempty"`
+	FontFamily string  `json:"fontFamily,omitempty"`
+	Color      string  `json:"color,omitempty"`
+	Width      string  `json:"width,omitempty"`
+	Opacity    float64 `json:"opacity,omitempty"`
+	Rotation   float64 `json:"rotation,omitempty"`
+	Position   string  `json:"position,omitempty"`
+	Pages      string  `json:"pages,omitempty"`
+	Behind     bool    `json:"behind,omitempty"`
+}
+
+// Optimize is the JSON form of ejspdf.Optimization.
+type Optimize struct {
+	MaxImageDPI float64 `json:"maxImageDPI,omitempty"`
+	JPEGQuality int     `json:"jpegQuality,omitempty"`
+}
+
+// Attachment is the JSON form of ejspdf.Attachment. Data is base64
+// encoded.
+type Attachment struct {
+	Name         string `json:"name"`
+	MIMEType     string `json:"mimeType,omitempty"`
+	Data         []byte `json:"data"`
+	Description  string `json:"description,omitempty"`
+	Relationship string `json:"relationship,omitempty"`
+}
+
+// apply overrides the options of opt set in o.
+func (o *RequestOptions) apply(opt *ejspdf.Options) error {
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&opt.PageSize, o.PageSize)
+	set(&opt.PaperWidth, o.PaperWidth)
+	set(&opt.PaperHeight, o.PaperHeight)
+	set(&opt.MarginTop, o.MarginTop)
+	set(&opt.MarginBottom, o.MarginBottom)
+	set(&opt.MarginLeft, o.MarginLeft)
+	set(&opt.MarginRight, o.MarginRight)
+	set(&opt.HeaderTemplate, o.HeaderTemplate)
+	set(&opt.FooterTemplate, o.FooterTemplate)
+	set(&opt.WaitSelector, o.WaitSelector)
+	set(&opt.PageRanges, o.PageRanges)
+	set(&opt.PDFA, o.PDFA)
+
+	opt.Landscape = opt.Landscape || o.Landscape
+	opt.DisplayHeaderFooter = opt.DisplayHeaderFooter || o.DisplayHeaderFooter
+	opt.IgnoreBackground = opt.IgnoreBackground || o.IgnoreBackground
+	opt.GenerateOutline = opt.GenerateOutline || o.GenerateOutline
+	opt.Tagged = opt.Tagged || o.Tagged
+	opt.Fields = opt.Fields || o.Fields
+	opt.SplitSections = opt.SplitSections || o.SplitSections
+	opt.Linearize = opt.Linearize || o.Linearize
+
+	if o.WaitDelay != "" {
+		d, err := time.ParseDuration(o.WaitDelay)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid waitDelay %q", o.WaitDelay)
+		}
+		opt.WaitDelay = d
+	}
+	if o.Scale != 0 {
+		opt.Scale = o.Scale
+	}
+
+	if e := o.Encryption; e != nil {
+		enc := ejspdf.Encryption(*e)
+		opt.Encryption = &enc
+	}
+	if w := o.Watermark; w != nil {
+		wm := ejspdf.Watermark(*w)
+		opt.Watermark = &wm
+	}
+	if p := o.Optimize; p != nil {
+		opti := ejspdf.Optimization(*p)
+		opt.Optimize = &opti
+	}
+	if len(o.Attachments) > 0 {
+		attachments := append([]ejspdf.Attachment(nil), opt.Attachments...)
+		for _, a := range o.Attachments {
+			attachments = append(attachments, ejspdf.Attachment(a))
+		}
+		opt.Attachments = attachments
+	}
+	return nil
+}
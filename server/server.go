@@ -0,0 +1,444 @@
+// Package server renders ejspdf templates over HTTP.
+//
+// A Server is an http.Handler with these endpoints:
+//
+//	POST /render   render a template of the registry, see RenderRequest
+//	GET  /healthz  report that the server is running
+//	GET  /readyz   report whether the browser is usable
+//
+// For example:
+//
+//	srv := &server.Server{
+//		Templates:     os.DirFS("templates"),
+//		MaxConcurrent: 4,
+//	}
+//	defer srv.Close()
+//	http.ListenAndServe(":8080", srv)
+//
+// Renders share one local browser, started by the first request and
+// opening a tab per render, unless Options sets a RemoteURL or a Printer
+// other than ChromePrinter.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yodsakorn-so/ejspdf"
+)
+
+// runRender and checkBrowser render a document and check the shared
+// browser; tests replace them.
+var (
+	runRender    = ejspdf.RenderDocument
+	checkBrowser = (*ejspdf.ChromePrinter).Check
+)
+
+// Defaults of the Server limits.
+const (
+	DefaultTimeout         = time.Minute
+	DefaultMaxRequestBytes = 10 << 20
+)
+
+// Server serves the rendering endpoints. Its fields must not be changed
+// once it has served a request.
+type Server struct {
+	// Templates is the template registry. The template of a request is a
+	// slash-separated path in it, e.g. "invoices/standard.ejs", and the
+	// includes of a template are read from it too. Use os.DirFS for a
+	// directory or an embed.FS.
+	Templates fs.FS
+
+	// Options are the base options of every render, e.g. the Printer,
+	// ChromePath or RemoteURL, the Logger and the Sign settings. The
+	// options of a request override its page and document settings.
+	Options ejspdf.Options
+
+	// MaxConcurrent is the number of renders run at once. Further
+	// requests wait for a free slot until their timeout. Default is the
+	// number of CPUs.
+	MaxConcurrent int
+	// Timeout limits a request, including the wait for a slot. Default is
+	// DefaultTimeout.
+	Timeout time.Duration
+	// MaxRequestBytes limits the size of a request body. Default is
+	// DefaultMaxRequestBytes.
+	MaxRequestBytes int64
+
+	once     sync.Once
+	mux      *http.ServeMux
+	slots    chan struct{}
+	inFlight atomic.Int64
+
+	// browser guards session, the context of the shared browser, and
+	// closeBrowser, which closes it. It is a channel so that waiting for
+	// the browser to start can be canceled.
+	browser      chan struct{}
+	session      context.Context
+	closeBrowser context.CancelFunc
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.once.Do(s.init)
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) init() {
+	n := s.MaxConcurrent
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	s.slots = make(chan struct{}, n)
+	s.browser = make(chan struct{}, 1)
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("POST /render", s.render)
+	s.mux.HandleFunc("GET /healthz", s.health)
+	s.mux.HandleFunc("GET /readyz", s.ready)
+}
+
+func (s *Server) logger() *slog.Logger {
+	if s.Options.Logger == nil {
+		return slog.Default()
+	}
+	return s.Options.Logger
+}
+
+func (s *Server) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return s.Timeout
+}
+
+// render handles POST /render.
+func (s *Server) render(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout())
+	defer cancel()
+
+	limit := s.MaxRequestBytes
+	if limit <= 0 {
+		limit = DefaultMaxRequestBytes
+	}
+	var req RenderRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", limit))
+			return
+		}
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+
+	opt, status, err := s.options(&req)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+
+	// Wait for a render slot.
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusServiceUnavailable, errors.New("server busy, try again later"))
+		return
+	}
+	s.inFlight.Add(1)
+	doc, err := s.renderDocument(ctx, opt)
+	s.inFlight.Add(-1)
+	<-s.slots
+
+	logger := s.logger().With("template", req.Template, "duration", time.Since(start))
+	if err != nil {
+		var ce *ejspdf.ConformanceError
+		switch {
+		case errors.As(err, &ce):
+			writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: err.Error(), Violations: ce.Violations})
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			writeError(w, http.StatusGatewayTimeout, fmt.Errorf("render timed out after %s", s.timeout()))
+		case r.Context().Err() != nil:
+			// The client is gone.
+			logger.Debug("render canceled", "error", err)
+			return
+		default:
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		logger.Warn("render failed", "error", err)
+		return
+	}
+	logger.Info("rendered", "bytes", len(doc.PDF))
+
+	if doc.BrowserVersion != "" {
+		w.Header().Set("X-Ejspdf-Browser-Version", doc.BrowserVersion)
+	}
+	if opt.SplitSections {
+		writeSections(w, doc.Sections)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Length", strconv.Itoa(len(doc.PDF)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": req.filename()}))
+	w.WriteHeader(http.StatusOK)
+	w.Write(doc.PDF)
+}
+
+// renderDocument renders opt in a tab of the shared browser, if there is
+// one.
+func (s *Server) renderDocument(ctx context.Context, opt ejspdf.Options) (*ejspdf.Document, error) {
+	if s.printer() == nil {
+		return runRender(ctx, opt)
+	}
+	session, err := s.browserSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The tab is closed when the request ends.
+	tab, cancel := context.WithCancel(session)
+	defer cancel()
+	defer context.AfterFunc(ctx, cancel)()
+	return runRender(tab, opt)
+}
+
+// printer returns the ChromePrinter of the shared browser, or nil if the
+// renders do not share one.
+func (s *Server) printer() *ejspdf.ChromePrinter {
+	o := s.Options
+	switch p := o.Printer.(type) {
+	case nil:
+		if o.RemoteURL != "" {
+			return nil
+		}
+		return &ejspdf.ChromePrinter{
+			ChromePath:  o.ChromePath,
+			ChromeFlags: o.ChromeFlags,
+			ChromeEnv:   o.ChromeEnv,
+			Sandbox:     o.Sandbox,
+			Browser:     o.Browser,
+			Logger:      o.Logger,
+
+			StrictBrowserVersion: o.StrictBrowserVersion,
+		}
+	case *ejspdf.ChromePrinter:
+		if p.RemoteURL != "" {
+			return nil
+		}
+		return p
+	default:
+		return nil
+	}
+}
+
+// browserSession returns the session of the shared browser, starting it
+// if it is not running.
+func (s *Server) browserSession(ctx context.Context) (context.Context, error) {
+	select {
+	case s.browser <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-s.browser }()
+	if s.session != nil && s.session.Err() == nil {
+		return s.session, nil
+	}
+	session, cancel, err := s.printer().Start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.logger().Info("browser started")
+	s.session, s.closeBrowser = session, cancel
+	return session, nil
+}
+
+// resetBrowser closes the shared browser; the next render starts a new
+// one.
+func (s *Server) resetBrowser() {
+	s.browser <- struct{}{}
+	defer func() { <-s.browser }()
+	if s.closeBrowser != nil {
+		s.closeBrowser()
+	}
+	s.session, s.closeBrowser = nil, nil
+}
+
+// Close closes the shared browser. Renders still running fail; call it
+// once the HTTP server has shut down.
+func (s *Server) Close() error {
+	s.once.Do(s.init)
+	s.resetBrowser()
+	return nil
+}
+
+// options returns the render options of req, or the status and error of
+// an invalid request.
+func (s *Server) options(req *RenderRequest) (ejspdf.Options, int, error) {
+	if s.Templates == nil {
+		return ejspdf.Options{}, http.StatusServiceUnavailable, errors.New("no template registry")
+	}
+	if req.Template == "" {
+		return ejspdf.Options{}, http.StatusBadRequest, errors.New("template is required")
+	}
+	if !fs.ValidPath(req.Template) || req.Template == "." {
+		return ejspdf.Options{}, http.StatusBadRequest, fmt.Errorf("invalid template name %q", req.Template)
+	}
+	tpl, err := fs.ReadFile(s.Templates, req.Template)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ejspdf.Options{}, http.StatusNotFound, fmt.Errorf("template %q not found", req.Template)
+	}
+	if err != nil {
+		return ejspdf.Options{}, http.StatusInternalServerError, fmt.Errorf("read template: %w", err)
+	}
+
+	opt := s.Options
+	opt.Template = string(tpl)
+	opt.TemplatePath = req.Template
+	opt.TemplateFS = s.Templates
+	opt.Data = req.Data
+	if err := req.Options.apply(&opt); err != nil {
+		return ejspdf.Options{}, http.StatusBadRequest, err
+	}
+	if err := opt.Validate(); err != nil {
+		return ejspdf.Options{}, http.StatusBadRequest, err
+	}
+	return opt, 0, nil
+}
+
+// writeSections streams the sections of a split render as a
+// multipart/mixed response, one PDF part per section, flushing each part
+// as it is written.
+func writeSections(w http.ResponseWriter, sections []ejspdf.Section) {
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	for _, sec := range sections {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", "application/pdf")
+		h.Set("Content-Length", strconv.Itoa(len(sec.PDF)))
+		h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": sec.ID + ".pdf"}))
+		h.Set("X-Ejspdf-Section", sec.ID)
+		h.Set("X-Ejspdf-Pages", fmt.Sprintf("%d-%d", sec.FirstPage, sec.LastPage))
+		part, err := mw.CreatePart(h)
+		if err != nil {
+			return
+		}
+		if _, err := part.Write(sec.PDF); err != nil {
+			return
+		}
+		rc.Flush()
+	}
+	mw.Close()
+}
+
+// health handles GET /healthz. It only reports that the server runs.
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// ready handles GET /readyz. The server is ready when it has a template
+// registry and its printer's browser is usable, see ChromePrinter.Check.
+// The shared browser is started if it is not running, and restarted by
+// the next render if it does not answer. A custom Printer is checked if
+// it has a Check(context.Context) error method.
+func (s *Server) ready(w http.ResponseWriter, r *http.Request) {
+	resp := statusResponse{
+		Status:        "ok",
+		InFlight:      int(s.inFlight.Load()),
+		MaxConcurrent: cap(s.slots),
+	}
+	err := s.check(r.Context())
+	if err != nil {
+		resp.Status, resp.Error = "unavailable", err.Error()
+		writeJSON(w, http.StatusServiceUnavailable, resp)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// check reports whether renders can succeed.
+func (s *Server) check(ctx context.Context) error {
+	if s.Templates == nil {
+		return errors.New("no template registry")
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if p := s.printer(); p != nil {
+		session, err := s.browserSession(ctx)
+		if err != nil {
+			return err
+		}
+		ping, cancel := context.WithCancel(session)
+		defer cancel()
+		defer context.AfterFunc(ctx, cancel)()
+		if err := checkBrowser(p, ping); err != nil {
+			// A probe that was canceled or timed out says nothing about
+			// the browser, whose renders would fail with it.
+			if ctx.Err() == nil {
+				s.resetBrowser()
+			}
+			return err
+		}
+		return nil
+	}
+	p := s.Options.Printer
+	if p == nil {
+		p = &ejspdf.ChromePrinter{RemoteURL: s.Options.RemoteURL}
+	}
+	if c, ok := p.(interface{ Check(context.Context) error }); ok {
+		return c.Check(ctx)
+	}
+	return nil
+}
+
+// errorResponse is the JSON body of an error response.
+type errorResponse struct {
+	Error string `json:"error"`
+	// Violations lists the PDF/A violations of a ConformanceError.
+	Violations []string `json:"violations,omitempty"`
+}
+
+// statusResponse is the JSON body of the health and readiness endpoints.
+type statusResponse struct {
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+	InFlight      int    `json:"inFlight,omitempty"`
+	MaxConcurrent int    `json:"maxConcurrent,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// filename returns the file name of the rendered PDF.
+func (req *RenderRequest) filename() string {
+	if req.Filename != "" {
+		return req.Filename
+	}
+	name := path.Base(req.Template)
+	return strings.TrimSuffix(name, path.Ext(name)) + ".pdf"
+}
@@ -0,0 +1,329 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/yodsakorn-so/ejspdf"
+	"github.com/yodsakorn-so/ejspdf/internal/pdfdoc"
+)
+
+// fakePrinter records the documents it prints and returns a blank page
+// for each. If block is set, printing waits for it to be closed or for
+// the context to end.
+type fakePrinter struct {
+	mu    sync.Mutex
+	html  []string
+	opts  []ejspdf.PrintOptions
+	err   error
+	block chan struct{}
+	check error
+}
+
+func (p *fakePrinter) Print(ctx context.Context, html string, opt ejspdf.PrintOptions) ([]byte, error) {
+	p.mu.Lock()
+	p.html = append(p.html, html)
+	p.opts = append(p.opts, opt)
+	p.mu.Unlock()
+	if p.block != nil {
+		select {
+		case <-p.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	d := pdfdoc.New()
+	page := d.Add(pdfdoc.Dict{
+		"Type":     pdfdoc.Name("Page"),
+		"MediaBox": pdfdoc.Array{0, 0, opt.PaperWidth * 72, opt.PaperHeight * 72},
+	})
+	if err := d.SetPages([]pdfdoc.Ref{page}); err != nil {
+		return nil, err
+	}
+	return d.Bytes()
+}
+
+func (p *fakePrinter) Check(ctx context.Context) error { return p.check }
+
+var templates = fstest.MapFS{
+	"invoices/standard.ejs": {Data: []byte(`<%- include('../partials/header.ejs') %><p><%= customer %></p>`)},
+	"partials/header.ejs":   {Data: []byte(`<h1>Invoice</h1>`)},
+}
+
+func post(t *testing.T, h http.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/render", strings.NewReader(body)))
+	return w
+}
+
+func TestRender(t *testing.T) {
+	p := &fakePrinter{}
+	srv := &Server{Templates: templates, Options: ejspdf.Options{Printer: p, PageSize: "Letter"}}
+
+	w := post(t, srv, `{"template": "invoices/standard.ejs", "data": {"customer": "ACME"}, "options": {"landscape": true, "marginTop": "1in"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/pdf" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `inline; filename=standard.pdf` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	if !strings.HasPrefix(w.Body.String(), "%PDF-") {
+		t.Errorf("body is not a PDF: %.20q", w.Body)
+	}
+	if !strings.Contains(p.html[0], "<h1>Invoice</h1><p>ACME</p>") {
+		t.Errorf("printed HTML: %q", p.html[0])
+	}
+	if o := p.opts[0]; !o.Landscape || o.PaperWidth != 8.5 || o.MarginTop != 1 {
+		t.Errorf("print options %+v", o)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	srv := &Server{
+		Templates:       templates,
+		Options:         ejspdf.Options{Printer: &fakePrinter{err: errors.New("printer on fire")}},
+		MaxRequestBytes: 1 << 10,
+	}
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"malformed", `{"template":`, http.StatusBadRequest},
+		{"unknown field", `{"template": "invoices/standard.ejs", "colour": "red"}`, http.StatusBadRequest},
+		{"no template", `{"data": {}}`, http.StatusBadRequest},
+		{"outside registry", `{"template": "../secrets.ejs"}`, http.StatusBadRequest},
+		{"unknown template", `{"template": "invoices/missing.ejs"}`, http.StatusNotFound},
+		{"invalid option", `{"template": "invoices/standard.ejs", "options": {"pdfa": "PDF/A-1a"}}`, http.StatusBadRequest},
+		{"invalid delay", `{"template": "invoices/standard.ejs", "options": {"waitDelay": "soon"}}`, http.StatusBadRequest},
+		{"watermark css", `{"template": "invoices/standard.ejs", "options": {"watermark": {"text": "DRAFT", "fontFamily": "x}</style><img src=http://169.254.169.254/>"}}}`, http.StatusBadRequest},
+		{"too large", `{"template": "invoices/standard.ejs", "data": "` + strings.Repeat("x", 2<<10) + `"}`, http.StatusRequestEntityTooLarge},
+		{"render failed", `{"template": "invoices/standard.ejs", "data": {"customer": "ACME"}}`, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(t, srv, tt.body)
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			var resp errorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+				t.Errorf("error body %q: %v", w.Body, err)
+			}
+		})
+	}
+
+	// Only POST is served.
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/render", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /render: status %d", w.Code)
+	}
+}
+
+func TestRenderLimits(t *testing.T) {
+	p := &fakePrinter{block: make(chan struct{})}
+	srv := &Server{
+		Templates:     templates,
+		Options:       ejspdf.Options{Printer: p},
+		MaxConcurrent: 1,
+		Timeout:       100 * time.Millisecond,
+	}
+	body := `{"template": "invoices/standard.ejs", "data": {"customer": "ACME"}}`
+
+	// A render that does not finish in time.
+	if w := post(t, srv, body); w.Code != http.StatusGatewayTimeout {
+		t.Errorf("slow render: status %d: %s", w.Code, w.Body)
+	}
+
+	// A request finding no free slot in time.
+	srv = &Server{Templates: templates, Options: srv.Options, MaxConcurrent: 1}
+	done := make(chan int)
+	go func() { done <- post(t, srv, body).Code }()
+	for srv.inFlight.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/render", strings.NewReader(body)).WithContext(ctx))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("busy: status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	close(p.block)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("queued render: status %d", code)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	p := &fakePrinter{}
+	srv := &Server{Templates: templates, Options: ejspdf.Options{Printer: p}, MaxConcurrent: 3}
+	get := func(path string) (int, statusResponse) {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var resp statusResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		return w.Code, resp
+	}
+
+	if code, resp := get("/healthz"); code != http.StatusOK || resp.Status != "ok" {
+		t.Errorf("healthz: %d %+v", code, resp)
+	}
+	if code, resp := get("/readyz"); code != http.StatusOK || resp.MaxConcurrent != 3 {
+		t.Errorf("readyz: %d %+v", code, resp)
+	}
+	p.check = errors.New("browser unreachable")
+	if code, resp := get("/readyz"); code != http.StatusServiceUnavailable || resp.Error != "browser unreachable" {
+		t.Errorf("readyz with a failed check: %d %+v", code, resp)
+	}
+	// Liveness does not depend on the browser.
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Errorf("healthz with a failed check: %d", code)
+	}
+}
+
+func TestWriteSections(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeSections(w, []ejspdf.Section{
+		{ID: "cust-1", FirstPage: 1, LastPage: 2, PDF: []byte("%PDF-1")},
+		{ID: "cust-2", FirstPage: 3, LastPage: 3, PDF: []byte("%PDF-2")},
+	})
+	mt, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil || mt != "multipart/mixed" {
+		t.Fatalf("Content-Type %q: %v", w.Header().Get("Content-Type"), err)
+	}
+	mr := multipart.NewReader(w.Body, params["boundary"])
+	var got []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(part)
+		got = append(got, part.FileName()+" "+part.Header.Get("X-Ejspdf-Pages")+" "+string(b))
+	}
+	want := []string{"cust-1.pdf 1-2 %PDF-1", "cust-2.pdf 3-3 %PDF-2"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("parts %q, want %q", got, want)
+	}
+}
+
+func TestSharedBrowser(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		opt    ejspdf.Options
+		shared bool
+	}{
+		{"default", ejspdf.Options{}, true},
+		{"chrome printer", ejspdf.Options{Printer: &ejspdf.ChromePrinter{ChromePath: "chrome"}}, true},
+		{"remote", ejspdf.Options{RemoteURL: "ws://chrome:9222"}, false},
+		{"remote printer", ejspdf.Options{Printer: &ejspdf.ChromePrinter{RemoteURL: "ws://chrome:9222"}}, false},
+		{"custom printer", ejspdf.Options{Printer: &fakePrinter{}}, false},
+	} {
+		srv := &Server{Options: tt.opt}
+		if got := srv.printer() != nil; got != tt.shared {
+			t.Errorf("%s: shared browser %v, want %v", tt.name, got, tt.shared)
+		}
+	}
+
+	srv := &Server{Options: ejspdf.Options{StrictBrowserVersion: true}}
+	if p := srv.printer(); p == nil || !p.StrictBrowserVersion {
+		t.Errorf("shared printer %+v is not strict", p)
+	}
+
+	// A browser that does not start makes the server unready and fails
+	// renders.
+	chrome := filepath.Join(t.TempDir(), "chrome")
+	if err := os.WriteFile(chrome, []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	srv = &Server{Templates: templates, Options: ejspdf.Options{ChromePath: chrome}}
+	defer srv.Close()
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "start chrome") {
+		t.Errorf("readyz: %d %s", w.Code, w.Body)
+	}
+	if w := post(t, srv, `{"template": "invoices/standard.ejs"}`); w.Code != http.StatusInternalServerError {
+		t.Errorf("render: %d %s", w.Code, w.Body)
+	}
+	if srv.session != nil {
+		t.Error("session kept for a browser that did not start")
+	}
+}
+
+func TestReadyCanceledDuringRender(t *testing.T) {
+	release := make(chan struct{})
+	rendering := make(chan struct{})
+	oldRender, oldCheck := runRender, checkBrowser
+	t.Cleanup(func() { runRender, checkBrowser = oldRender, oldCheck })
+	runRender = func(ctx context.Context, opt ejspdf.Options) (*ejspdf.Document, error) {
+		close(rendering)
+		select {
+		case <-release:
+			return &ejspdf.Document{PDF: []byte("%PDF-1.7")}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	// The probe takes as long as the client lets it.
+	checkBrowser = func(p *ejspdf.ChromePrinter, ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	srv := &Server{Templates: templates}
+	srv.once.Do(srv.init)
+	session, closeBrowser := context.WithCancel(context.Background())
+	srv.session, srv.closeBrowser = session, closeBrowser
+	defer srv.Close()
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post(t, srv, `{"template": "invoices/standard.ejs"}`) }()
+	<-rendering
+
+	ctx, cancel := context.WithCancel(context.Background())
+	probe := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil).WithContext(ctx))
+		probe <- w.Code
+	}()
+	cancel()
+	if code := <-probe; code != http.StatusServiceUnavailable {
+		t.Errorf("canceled readyz: status %d", code)
+	}
+	if session.Err() != nil {
+		t.Fatal("canceled probe closed the browser")
+	}
+	close(release)
+	if w := <-done; w.Code != http.StatusOK {
+		t.Errorf("render: status %d %s", w.Code, w.Body)
+	}
+}
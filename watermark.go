@@ -0,0 +1,132 @@
+package ejspdf
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/yodsakorn-so/ejspdf/pdfutil"
+)
+
+// Watermark is a text or image stamped onto the rendered pages, e.g.
+// "DRAFT" or a "PAID" stamp. It is drawn on top of the page content
+// without changing the layout of the template.
+type Watermark struct {
+	// Text is the watermark text.
+	Text string
+	// Image is a PNG, JPEG, GIF, WebP or SVG image used instead of Text.
+	Image []byte
+
+	// FontSize is the CSS font size of Text. Default is "96px".
+	FontSize string
+	// FontFamily is the CSS font family of Text, with unquoted family
+	// names, e.g. "Noto Sans Thai, sans-serif". Default is "sans-serif".
+	FontFamily string
+	// Color is the CSS color of Text. Default is "#888".
+	Color string
+	// Width is the CSS width of Image (e.g. "50%", "80mm"). Default is the
+	// natural size of the image.
+	Width string
+
+	// Opacity ranges from 0 (invisible) to 1. Default is 0.3.
+	Opacity float64
+	// Rotation is the clockwise rotation in degrees, e.g. -45 for a
+	// diagonal watermark.
+	Rotation float64
+	// Position is one of "center" (default), "top", "bottom", "left",
+	// "right", "top-left", "top-right", "bottom-left" or "bottom-right".
+	Position string
+
+	// Pages selects the pages to stamp, e.g. "1", "2-4, 7" or "3-".
+	// Empty means all pages.
+	Pages string
+	// Behind draws the watermark under the page content instead of over
+	// it. Content with an opaque background hides it.
+	Behind bool
+}
+
+// watermarkPositions maps Position to the CSS flex alignment
+// (justify-content, align-items) of the watermark on the page.
+var watermarkPositions = map[string][2]string{
+	"":             {"center", "center"},
+	"center":       {"center", "center"},
+	"top":          {"center", "flex-start"},
+	"bottom":       {"center", "flex-end"},
+	"left":         {"flex-start", "center"},
+	"right":        {"flex-end", "center"},
+	"top-left":     {"flex-start", "flex-start"},
+	"top-right":    {"flex-end", "flex-start"},
+	"bottom-left":  {"flex-start", "flex-end"},
+	"bottom-right": {"flex-end", "flex-end"},
+}
+
+func (w *Watermark) validate() error {
+	if w.Text == "" && len(w.Image) == 0 {
+		return fmt.Errorf("ejspdf: watermark requires a text or an image")
+	}
+	if _, ok := watermarkPositions[w.Position]; !ok {
+		return fmt.Errorf("ejspdf: unsupported watermark position %q", w.Position)
+	}
+	if w.Opacity < 0 || w.Opacity > 1 {
+		return fmt.Errorf("ejspdf: watermark opacity must be between 0 and 1")
+	}
+	if _, err := pdfutil.ParseRanges(w.Pages, 0); err != nil {
+		return fmt.Errorf("ejspdf: invalid watermark pages %q", w.Pages)
+	}
+	// The CSS values are written into the style sheet of the watermark
+	// page as they are, so they must not end the declaration or the
+	// style element.
+	for _, v := range []struct{ name, value string }{
+		{"font size", w.FontSize},
+		{"font family", w.FontFamily},
+		{"color", w.Color},
+		{"width", w.Width},
+	} {
+		if strings.ContainsAny(v.value, "<>{};\"'\\") || strings.ContainsFunc(v.value, unicode.IsControl) {
+			return fmt.Errorf("ejspdf: invalid watermark %s %q", v.name, v.value)
+		}
+	}
+	return nil
+}
+
+// html returns the page printed by Chrome to produce the watermark.
+func (w *Watermark) html() string {
+	pos := watermarkPositions[w.Position]
+	opacity := w.Opacity
+	if opacity == 0 {
+		opacity = 0.3
+	}
+
+	var mark string
+	if len(w.Image) > 0 {
+		mime := http.DetectContentType(w.Image)
+		if strings.HasPrefix(mime, "text/") {
+			mime = "image/svg+xml"
+		}
+		style := ""
+		if w.Width != "" {
+			style = fmt.Sprintf(` style="width:%s"`, html.EscapeString(w.Width))
+		}
+		mark = fmt.Sprintf(`<img class="mark" src="data:%s;base64,%s"%s>`, mime, base64.StdEncoding.EncodeToString(w.Image), style)
+	} else {
+		mark = fmt.Sprintf(`<div class="mark">%s</div>`, html.EscapeString(w.Text))
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><style>
+html, body { margin: 0; background: transparent; }
+.page { position: fixed; inset: 0; padding: 24px; display: flex; justify-content: %s; align-items: %s; }
+.mark { opacity: %s; transform: rotate(%sdeg); font: bold %s %s; color: %s; white-space: pre; }
+</style></head><body><div class="page">%s</div></body></html>`,
+		pos[0], pos[1],
+		strconv.FormatFloat(opacity, 'f', -1, 64),
+		strconv.FormatFloat(w.Rotation, 'f', -1, 64),
+		defaultString(w.FontSize, "96px"),
+		defaultString(w.FontFamily, "sans-serif"),
+		defaultString(w.Color, "#888"),
+		mark)
+}